@@ -1,120 +1,1380 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"ProxyScraperChecker/src"
+	"ProxyScraperChecker/src/store"
 )
 
 func main() {
-	// Parse command line flags
-	strictCheck := flag.Bool("strict", false, "Enable strict proxy checking")
-	detailedOutput := flag.Bool("detailed", false, "Show detailed checking results")
-	flag.Parse()
+	// The first argument names a subcommand unless it is a flag. A bare
+	// invocation scrapes, checks and serves proxies in a single run.
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
 
-	// Set up logging to file
-	logFile, err := os.OpenFile("proxy_checker.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("Error opening log file: %v\n", err)
+	switch command {
+	case "":
+		runAll(args)
+	case "scrape":
+		runScrape(args)
+	case "check":
+		runCheck(args)
+	case "serve":
+		runServe(args)
+	case "stats":
+		runStats(args)
+	case "judge":
+		runJudge(args)
+	default:
+		fmt.Printf("❌ Unknown command %q, expected scrape, check, serve, stats or judge\n", command)
+		os.Exit(2)
+	}
+}
+
+// runAll runs the bare invocation: scrape the sources, check the proxies
+// found and serve the working ones
+func runAll(args []string) {
+	o := newOptions(filepath.Base(os.Args[0]))
+	o.addScrapeFlags()
+	o.addCheckFlags()
+	o.addInputFlags()
+	o.addProgressFlags()
+	checkOnly := o.flags.Bool("check-only", false, "Check the proxies of --input instead of scraping sources")
+	scrapeOnly := o.flags.Bool("scrape-only", false, "Write the scraped proxies to raw_<protocol>.txt without checking them")
+	o.flags.BoolVar(&o.revalidateFirst, "revalidate-first", false, "Check the previously working proxies before the newly scraped ones and publish them early")
+	if !o.parse(args) {
+		return
+	}
+	if *checkOnly != (len(o.inputs) > 0) {
+		fmt.Println("❌ --check-only and --input must be used together")
+		return
+	}
+	if *scrapeOnly && (*checkOnly || o.resume) {
+		fmt.Println("❌ --scrape-only cannot be combined with --check-only or --resume")
+		return
+	}
+	if o.revalidateFirst && (*checkOnly || *scrapeOnly) {
+		fmt.Println("❌ --revalidate-first cannot be combined with --check-only or --scrape-only")
+		return
+	}
+	if o.dryRun {
+		if *checkOnly || o.resume {
+			fmt.Println("❌ --dry-run cannot be combined with --check-only or --resume")
+			return
+		}
+		if !dryRun(o, !*scrapeOnly) {
+			os.Exit(1)
+		}
 		return
 	}
-	defer logFile.Close()
-	log.SetOutput(logFile)
 
-	// Load configuration
-	config, err := src.LoadConfig("config.yaml")
-	if err != nil {
-		log.Printf("Error loading config: %v", err)
+	s, ok := o.start()
+	if !ok {
 		return
 	}
+	defer s.Close()
+	printBanner(s.config)
 
-	// Update checker configuration with command line flags
-	config.Checker.StrictCheck = *strictCheck
-	config.Checker.DetailedOutput = *detailedOutput
+	if *scrapeOnly {
+		if writeRawProxies(s.ctx, s.config) {
+			info("\n✨ Proxy scraping completed\n")
+		}
+		return
+	}
+	checkAndServe(s, o, !*checkOnly)
+}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll("out", 0755); err != nil {
-		log.Printf("Error creating output directory: %v", err)
+// runScrape runs the scrape subcommand, which writes the scraped proxies to
+// raw_<protocol>.txt without checking them
+func runScrape(args []string) {
+	o := newOptions("scrape")
+	o.addScrapeFlags()
+	o.addProgressFlags()
+	if !o.parse(args) {
 		return
 	}
+	if o.dryRun {
+		if !dryRun(o, false) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	s, ok := o.start()
+	if !ok {
+		return
+	}
+	defer s.Close()
+	info("🚀 Proxy Scraper Started\n")
+
+	if writeRawProxies(s.ctx, s.config) {
+		info("\n✨ Proxy scraping completed\n")
+	}
+}
+
+// runCheck runs the check subcommand, which checks the proxies of the files
+// given as arguments or with --input, then serves the working ones
+func runCheck(args []string) {
+	o := newOptions("check")
+	o.addCheckFlags()
+	o.addInputFlags()
+	o.addProgressFlags()
+	if !o.parse(args) {
+		return
+	}
+	o.inputs = append(o.inputs, o.flags.Args()...)
+	if len(o.inputs) == 0 && !o.resume {
+		fmt.Println("❌ No proxies to check, give proxy list files as arguments or - for stdin")
+		return
+	}
+
+	s, ok := o.start()
+	if !ok {
+		return
+	}
+	defer s.Close()
+	printBanner(s.config)
+	checkAndServe(s, o, false)
+}
+
+// runServe runs the serve subcommand, which serves the working proxies of
+// the last run with the rotating proxy server and the API without checking
+// them again
+func runServe(args []string) {
+	o := newOptions("serve")
+	if !o.parse(args) {
+		return
+	}
+
+	s, ok := o.start()
+	if !ok {
+		return
+	}
+	defer s.Close()
+	if !s.config.Server.Enabled() && s.config.API.Listen == "" && s.config.GRPC.Listen == "" {
+		fmt.Println("❌ Nothing to serve, set server.http_listen, server.socks5_listen, api.listen or grpc.listen")
+		return
+	}
+
+	pool := src.NewPool()
+	for _, proxyType := range src.DetectOrder {
+		path := src.OutputFile(s.config.Output.Dir, proxyType)
+		if _, err := pool.Load(path, proxyType); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Error reading working proxies", "path", path, "error", err)
+			fmt.Printf("❌ Error reading %s: %v\n", path, err)
+			return
+		}
+	}
+
+	services := startServices(s, pool, nil)
+	info("🔁 Serving %d working proxies, press Ctrl+C to stop\n", pool.Len())
+	services.Wait()
+}
+
+// runStats runs the stats subcommand, which summarizes the results of the
+// last runs: working proxies, an interrupted run, source health and proxy
+// history
+func runStats(args []string) {
+	o := newOptions("stats")
+	if !o.parse(args) {
+		return
+	}
+	config, err := o.loadConfig()
+	if err != nil {
+		fmt.Printf("❌ Error loading config: %v\n", err)
+		return
+	}
+
+	fmt.Printf("📊 Working proxies in %s\n", config.Output.Dir)
+	for _, proxyType := range src.DetectOrder {
+		proxies, err := src.ReadOutputFile(src.OutputFile(config.Output.Dir, proxyType))
+		if errors.Is(err, fs.ErrNotExist) && proxyType != src.ProxyTypeHTTP && proxyType != src.ProxyTypeSOCKS5 {
+			continue
+		}
+		fmt.Printf("  %-7s %d\n", proxyType, len(proxies))
+	}
+
+	checkpointPath := filepath.Join(config.Output.Dir, "checkpoint.json")
+	if checkpoint, err := src.LoadCheckpoint(checkpointPath); err == nil {
+		httpProxies, socks5Proxies, autoProxies := checkpoint.Pending()
+		fmt.Printf("♻️ Run started at %s was interrupted with %d proxies left, run with --resume to finish it\n",
+			checkpoint.Created().Format(time.DateTime), len(httpProxies)+len(socks5Proxies)+len(autoProxies))
+		checkpoint.Close()
+	}
+
+	if config.Scraper.HealthFile != "" {
+		health, err := src.LoadSourceHealth(config.Scraper.HealthFile)
+		if err != nil {
+			fmt.Printf("❌ Error reading source health: %v\n", err)
+			return
+		}
+		stats := health.Stats()
+		disabled := 0
+		for _, source := range stats {
+			if source.Disabled {
+				disabled++
+			}
+		}
+		fmt.Printf("📊 %d sources tracked, %d disabled\n", len(stats), disabled)
+		for _, source := range stats {
+			fmt.Printf("  %5.1f%% working, %d runs  %s %s\n", source.WorkingRate(), source.Runs, source.Protocol, source.URL)
+		}
+	}
+
+	if config.Store.Path != "" {
+		history, err := store.Open(config.Store.Path)
+		if err != nil {
+			fmt.Printf("❌ Error opening history database: %v\n", err)
+			return
+		}
+		defer history.Close()
+		for _, proxyType := range src.DetectOrder {
+			entries, err := history.Entries(context.Background(), proxyType.String())
+			if err != nil {
+				fmt.Printf("❌ Error reading proxy history: %v\n", err)
+				return
+			}
+			if len(entries) == 0 {
+				continue
+			}
+			var uptime float64
+			for _, entry := range entries {
+				uptime += entry.Uptime()
+			}
+			fmt.Printf("📊 %d %s proxies in history, %.1f%% average uptime\n", len(entries), proxyType, uptime/float64(len(entries)))
+		}
+	}
+}
+
+// dryRun checks the configuration and that the sources can be reached,
+// then prints the plan of a run, which checks the scraped proxies if check
+// is set, without scraping or checking anything. It reports false if the
+// configuration or the sources are invalid or a source cannot be reached.
+func dryRun(o *options, check bool) bool {
+	config, err := o.loadConfig()
+	if err != nil {
+		fmt.Printf("❌ Error loading config: %v\n", err)
+		return false
+	}
+	fmt.Println("🧪 Dry run, nothing will be scraped or checked")
+	if o.profile != "" {
+		fmt.Printf("✅ Configuration %s is valid with profile %s\n", o.configPath, o.profile)
+	} else {
+		fmt.Printf("✅ Configuration %s is valid\n", o.configPath)
+	}
+
+	sources, _, ok := activeSources(config)
+	if !ok {
+		return false
+	}
+	fmt.Printf("✅ %d sources: %d HTTP, %d SOCKS5, %d auto\n", len(sources),
+		len(src.SourcesFor(sources, "http")), len(src.SourcesFor(sources, "socks5")), len(src.SourcesFor(sources, "auto")))
+
+	client, proxies, err := src.NewScrapeClient(config)
+	if err != nil {
+		fmt.Printf("❌ Error creating scraping client: %v\n", err)
+		return false
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Println("🔎 Probing sources...")
+	reachable, unprobed := 0, 0
+	for _, probe := range src.ProbeSources(ctx, client, sources, config.Scraper) {
+		switch {
+		case probe.Target == "":
+			unprobed++
+		case probe.Err != nil:
+			fmt.Printf("  ❌ %s %s: %v\n", probe.Source.Protocol, probe.Target, probe.Err)
+		default:
+			reachable++
+		}
+	}
+	status := "✅"
+	if reachable+unprobed < len(sources) {
+		status = "❌"
+	}
+	fmt.Printf("%s %d of %d sources reachable", status, reachable, len(sources)-unprobed)
+	if unprobed > 0 {
+		fmt.Printf(", %d not probed (github, stdin, cidr and custom source types)", unprobed)
+	}
+	fmt.Println()
+
+	fmt.Println("📋 Plan:")
+	fmt.Printf("  • Scrape %d sources, %d at once, with a timeout of %s and %d retries\n",
+		len(sources), config.Scraper.Concurrent, config.Scraper.Timeout, config.Scraper.Retries)
+	switch {
+	case config.Scraper.UpstreamProxy != "":
+		// Validated with the configuration, credentials are not printed
+		upstream, _ := url.Parse(config.Scraper.UpstreamProxy)
+		fmt.Printf("  • Scrape through %s\n", upstream.Redacted())
+	case proxies > 0:
+		fmt.Printf("  • Scrape through %d previously working proxies\n", proxies)
+	}
+	if !check {
+		fmt.Printf("  • Write the scraped proxies to raw_<protocol>.txt in %s\n", config.Output.Dir)
+		return status == "✅"
+	}
+
+	checker := config.Checker
+	if workers := len(config.Distributed.Workers); workers > 0 {
+		fmt.Printf("  • Check on %d remote workers, %d batches of %d proxies at once each\n",
+			workers, config.Distributed.BatchesPerWorker, config.Distributed.BatchSize)
+	} else {
+		fmt.Printf("  • Check %d HTTP, %d SOCKS5 and %d proxies of unknown protocol at once\n",
+			checker.ConcurrentHTTP, checker.ConcurrentSOCKS5, checker.ConcurrentAuto)
+	}
+	mode := "basic"
+	if checker.StrictCheck {
+		mode = "strict"
+	}
+	fmt.Printf("  • Check in %s mode with a timeout of %s, a connect timeout of %s and %d retries\n",
+		mode, checker.Timeout, checker.ConnectTimeout, checker.Retries)
+	fmt.Printf("  • Run the check steps %s\n", strings.Join(checker.Steps, ", "))
+	if checker.MaxLatency > 0 {
+		fmt.Printf("  • Drop proxies slower than %s\n", checker.MaxLatency)
+	}
+	fmt.Printf("  • Write the working proxies to %s\n", config.Output.Dir)
+
+	var listeners []string
+	for _, listener := range []struct{ name, addr string }{
+		{"HTTP proxy", config.Server.HTTPListen},
+		{"SOCKS5 proxy", config.Server.SOCKS5Listen},
+		{"API", config.API.Listen},
+		{"gRPC", config.GRPC.Listen},
+	} {
+		if listener.addr != "" {
+			listeners = append(listeners, fmt.Sprintf("%s on %s", listener.name, listener.addr))
+		}
+	}
+	if len(listeners) > 0 {
+		fmt.Printf("  • Serve them with the %s\n", strings.Join(listeners, ", "))
+		if config.Daemon.Interval > 0 {
+			fmt.Printf("  • Scrape and check again %s after each check\n", config.Daemon.Interval)
+		}
+	}
+	return status == "✅"
+}
+
+// printBanner prints the start message and the active checking parameters
+func printBanner(config *src.Config) {
+	info("🚀 Proxy Scraper and Checker Started\n")
 
-	fmt.Println("🚀 Proxy Scraper and Checker Started")
-	
 	// Display active parameters
-	if *strictCheck || *detailedOutput {
-		fmt.Println("Active parameters:")
-		if *strictCheck {
-			fmt.Println("  • Strict checking mode enabled")
+	if config.Checker.StrictCheck {
+		info("Active parameters:\n")
+		info("  • Strict checking mode enabled\n")
+		if config.Checker.DetailedOutput {
+			info("  • Detailed output mode enabled\n")
 		}
-		if *detailedOutput {
-			fmt.Println("  • Detailed output mode enabled")
+		info("\n")
+	}
+}
+
+// checkAndServe checks proxies and serves the working ones. With
+// daemon.interval, they are checked again at every interval while served,
+// with config.yaml and the sources reloaded first if they changed.
+func checkAndServe(s *session, o *options, scrape bool) {
+	pool := src.NewPool()
+	var running *serviceSet
+	serve := func(checker *src.ProxyChecker) {
+		if running == nil {
+			running = startServices(s, pool, checker)
+		} else {
+			running.setChecker(checker)
 		}
-		fmt.Println()
 	}
 
-	// Scrape HTTP proxies
-	httpSources, err := src.ReadLines(filepath.Join("sources", "http.txt"))
+	checkCycle(s, o, scrape, pool, serve)
+	if running == nil {
+		return
+	}
+	daemon := s.config.Server.Enabled() || s.config.API.Listen != "" || s.config.GRPC.Listen != ""
+	if !daemon || s.ctx.Err() != nil {
+		running.Wait()
+		return
+	}
+
+	if s.config.Daemon.Interval > 0 {
+		watcher := watchConfig(s, o)
+		defer watcher.Close()
+		// Later checks start afresh
+		o.resume = false
+		for s.ctx.Err() == nil && s.config.Daemon.Interval > 0 {
+			next := time.Now().Add(s.config.Daemon.Interval)
+			info("🔁 Serving %d working proxies until the next check at %s, press Ctrl+C to stop\n", pool.Len(), next.Format(time.TimeOnly))
+			select {
+			case <-time.After(s.config.Daemon.Interval):
+			case <-s.ctx.Done():
+				continue
+			}
+			reloadConfig(s, o, watcher)
+			checkCycle(s, o, scrape, pool, serve)
+		}
+	}
+	if s.ctx.Err() == nil {
+		info("🔁 Serving %d working proxies, press Ctrl+C to stop\n", pool.Len())
+	}
+	running.Wait()
+}
+
+// watchConfig returns a watcher of config.yaml and the sources, nil if they
+// cannot be watched, after printing the reason
+func watchConfig(s *session, o *options) *src.FileWatcher {
+	watcher, err := src.NewFileWatcher()
+	if err == nil {
+		if err = watcher.Watch(o.configPath, s.config.Scraper.SourcesDir); err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		slog.Error("Error watching config", "error", err)
+		fmt.Printf("⚠️ Cannot watch the configuration, changes will not be reloaded: %v\n", err)
+		return nil
+	}
+	return watcher
+}
+
+// reloadConfig loads the configuration again if config.yaml or the sources
+// changed since the last check. The previous configuration is kept if the
+// new one is invalid. Logging and the servers keep the configuration they
+// started with.
+func reloadConfig(s *session, o *options, watcher *src.FileWatcher) {
+	changed := watcher.Changed()
+	if len(changed) == 0 {
+		return
+	}
+	names := make([]string, len(changed))
+	for i, path := range changed {
+		names[i] = filepath.Base(path)
+	}
+	info("📝 %s changed, reloading the configuration\n", strings.Join(names, ", "))
+
+	config, err := o.loadConfig()
+	if err == nil {
+		err = os.MkdirAll(config.Output.Dir, 0755)
+	}
 	if err != nil {
-		log.Printf("Error reading HTTP sources: %v", err)
+		slog.Error("Error reloading config", "error", err)
+		fmt.Printf("❌ Error reloading config, keeping the previous one: %v\n", err)
 		return
 	}
+	s.config = config
+	if err := watcher.Watch(o.configPath, config.Scraper.SourcesDir); err != nil {
+		slog.Error("Error watching sources", "error", err)
+	}
+}
 
-	httpProxies := src.ScrapeProxies(httpSources, config.Scraper.UserAgents, config.Scraper.Timeout, "HTTP", config.Scraper.Concurrent)
+// checkCycle checks proxies and adds their results to pool: those left by
+// an interrupted run with --resume, else those of the input files, else the
+// scraped ones if scrape is set. serve is called with the checker once
+// checking starts, so that the servers report its progress.
+func checkCycle(s *session, o *options, scrape bool, pool *src.Pool, serve func(checker *src.ProxyChecker)) {
+	ctx, config := s.ctx, s.config
+	started := time.Now()
 
-	// Scrape SOCKS5 proxies
-	socks5Sources, err := src.ReadLines(filepath.Join("sources", "socks5.txt"))
+	// Pick up the proxies left unchecked by an interrupted run, or read or
+	// scrape new ones
+	checkpointPath := filepath.Join(config.Output.Dir, "checkpoint.json")
+	var checkpoint *src.Checkpoint
+	if o.resume {
+		var err error
+		checkpoint, err = src.LoadCheckpoint(checkpointPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			info("ℹ️ No checkpoint found, starting a new run\n")
+		} else if err != nil {
+			slog.Error("Error reading checkpoint", "error", err)
+			fmt.Printf("❌ Error reading checkpoint: %v\n", err)
+			return
+		}
+		defer checkpoint.Close()
+	}
+
+	var httpProxies, socks5Proxies, autoProxies []string
+	var health *src.SourceHealth
+	var previous map[string]bool
+	var ok bool
+	switch {
+	case checkpoint != nil:
+		httpProxies, socks5Proxies, autoProxies = checkpoint.Pending()
+		info("♻️ Resuming the run started at %s\n", checkpoint.Created().Format(time.DateTime))
+	case len(o.inputs) > 0:
+		if httpProxies, socks5Proxies, autoProxies, ok = readInputs(ctx, config, o.inputs, o.inputType); !ok {
+			return
+		}
+	case scrape:
+		if o.revalidateFirst {
+			previous = previousProxies(config)
+		}
+		if httpProxies, socks5Proxies, autoProxies, health, ok = collectProxies(ctx, config); !ok {
+			return
+		}
+	default:
+		info("ℹ️ Nothing to check\n")
+		return
+	}
+
+	// Spread the proxies of a subnet across the run, before the priorities
+	// below reorder them
+	if config.Checker.Shuffle && checkpoint == nil {
+		for _, proxies := range [][]string{httpProxies, socks5Proxies, autoProxies} {
+			src.ShuffleProxies(proxies)
+		}
+	}
+
+	// Check historically reliable proxies first
+	var history *store.Store
+	if config.Store.Path != "" {
+		var err error
+		history, err = store.Open(config.Store.Path)
+		if err != nil {
+			slog.Error("Error opening history database", "error", err)
+			fmt.Printf("❌ Error opening history database: %v\n", err)
+			return
+		}
+		defer history.Close()
+
+		// Proxies of unknown protocol are recorded with the type they were
+		// detected as, and rank by the best of their histories
+		prioritize := func(proxies []string, proxyTypes ...src.ProxyType) {
+			scores := make(map[string]float64)
+			for _, proxyType := range proxyTypes {
+				entries, err := history.Entries(ctx, proxyType.String())
+				if err != nil {
+					slog.Error("Error reading proxy history", "type", proxyType, "error", err)
+					return
+				}
+				for proxy, entry := range entries {
+					scores[proxy] = max(scores[proxy], entry.Uptime())
+				}
+			}
+			src.PrioritizeProxies(proxies, scores)
+		}
+		if checkpoint == nil {
+			prioritize(httpProxies, src.ProxyTypeHTTP)
+			prioritize(socks5Proxies, src.ProxyTypeSOCKS5)
+			prioritize(autoProxies, src.ProxyTypeHTTP, src.ProxyTypeHTTPS, src.ProxyTypeSOCKS4, src.ProxyTypeSOCKS5, src.ProxyTypeAuto)
+		}
+	}
+
+	// With --revalidate-first, the previously working proxies come before
+	// the newly scraped ones
+	if previous != nil {
+		scores := make(map[string]float64, len(previous))
+		for proxy := range previous {
+			scores[proxy] = 1
+		}
+		for _, proxies := range [][]string{httpProxies, socks5Proxies, autoProxies} {
+			src.PrioritizeProxies(proxies, scores)
+		}
+	}
+
+	info("✅ Total %d HTTP proxies to check\n", len(httpProxies))
+	info("✅ Total %d SOCKS5 proxies to check\n", len(socks5Proxies))
+	if len(autoProxies) > 0 {
+		info("✅ Total %d proxies of unknown protocol to check\n", len(autoProxies))
+	}
+	info("🔍 Checking proxies...\n")
+
+	// Start the rotating proxy server and the API early so they serve
+	// proxies as soon as they are validated
+	checker := src.NewProxyChecker(config)
+	checker.ResultChan = make(chan src.CheckResult, 100)
+	checker.Store = history
+	checker.Revalidate = previous
+	if config.GeoIP.Database != "" {
+		geo, err := src.OpenGeoIP(config.GeoIP.Database)
+		if err != nil {
+			slog.Error("Error opening GeoIP database", "error", err)
+			fmt.Printf("❌ Error opening GeoIP database: %v\n", err)
+			return
+		}
+		defer geo.Close()
+		checker.GeoIP = geo
+	}
+	if config.GeoIP.ASNDatabase != "" {
+		asn, err := src.OpenASNDatabase(config.GeoIP.ASNDatabase)
+		if err != nil {
+			slog.Error("Error opening ASN database", "error", err)
+			fmt.Printf("❌ Error opening ASN database: %v\n", err)
+			return
+		}
+		defer asn.Close()
+		checker.ASN = asn
+	}
+	reputation, err := src.NewReputation(config)
 	if err != nil {
-		log.Printf("Error reading SOCKS5 sources: %v", err)
+		slog.Error("Error loading blocklist", "error", err)
+		fmt.Printf("❌ Error loading blocklist: %v\n", err)
 		return
 	}
+	checker.Reputation = reputation
+	if config.Checker.TorExits != "" {
+		torExits, err := src.LoadTorExits(ctx, config)
+		if err != nil {
+			slog.Error("Error loading Tor exit list", "error", err)
+			fmt.Printf("❌ Error loading Tor exit list: %v\n", err)
+			return
+		}
+		info("ℹ️ Loaded %d Tor exit nodes\n", torExits.Len())
+		checker.TorExits = torExits
+	}
+	workers, err := src.NewRemoteWorkers(config)
+	if err != nil {
+		slog.Error("Error configuring remote workers", "error", err)
+		fmt.Printf("❌ Error configuring remote workers: %v\n", err)
+		return
+	}
+	defer workers.Close()
+	if workers != nil {
+		checker.Workers = workers
+		info("🛰️ Checking on %d remote workers\n", workers.Len())
+	}
+	shared, err := src.NewRedisPool(ctx, config)
+	if err != nil {
+		slog.Error("Error connecting to Redis", "error", err)
+		fmt.Printf("❌ Error connecting to Redis: %v\n", err)
+		return
+	}
+	if shared != nil {
+		info("🧰 Publishing working proxies to Redis at %s\n", shared.Addr())
+	}
 
-	socks5Proxies := src.ScrapeProxies(socks5Sources, config.Scraper.UserAgents, config.Scraper.Timeout, "SOCKS5", config.Scraper.Concurrent)
+	// Record progress so that an interrupted run can be resumed, once
+	// nothing can stop the run before it starts checking
+	if checkpoint == nil {
+		checkpoint, err = src.CreateCheckpoint(checkpointPath, httpProxies, socks5Proxies, autoProxies)
+		if err != nil {
+			slog.Error("Error creating checkpoint", "error", err)
+		}
+		defer checkpoint.Close()
+	}
+	checker.Checkpoint = checkpoint
+	serve(checker)
+
+	// Start checking
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		for result := range checker.ResultChan {
+			pool.Add(result)
+			health.RecordResult(result)
+			s.dashboard.AddResult(result)
+			shared.Publish(result)
+		}
+	}()
+
+	checkErr := checker.CheckProxies(ctx, httpProxies, socks5Proxies, autoProxies)
+	<-consumed
+	if err := shared.Close(); err != nil {
+		slog.Error("Error closing Redis connection", "error", err)
+	}
+	// Proxies left unchecked stay in the checkpoint to be resumed
+	if ctx.Err() != nil || checkErr != nil {
+		if err := checkpoint.Close(); err != nil {
+			slog.Error("Error writing checkpoint", "error", err)
+		}
+		if checkErr != nil {
+			fmt.Printf("❌ Checking stopped: %v, results validated so far were saved\n", checkErr)
+		} else {
+			fmt.Println("⚠️ Interrupted, results validated so far were saved")
+		}
+		if checkpoint != nil {
+			fmt.Println("ℹ️ Run again with --resume to check the remaining proxies")
+		}
+		return
+	}
+	if err := checkpoint.Remove(); err != nil {
+		slog.Error("Error removing checkpoint", "error", err)
+	}
+
+	// Source statistics are only updated by complete runs
+	if health != nil {
+		health.Finish(time.Now())
+		if err := health.Save(); err != nil {
+			slog.Error("Error saving source health", "error", err)
+		}
+		if err := health.WriteReport(filepath.Join(config.Output.Dir, "sources_report.csv")); err != nil {
+			slog.Error("Error writing sources report", "error", err)
+		}
+	}
+	info("\n✨ Proxy scraping and checking completed\n")
+
+	uploader, err := src.NewS3Uploader(config)
+	if err != nil {
+		slog.Error("Error configuring upload", "error", err)
+		fmt.Printf("❌ Error configuring upload: %v\n", err)
+	} else if uploader != nil {
+		n, err := uploader.Upload(ctx, time.Now())
+		if err != nil {
+			slog.Error("Error uploading results", "error", err)
+			fmt.Printf("❌ Error uploading results: %v\n", err)
+		} else {
+			info("☁️ Uploaded %d files to bucket %s\n", n, config.Output.Upload.Bucket)
+		}
+	}
+
+	if notifier := src.NewTelegramNotifier(config); notifier != nil {
+		summary := append([]string{"✨ Proxy scraping and checking completed"}, checker.Progress().Summary()...)
+		summary = append(summary, fmt.Sprintf("⏱️ Took %s", time.Since(started).Round(time.Second)))
+		if err := notifier.Notify(ctx, strings.Join(summary, "\n")); err != nil {
+			slog.Error("Error sending Telegram notification", "error", err)
+			fmt.Printf("❌ Error sending Telegram notification: %v\n", err)
+		}
+	}
+}
+
+// serviceSet holds the rotating proxy server, the API and the gRPC service,
+// running until the session is interrupted
+type serviceSet struct {
+	sync.WaitGroup
+	api  *src.APIServer
+	grpc *src.GRPCServer
+}
+
+// setChecker makes the API and the gRPC service use the checker of a new
+// check
+func (s *serviceSet) setChecker(checker *src.ProxyChecker) {
+	if s.api != nil {
+		s.api.SetChecker(checker)
+	}
+	if s.grpc != nil {
+		s.grpc.SetChecker(checker)
+	}
+}
+
+// startServices starts the rotating proxy server and the API, if
+// configured, until the session is interrupted. checker may be nil when no
+// checking is in progress.
+func startServices(s *session, pool *src.Pool, checker *src.ProxyChecker) *serviceSet {
+	services := &serviceSet{}
+	if s.config.Server.Enabled() {
+		server := src.NewRotatingServer(s.config, pool)
+		services.Add(1)
+		go func() {
+			defer services.Done()
+			if err := server.ListenAndServe(s.ctx); err != nil {
+				slog.Error("Error running rotating proxy server", "error", err)
+				fmt.Printf("❌ Rotating proxy server failed: %v\n", err)
+			}
+		}()
+	}
+
+	if s.config.API.Listen != "" {
+		api := src.NewAPIServer(s.config, pool, checker)
+		services.api = api
+		services.Add(1)
+		go func() {
+			defer services.Done()
+			if err := api.ListenAndServe(s.ctx); err != nil {
+				slog.Error("Error running API server", "error", err)
+				fmt.Printf("❌ API server failed: %v\n", err)
+			}
+		}()
+	}
+
+	if s.config.GRPC.Listen != "" {
+		grpc := src.NewGRPCServer(s.config, pool, checker)
+		services.grpc = grpc
+		services.Add(1)
+		go func() {
+			defer services.Done()
+			if err := grpc.ListenAndServe(s.ctx); err != nil {
+				slog.Error("Error running gRPC server", "error", err)
+				fmt.Printf("❌ gRPC server failed: %v\n", err)
+			}
+		}()
+	}
+	return services
+}
+
+// collectProxies scrapes the sources and merges the proxies found with the
+// previous results, then clears the output files. Proxies of unknown
+// protocol, whose protocol is detected while checking, are returned in
+// autoProxies. It reports false if the run must stop, after printing the
+// reason.
+func collectProxies(ctx context.Context, config *src.Config) (httpProxies, socks5Proxies, autoProxies []string, health *src.SourceHealth, ok bool) {
+	httpProxies, socks5Proxies, autoProxies, health, ok = scrapeSources(ctx, config)
+	if !ok {
+		return nil, nil, nil, nil, false
+	}
 
 	// Read existing proxies
-	existingHTTP, _ := src.ReadLines(filepath.Join("out", "http.txt"))
-	existingSOCKS5, _ := src.ReadLines(filepath.Join("out", "socks5.txt"))
+	existingHTTP, _ := src.ReadOutputFile(src.OutputFile(config.Output.Dir, src.ProxyTypeHTTP))
+	existingSOCKS5, _ := src.ReadOutputFile(src.OutputFile(config.Output.Dir, src.ProxyTypeSOCKS5))
 
 	// Add existing proxies
 	if len(existingHTTP) > 0 {
-		fmt.Printf("ℹ️ Found %d existing HTTP proxies\n", len(existingHTTP))
+		info("ℹ️ Found %d existing HTTP proxies\n", len(existingHTTP))
 		httpProxies = append(httpProxies, existingHTTP...)
 	}
 	if len(existingSOCKS5) > 0 {
-		fmt.Printf("ℹ️ Found %d existing SOCKS5 proxies\n", len(existingSOCKS5))
+		info("ℹ️ Found %d existing SOCKS5 proxies\n", len(existingSOCKS5))
 		socks5Proxies = append(socks5Proxies, existingSOCKS5...)
 	}
 
-	// Remove duplicates
-	httpProxies = src.RemoveDuplicates(httpProxies)
-	socks5Proxies = src.RemoveDuplicates(socks5Proxies)
+	// Previously detected HTTPS and SOCKS4 proxies are detected again
+	for _, proxyType := range []src.ProxyType{src.ProxyTypeHTTPS, src.ProxyTypeSOCKS4} {
+		existing, _ := src.ReadOutputFile(src.OutputFile(config.Output.Dir, proxyType))
+		if len(existing) > 0 {
+			info("ℹ️ Found %d existing %s proxies\n", len(existing), proxyType)
+			autoProxies = append(autoProxies, existing...)
+		}
+	}
 
-	// Clear existing output files
-	if err := os.WriteFile(filepath.Join("out", "http.txt"), []byte{}, 0644); err != nil {
-		log.Printf("Error clearing HTTP output file: %v", err)
-		return
+	httpProxies, socks5Proxies, autoProxies, ok = prepareProxies(ctx, config, httpProxies, socks5Proxies, autoProxies)
+	return httpProxies, socks5Proxies, autoProxies, health, ok
+}
+
+// previousProxies returns the working proxies of the previous results, of
+// all protocols
+func previousProxies(config *src.Config) map[string]bool {
+	previous := make(map[string]bool)
+	for _, proxyType := range src.DetectOrder {
+		proxies, _ := src.ReadOutputFile(src.OutputFile(config.Output.Dir, proxyType))
+		for _, proxy := range proxies {
+			previous[proxy] = true
+		}
 	}
-	if err := os.WriteFile(filepath.Join("out", "socks5.txt"), []byte{}, 0644); err != nil {
-		log.Printf("Error clearing SOCKS5 output file: %v", err)
-		return
+	return previous
+}
+
+// scrapeSources scrapes the proxies of the sources, skipping those that
+// stopped yielding working proxies. It reports false if the run must stop,
+// after printing the reason.
+func scrapeSources(ctx context.Context, config *src.Config) (httpProxies, socks5Proxies, autoProxies []string, health *src.SourceHealth, ok bool) {
+	sources, health, ok := activeSources(config)
+	if !ok {
+		return nil, nil, nil, nil, false
 	}
 
-	fmt.Printf("✅ Total %d HTTP proxies to check\n", len(httpProxies))
-	fmt.Printf("✅ Total %d SOCKS5 proxies to check\n", len(socks5Proxies))
-	fmt.Println("🔍 Checking proxies...")
+	client, proxies, err := src.NewScrapeClient(config)
+	if err != nil {
+		slog.Error("Error creating scraping client", "error", err)
+		fmt.Printf("❌ Error creating scraping client: %v\n", err)
+		return nil, nil, nil, nil, false
+	}
+	switch {
+	case config.Scraper.UpstreamProxy != "":
+		// Validated with the configuration, credentials are not printed
+		upstream, _ := url.Parse(config.Scraper.UpstreamProxy)
+		info("🔀 Scraping through %s\n", upstream.Redacted())
+	case proxies > 0:
+		info("🔀 Scraping through %d previously working proxies\n", proxies)
+	case config.Scraper.UseProxies:
+		fmt.Println("⚠️ No previously working HTTP or SOCKS5 proxies, scraping directly")
+	}
 
-	// Create checker and start checking
-	checker := src.NewProxyChecker(config)
-	go func() {
-		for result := range checker.ResultChan {
-			// Just receive results to prevent blocking
-			_ = result
+	// Requests to a host are limited across all sources and protocols
+	hosts := src.NewHostLimiter(config.Scraper.HostRequestsPerMinute, config.Scraper.Jitter)
+
+	// Scrape HTTP proxies
+	httpProxies = src.ScrapeProxies(ctx, client, src.SourcesFor(sources, "http"), config.Scraper, "HTTP", hosts, health)
+
+	// Scrape SOCKS5 proxies
+	socks5Proxies = src.ScrapeProxies(ctx, client, src.SourcesFor(sources, "socks5"), config.Scraper, "SOCKS5", hosts, health)
+
+	// Scrape proxies of unknown protocol
+	if autoSources := src.SourcesFor(sources, "auto"); len(autoSources) > 0 {
+		autoProxies = src.ScrapeProxies(ctx, client, autoSources, config.Scraper, "auto", hosts, health)
+	}
+
+	// Keep the previous results untouched if interrupted while scraping
+	if ctx.Err() != nil {
+		fmt.Println("\n⚠️ Interrupted, existing results were left unchanged")
+		return nil, nil, nil, nil, false
+	}
+	return httpProxies, socks5Proxies, autoProxies, health, true
+}
+
+// activeSources loads the sources to scrape: the flat txt files and, if
+// present, the structured sources.yaml, with the tokens of the scraper
+// section filled in, except duplicates and those that stopped yielding
+// working proxies. It reports false if they cannot be loaded, after printing the reason.
+func activeSources(config *src.Config) (sources []src.Source, health *src.SourceHealth, ok bool) {
+	sources, err := loadSources(config.Scraper.SourcesDir)
+	if err != nil {
+		slog.Error("Error reading sources", "error", err)
+		fmt.Printf("❌ Error reading sources: %v\n", err)
+		return nil, nil, false
+	}
+	for i := range sources {
+		if sources[i].Type == src.SourceTelegram && sources[i].BotToken == "" {
+			sources[i].BotToken = config.Scraper.TelegramBotToken
+		}
+		if sources[i].Type == src.SourceGitHub && sources[i].Token == "" {
+			sources[i].Token = config.Scraper.GitHubToken
+		}
+		// Ranges are only scanned on explicit request
+		if sources[i].Type == src.SourceCIDR && !config.Scraper.AllowCIDR {
+			slog.Error("cidr source without scraper.allow_cidr", "url", sources[i].URL)
+			fmt.Printf("❌ Error reading sources: %s requires scraper.allow_cidr: true\n", sources[i].URL)
+			return nil, nil, false
+		}
+	}
+
+	// Skip sources listed twice, possibly under another URL of the same list
+	sources, duplicates := src.DedupeSources(sources)
+	if len(duplicates) > 0 {
+		info("ℹ️ Skipped %d duplicate sources:\n", len(duplicates))
+		for _, duplicate := range duplicates {
+			info("   %s (same as %s)\n", duplicate.Source.URL, duplicate.Of.URL)
+			slog.Debug("Skipped duplicate source", "url", duplicate.Source.URL, "duplicate_of", duplicate.Of.URL)
+		}
+	}
+
+	// Skip sources that stopped yielding working proxies
+	if config.Scraper.HealthFile != "" {
+		health, err = src.LoadSourceHealth(config.Scraper.HealthFile)
+		if err != nil {
+			slog.Error("Error reading source health", "error", err)
+			fmt.Printf("❌ Error reading source health: %v\n", err)
+			return nil, nil, false
+		}
+		var disabled []src.Source
+		sources, disabled = health.Filter(sources, config.Scraper.DisableAfter)
+		if len(disabled) > 0 {
+			info("ℹ️ Skipped %d sources without working proxies in the last %d runs\n", len(disabled), config.Scraper.DisableAfter)
 		}
+	}
+	return sources, health, true
+}
+
+// writeRawProxies scrapes the sources and writes the proxies found, without
+// duplicates, to raw_http.txt, raw_socks5.txt and raw_auto.txt in the output
+// directory. raw_auto.txt is only written if auto sources found proxies. It
+// reports false if the run was interrupted or failed, after printing the
+// reason.
+func writeRawProxies(ctx context.Context, config *src.Config) bool {
+	httpProxies, socks5Proxies, autoProxies, _, ok := scrapeSources(ctx, config)
+	if !ok {
+		return false
+	}
+
+	for _, list := range []struct {
+		protocol string
+		proxies  []string
+	}{
+		{"http", httpProxies},
+		{"socks5", socks5Proxies},
+		{"auto", autoProxies},
+	} {
+		path := filepath.Join(config.Output.Dir, "raw_"+list.protocol+".txt")
+		if list.protocol == "auto" && len(list.proxies) == 0 {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.Error("Error removing raw proxies", "path", path, "error", err)
+			}
+			continue
+		}
+
+		if err := src.WriteLines(path, list.proxies); err != nil {
+			slog.Error("Error writing raw proxies", "path", path, "error", err)
+			fmt.Printf("❌ Error writing %s: %v\n", path, err)
+			return false
+		}
+		info("✅ Saved %d %s proxies to %s\n", len(list.proxies), list.protocol, path)
+	}
+	return true
+}
+
+// readInputs reads the proxies to check from user-provided files, - for
+// stdin, then clears the output files. It reports false if the run must
+// stop, after printing the reason.
+func readInputs(ctx context.Context, config *src.Config, paths []string, protocol string) (httpProxies, socks5Proxies, autoProxies []string, ok bool) {
+	var in src.InputProxies
+	for _, path := range paths {
+		var err error
+		if path == "-" {
+			err = in.Read(os.Stdin, protocol)
+		} else {
+			var file *os.File
+			if file, err = os.Open(path); err == nil {
+				err = in.Read(file, protocol)
+				file.Close()
+			}
+		}
+		if err != nil {
+			slog.Error("Error reading input", "path", path, "error", err)
+			fmt.Printf("❌ Error reading %s: %v\n", path, err)
+			return nil, nil, nil, false
+		}
+	}
+	if in.Invalid > 0 {
+		info("ℹ️ Skipped %d input lines that are not proxies\n", in.Invalid)
+	}
+	return prepareProxies(ctx, config, in.HTTP, in.SOCKS5, in.Auto)
+}
+
+// prepareProxies removes duplicate and unreachable proxies from the lists
+// to check, keeps a copy of the previous results with output.keep_history,
+// then clears the output files with output.write_mode: append. It reports
+// false if the run must stop.
+func prepareProxies(ctx context.Context, config *src.Config, httpProxies, socks5Proxies, autoProxies []string) ([]string, []string, []string, bool) {
+	if upstream, err := src.CheckerUpstream(config); err == nil && upstream != nil {
+		info("🔀 Checking through %s\n", upstream.Redacted())
+	}
+
+	// Detect the protocol of every proxy, checking proxies listed with
+	// several protocols only once
+	if config.Checker.DetectProtocol {
+		autoProxies = append(append(autoProxies, httpProxies...), socks5Proxies...)
+		httpProxies, socks5Proxies = nil, nil
+	}
+
+	// Remove duplicates, in place to keep large lists in memory once
+	dedupe := func(proxies []string) []string {
+		return src.DedupeProxies(proxies, src.NewProxySet(config.Scraper.Dedup, config.Scraper.DedupCapacity))
+	}
+	httpProxies = dedupe(httpProxies)
+	socks5Proxies = dedupe(socks5Proxies)
+	autoProxies = dedupe(autoProxies)
+
+	// Skip IPv6 proxies the local host cannot reach
+	var skippedHTTP, skippedSOCKS5, skippedAuto int
+	httpProxies, skippedHTTP = src.FilterIPv6(httpProxies, config.Checker.IPv6)
+	socks5Proxies, skippedSOCKS5 = src.FilterIPv6(socks5Proxies, config.Checker.IPv6)
+	autoProxies, skippedAuto = src.FilterIPv6(autoProxies, config.Checker.IPv6)
+	if skipped := skippedHTTP + skippedSOCKS5 + skippedAuto; skipped > 0 {
+		info("ℹ️ Skipped %d IPv6 proxies (checker.ipv6: %s)\n", skipped, config.Checker.IPv6)
+	}
+
+	// Skip proxies whose port does not accept connections before the full
+	// checks
+	if config.Checker.Prefilter {
+		info("🔌 Connecting to %d proxies to skip unreachable ones...\n", len(httpProxies)+len(socks5Proxies)+len(autoProxies))
+		lists, unreachable := src.Prefilter(ctx, config, httpProxies, socks5Proxies, autoProxies)
+		if ctx.Err() != nil {
+			fmt.Println("\n⚠️ Interrupted, existing results were left unchanged")
+			return nil, nil, nil, false
+		}
+		httpProxies, socks5Proxies, autoProxies = lists[0], lists[1], lists[2]
+		info("ℹ️ Skipped %d unreachable proxies (checker.prefilter)\n", unreachable)
+	}
+
+	if err := src.RotateHistory(config); err != nil {
+		slog.Error("Error keeping previous results", "error", err)
+		fmt.Printf("❌ Error keeping previous results: %v\n", err)
+		return nil, nil, nil, false
+	}
+
+	// Clear existing output files. HTTPS and SOCKS4 files are only created
+	// again if protocol detection finds such proxies. Atomic runs replace
+	// them once checking ends instead.
+	if config.Output.WriteMode == "atomic" {
+		return httpProxies, socks5Proxies, autoProxies, true
+	}
+	for _, proxyType := range src.DetectOrder {
+		path := src.OutputFile(config.Output.Dir, proxyType)
+		var err error
+		if proxyType == src.ProxyTypeHTTP || proxyType == src.ProxyTypeSOCKS5 {
+			err = os.WriteFile(path, []byte{}, 0644)
+		} else if err = os.Remove(path); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			slog.Error("Error clearing output file", "path", path, "error", err)
+			return nil, nil, nil, false
+		}
+	}
+	return httpProxies, socks5Proxies, autoProxies, true
+}
+
+// loadSources reads http.txt, socks5.txt, auto.txt and sources.yaml in dir.
+// auto.txt is optional, the other txt files may be missing when sources.yaml
+// exists.
+func loadSources(dir string) ([]src.Source, error) {
+	sources, err := src.LoadSources(filepath.Join(dir, "sources.yaml"))
+	hasYAML := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("sources.yaml: %w", err)
+	}
+
+	for _, protocol := range []string{"http", "socks5", "auto"} {
+		lines, err := src.ReadLines(filepath.Join(dir, protocol+".txt"))
+		if err != nil {
+			if (hasYAML || protocol == "auto") && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		parsed, err := src.ParseSources(lines)
+		if err != nil {
+			return nil, fmt.Errorf("%s.txt: %w", protocol, err)
+		}
+		for _, source := range parsed {
+			source.Protocol = protocol
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// runJudge runs the judge subcommand, a self-hosted replacement for
+// httpbin.org that checkers can use in checker.judge_urls
+func runJudge(args []string) {
+	flags := flag.NewFlagSet("judge", flag.ExitOnError)
+	listen := flags.String("listen", ":8080", "Address to listen on")
+	certFile := flags.String("tls-cert", "", "TLS certificate file (enables HTTPS with --tls-key)")
+	keyFile := flags.String("tls-key", "", "TLS private key file")
+	flags.Parse(args)
+
+	if (*certFile == "") != (*keyFile == "") {
+		fmt.Println("❌ --tls-cert and --tls-key must be used together")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheme := "http"
+	if *certFile != "" {
+		scheme = "https"
+	}
+	fmt.Printf("⚖️ Judge listening on %s://%s, press Ctrl+C to stop\n", scheme, *listen)
+
+	judge := src.NewJudgeServer(*listen, *certFile, *keyFile)
+	if err := judge.ListenAndServe(ctx); err != nil {
+		fmt.Printf("❌ Judge server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// quiet suppresses the messages printed by info
+var quiet bool
+
+// info prints an informational message unless --quiet is set
+func info(format string, a ...any) {
+	if !quiet {
+		fmt.Printf(format, a...)
+	}
+}
+
+// options holds the command line flags. Every subcommand registers the
+// shared flags and the groups of flags of the stages it runs.
+type options struct {
+	flags *flag.FlagSet
+
+	configPath string
+	profile    string
+	logLevel   string
+	outDir     string
+
+	sourcesDir string
+	dryRun     bool
+
+	strictCheck      bool
+	detailedOutput   bool
+	timeout          time.Duration
+	maxLatency       time.Duration
+	concurrent       int
+	concurrentHTTP   int
+	concurrentSOCKS5 int
+	testURL          string
+	resume           bool
+	revalidateFirst  bool
+
+	inputs    stringList
+	inputType string
+
+	progress string
+	tui      bool
+}
+
+// newOptions returns the flags shared by all subcommands of the given name
+func newOptions(name string) *options {
+	o := &options{flags: flag.NewFlagSet(name, flag.ExitOnError)}
+	o.flags.StringVar(&o.configPath, "config", "config.yaml", "Path of the configuration file")
+	o.flags.StringVar(&o.profile, "profile", "", "Profile of the configuration file applied over its scraper and checker settings")
+	o.flags.StringVar(&o.logLevel, "log-level", "", "Log level: debug, info, warn or error (overrides log.level)")
+	o.flags.StringVar(&o.outDir, "out-dir", "", "Directory of the output files (overrides output.dir)")
+	o.flags.BoolVar(&quiet, "quiet", false, "Print only errors and warnings")
+	return o
+}
+
+// addScrapeFlags registers the flags of scraping
+func (o *options) addScrapeFlags() {
+	o.flags.StringVar(&o.sourcesDir, "sources-dir", "", "Directory of the source lists (overrides scraper.sources_dir)")
+	o.flags.BoolVar(&o.dryRun, "dry-run", false, "Check the configuration and that the sources can be reached, print the plan and exit")
+}
+
+// addCheckFlags registers the flags of checking
+func (o *options) addCheckFlags() {
+	o.flags.BoolVar(&o.strictCheck, "strict", false, "Enable strict proxy checking")
+	o.flags.BoolVar(&o.detailedOutput, "detailed", false, "Show detailed checking results")
+	o.flags.DurationVar(&o.timeout, "timeout", 0, "Timeout of a proxy check, e.g. 5s (overrides checker.timeout)")
+	o.flags.DurationVar(&o.maxLatency, "max-latency", 0, "Latency above which proxies are not working, e.g. 1s (overrides checker.max_latency)")
+	o.flags.IntVar(&o.concurrent, "concurrent", 0, "Concurrent proxy checks (overrides checker.concurrent)")
+	o.flags.IntVar(&o.concurrentHTTP, "concurrent-http", 0, "Concurrent HTTP proxy checks (overrides checker.concurrent_http)")
+	o.flags.IntVar(&o.concurrentSOCKS5, "concurrent-socks5", 0, "Concurrent SOCKS5 proxy checks (overrides checker.concurrent_socks5)")
+	o.flags.StringVar(&o.testURL, "test-url", "", "URL requested through proxies by basic checks (overrides checker.test_url)")
+	o.flags.BoolVar(&o.resume, "resume", false, "Resume an interrupted run from its checkpoint instead of scraping again")
+}
+
+// addInputFlags registers the flags of user-provided proxy lists
+func (o *options) addInputFlags() {
+	o.flags.Var(&o.inputs, "input", "File of proxies to check, - for stdin; may be repeated")
+	o.flags.StringVar(&o.inputType, "input-type", "auto", "Protocol of input proxies without a scheme: http, socks5 or auto")
+}
+
+// addProgressFlags registers the flags choosing how progress is shown
+func (o *options) addProgressFlags() {
+	o.flags.StringVar(&o.progress, "progress", "bar", "Progress output: bar, or json for JSON events on stderr")
+	o.flags.BoolVar(&o.tui, "tui", false, "Show a full-screen dashboard instead of progress lines")
+}
+
+// parse parses the command line arguments. It reports false if they are
+// invalid, after printing the reason.
+func (o *options) parse(args []string) bool {
+	o.flags.Parse(args)
+	if o.progress != "" && o.progress != "bar" && o.progress != "json" {
+		fmt.Printf("❌ Invalid --progress %q, must be bar or json\n", o.progress)
+		return false
+	}
+	if o.progress == "json" && o.tui {
+		fmt.Println("❌ --progress=json cannot be combined with --tui")
+		return false
+	}
+	return true
+}
+
+// loadConfig loads the configuration. The profile selected with --profile
+// takes precedence over the rest of the configuration file, PSC_
+// environment variables over the file, and flags given on the command line
+// over all of them.
+func (o *options) loadConfig() (*src.Config, error) {
+	return src.LoadConfig(o.configPath, src.ApplyProfile(o.profile), src.ApplyEnv, func(config *src.Config) error {
+		o.flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "strict":
+				config.Checker.StrictCheck = o.strictCheck
+			case "detailed":
+				config.Checker.DetailedOutput = o.detailedOutput
+			case "log-level":
+				config.Log.Level = o.logLevel
+			case "out-dir":
+				config.Output.Dir = o.outDir
+			case "sources-dir":
+				config.Scraper.SourcesDir = o.sourcesDir
+			case "timeout":
+				config.Checker.Timeout = o.timeout
+			case "max-latency":
+				config.Checker.MaxLatency = o.maxLatency
+			case "concurrent":
+				config.Checker.Concurrent = o.concurrent
+			case "concurrent-http":
+				config.Checker.ConcurrentHTTP = o.concurrentHTTP
+			case "concurrent-socks5":
+				config.Checker.ConcurrentSOCKS5 = o.concurrentSOCKS5
+			case "test-url":
+				config.Checker.TestURL = o.testURL
+			}
+		})
+		return nil
+	})
+}
+
+// session holds what a subcommand runs with: the configuration, a context
+// cancelled on SIGINT/SIGTERM and the dashboard, if shown
+type session struct {
+	config    *src.Config
+	ctx       context.Context
+	dashboard *src.Dashboard
+	cleanups  []func()
+}
+
+// start loads the configuration and sets up logging, the output directory,
+// signal handling and the progress display. It reports false if the
+// subcommand must stop, after printing the reason.
+func (o *options) start() (*session, bool) {
+	config, err := o.loadConfig()
+	if err != nil {
+		fmt.Printf("❌ Error loading config: %v\n", err)
+		return nil, false
+	}
+
+	// Set up logging to file
+	logFile, err := src.SetupLogger(config.Log)
+	if err != nil {
+		fmt.Printf("❌ Error setting up logging: %v\n", err)
+		return nil, false
+	}
+	s := &session{config: config}
+	s.cleanups = append(s.cleanups, func() { logFile.Close() })
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(config.Output.Dir, 0755); err != nil {
+		slog.Error("Error creating output directory", "error", err)
+		s.Close()
+		return nil, false
+	}
+
+	// Stop gracefully on SIGINT/SIGTERM; a second signal exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	s.ctx = ctx
+	s.cleanups = append(s.cleanups, stop)
+	go func() {
+		<-ctx.Done()
+		stop()
 	}()
 
-	checker.CheckProxies(httpProxies, socks5Proxies)
-	fmt.Println("\n✨ Proxy scraping and checking completed")
-}
\ No newline at end of file
+	// Pick how the progress is shown
+	switch {
+	case o.progress == "json":
+		src.SetDisplay(src.NewJSONDisplay(os.Stderr))
+	case quiet && !o.tui:
+		src.SetDisplay(src.QuietDisplay{})
+	}
+
+	// Show the dashboard, which captures the messages printed from now on
+	// and prints them again once closed
+	if o.tui {
+		dashboard, err := src.NewDashboard(os.Stdout)
+		if err == nil {
+			err = dashboard.Start()
+		}
+		if err != nil {
+			fmt.Printf("⚠️ Cannot show the dashboard: %v\n", err)
+		} else {
+			src.SetDisplay(dashboard)
+			s.dashboard = dashboard
+			s.cleanups = append(s.cleanups, dashboard.Stop)
+		}
+	}
+	return s, true
+}
+
+// Close undoes what start set up, in reverse order
+func (s *session) Close() {
+	for i := len(s.cleanups) - 1; i >= 0; i-- {
+		s.cleanups[i]()
+	}
+}
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+// String returns the values joined by commas
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds a value
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package proxycheck exposes the proxy scraping and checking pipeline as a
+// library so it can be embedded in other Go programs.
+//
+// Unlike the command line tool, nothing in this package writes output files
+// or prints progress: results are returned to the caller.
+package proxycheck
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"ProxyScraperChecker/src"
+)
+
+// Config is the scraper and checker configuration
+type Config = src.Config
+
+// ProxyType identifies the protocol spoken by a proxy
+type ProxyType = src.ProxyType
+
+// Result is the outcome of checking a single proxy
+type Result = src.CheckResult
+
+// Location contains geolocation information of a proxy exit IP
+type Location = src.ProxyLocation
+
+// GeoIP resolves IP locations offline from a MaxMind GeoLite2 database
+type GeoIP = src.GeoIP
+
+// ASNDatabase resolves the autonomous system of IPs offline from a MaxMind
+// GeoLite2-ASN database
+type ASNDatabase = src.ASNDatabase
+
+// Supported proxy types. The protocol of an Auto proxy is detected by
+// trying HTTP, HTTPS, SOCKS4 and SOCKS5 in turn, and the result has the
+// detected type.
+const (
+	HTTP   = src.ProxyTypeHTTP
+	HTTPS  = src.ProxyTypeHTTPS
+	SOCKS4 = src.ProxyTypeSOCKS4
+	SOCKS5 = src.ProxyTypeSOCKS5
+	Auto   = src.ProxyTypeAuto
+)
+
+// Proxy is a single proxy endpoint to be checked
+type Proxy struct {
+	Addr string // IP:PORT
+	Type ProxyType
+}
+
+// DefaultConfig returns a configuration with all default values applied
+func DefaultConfig() *Config {
+	return src.DefaultConfig()
+}
+
+// Checker checks proxies without any file or console side effects
+type Checker struct {
+	config  *Config
+	checker *src.ProxyChecker
+}
+
+// New creates a Checker. A nil config is replaced by DefaultConfig(), unset
+// fields of a non-nil config are filled with their defaults. It returns an
+// error, naming the setting, if the configuration is invalid.
+func New(config *Config) (*Checker, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	config.SetDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Checker{
+		config:  config,
+		checker: src.NewProxyChecker(config),
+	}, nil
+}
+
+// OpenGeoIP opens a GeoLite2-City or GeoLite2-Country mmdb file
+func OpenGeoIP(path string) (*GeoIP, error) {
+	return src.OpenGeoIP(path)
+}
+
+// SetGeoIP makes strict checks resolve locations from a local database
+// instead of ip-api.com
+func (c *Checker) SetGeoIP(geo *GeoIP) {
+	c.checker.GeoIP = geo
+}
+
+// OpenASNDatabase opens a GeoLite2-ASN mmdb file
+func OpenASNDatabase(path string) (*ASNDatabase, error) {
+	return src.OpenASNDatabase(path)
+}
+
+// SetASNDatabase makes strict checks resolve the autonomous system of exit
+// IPs, which sets their network class, from a local database instead of
+// the IP lookup service
+func (c *Checker) SetASNDatabase(asn *ASNDatabase) {
+	c.checker.ASN = asn
+}
+
+// Check checks a single proxy. The check is aborted when ctx is done.
+func (c *Checker) Check(ctx context.Context, p Proxy) Result {
+	return c.checker.Check(ctx, p.Addr, p.Type)
+}
+
+// CheckAll checks proxies concurrently, honoring the per-type concurrency
+// limits of the configuration, and returns the results in input order.
+// HTTPS and SOCKS4 proxies, only found by protocol detection on the command
+// line, share checker.concurrent_auto with Auto proxies.
+// Proxies that were not checked because ctx was cancelled are reported as
+// not working.
+func (c *Checker) CheckAll(ctx context.Context, proxies []Proxy) []Result {
+	results := make([]Result, len(proxies))
+	semHTTP := make(chan struct{}, c.config.Checker.ConcurrentHTTP)
+	semSOCKS5 := make(chan struct{}, c.config.Checker.ConcurrentSOCKS5)
+	semAuto := make(chan struct{}, c.config.Checker.ConcurrentAuto)
+
+	var wg sync.WaitGroup
+	for i, p := range proxies {
+		sem := semHTTP
+		switch p.Type {
+		case SOCKS5:
+			sem = semSOCKS5
+		case HTTPS, SOCKS4, Auto:
+			sem = semAuto
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			for j := i; j < len(proxies); j++ {
+				results[j] = Result{Proxy: proxies[j].Addr, Type: proxies[j].Type}
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, p Proxy) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = c.Check(ctx, p)
+		}(i, p)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// Scrape fetches all source URLs and returns the deduplicated proxies found
+// in their responses. Sources that fail to load are skipped. Like lines of
+// the sources files, a URL may be followed by pagination and parser options,
+// see src.ParseSource. Invalid entries are skipped too.
+func Scrape(ctx context.Context, config *Config, urls []string) []string {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	config.SetDefaults()
+	client := &http.Client{Timeout: config.Scraper.Timeout}
+	sem := make(chan struct{}, config.Scraper.Concurrent)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var proxies []string
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			source, err := src.ParseSource(url)
+			if err != nil {
+				return
+			}
+			userAgent := config.Scraper.UserAgents[i%len(config.Scraper.UserAgents)]
+			found, err := src.ScrapeSource(ctx, client, source, userAgent)
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			proxies = append(proxies, found...)
+			mu.Unlock()
+		}(i, url)
+	}
+
+	wg.Wait()
+	return src.RemoveDuplicates(proxies)
+}
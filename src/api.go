@@ -0,0 +1,347 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// APIServer exposes the proxy pool and checking statistics over HTTP/JSON
+type APIServer struct {
+	config  *Config
+	pool    *Pool
+	checker atomic.Pointer[ProxyChecker]
+	mux     *http.ServeMux
+}
+
+// StatsResponse is returned by the /stats endpoint
+type StatsResponse struct {
+	Pool      int            `json:"pool"`
+	ByType    map[string]int `json:"by_type"`
+	ByCountry map[string]int `json:"by_country"`
+	Progress  *Progress      `json:"progress,omitempty"`
+}
+
+// NewAPIServer creates an API server for the pool. checker may be nil when
+// no check run is in progress.
+func NewAPIServer(config *Config, pool *Pool, checker *ProxyChecker) *APIServer {
+	s := &APIServer{
+		config: config,
+		pool:   pool,
+		mux:    http.NewServeMux(),
+	}
+	s.checker.Store(checker)
+	s.mux.HandleFunc("/proxies", s.handleProxies)
+	s.mux.HandleFunc("/stats", s.handleStats)
+	s.mux.HandleFunc("/events", s.handleEvents)
+	return s
+}
+
+// SetChecker replaces the checker whose progress is reported, e.g. when a
+// daemon starts a new check
+func (s *APIServer) SetChecker(checker *ProxyChecker) {
+	s.checker.Store(checker)
+}
+
+// ListenAndServe serves the API on config.API.Listen until ctx is done
+func (s *APIServer) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.config.API.Listen,
+		Handler:           s.mux,
+		ReadHeaderTimeout: serverHeaderTimeout,
+		IdleTimeout:       serverIdleTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP implements http.Handler
+func (s *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleProxies lists pooled proxies matching the query filters:
+// type, country, max_latency, anonymous, anonymity, network, target,
+// max_risk and limit
+func (s *APIServer) handleProxies(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var maxLatency time.Duration
+	if v := query.Get("max_latency"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid max_latency: "+err.Error())
+			return
+		}
+		maxLatency = d
+	}
+
+	var anonymous *bool
+	if v := query.Get("anonymous"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid anonymous: "+err.Error())
+			return
+		}
+		anonymous = &b
+	}
+
+	var cfFriendly *bool
+	if v := query.Get("cf_friendly"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid cf_friendly: "+err.Error())
+			return
+		}
+		cfFriendly = &b
+	}
+
+	var maxRisk *int
+	if v := query.Get("max_risk"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid max_risk")
+			return
+		}
+		maxRisk = &n
+	}
+
+	minLevel, err := ParseAnonymityLevel(query.Get("anonymity"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid anonymity: "+err.Error())
+		return
+	}
+
+	network, err := ParseNetworkClass(query.Get("network"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid network: "+err.Error())
+		return
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	results := poolQuery{
+		proxyType:  query.Get("type"),
+		country:    query.Get("country"),
+		maxLatency: maxLatency,
+		anonymous:  anonymous,
+		minLevel:   minLevel,
+		network:    network,
+		target:     query.Get("target"),
+		search:     query.Get("search"),
+		cfFriendly: cfFriendly,
+		maxRisk:    maxRisk,
+		limit:      limit,
+	}.selectFrom(s.pool)
+
+	records := make([]ProxyRecord, 0, len(results))
+	for _, result := range results {
+		records = append(records, NewProxyRecord(result))
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// poolQuery selects pooled proxies by type, country, maximum latency,
+// anonymity, network class, passed target and search engine, Cloudflare
+// friendliness and maximum risk score. Zero values match any proxy.
+type poolQuery struct {
+	proxyType  string
+	country    string
+	maxLatency time.Duration
+	anonymous  *bool
+	minLevel   AnonymityLevel
+	network    NetworkClass
+	target     string
+	search     string
+	cfFriendly *bool
+	maxRisk    *int
+	limit      int // Maximum number of proxies selected, 0 for all
+}
+
+// selectFrom returns the proxies of pool matching q, fastest first
+func (q poolQuery) selectFrom(pool *Pool) []CheckResult {
+	results := pool.List()
+	sort.Slice(results, func(i, j int) bool { return results[i].Speed < results[j].Speed })
+
+	selected := results[:0]
+	for _, result := range results {
+		if q.proxyType != "" && !strings.EqualFold(result.Type.String(), q.proxyType) {
+			continue
+		}
+		if q.country != "" && (result.Location == nil || !strings.EqualFold(result.Location.CountryCode, q.country)) {
+			continue
+		}
+		if q.maxLatency > 0 && result.Speed > q.maxLatency {
+			continue
+		}
+		if q.anonymous != nil && result.Anonymous != *q.anonymous {
+			continue
+		}
+		if result.Anonymity < q.minLevel {
+			continue
+		}
+		if q.network != NetworkUnknown && result.Network != q.network {
+			continue
+		}
+		if q.target != "" && !slices.Contains(result.Targets, q.target) {
+			continue
+		}
+		if q.search != "" && !slices.Contains(result.SearchPassed, q.search) {
+			continue
+		}
+		if q.cfFriendly != nil && result.CFFriendly != *q.cfFriendly {
+			continue
+		}
+		if q.maxRisk != nil && result.Risk > *q.maxRisk {
+			continue
+		}
+		selected = append(selected, result)
+		if q.limit > 0 && len(selected) == q.limit {
+			break
+		}
+	}
+	return selected
+}
+
+// handleStats returns pool counts and the current checking progress
+func (s *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := StatsResponse{
+		ByType:    make(map[string]int),
+		ByCountry: make(map[string]int),
+	}
+	for _, result := range s.pool.List() {
+		stats.Pool++
+		stats.ByType[result.Type.String()]++
+		if result.Location != nil && result.Location.CountryCode != "" {
+			stats.ByCountry[result.Location.CountryCode]++
+		}
+	}
+	if checker := s.checker.Load(); checker != nil {
+		progress := checker.Progress()
+		stats.Progress = &progress
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ResultEvent is a check result streamed by the /events endpoint
+type ResultEvent struct {
+	ProxyRecord
+	Working bool `json:"working"`
+}
+
+const (
+	eventBuffer    = 256              // Results buffered per /events client before dropping them
+	eventKeepAlive = 15 * time.Second // Interval of the comments keeping idle /events streams open
+)
+
+// handleEvents streams check results as Server-Sent Events as soon as they
+// are added to the pool, and the checking progress once a second when it
+// has changed. With working=true or false, only working or failed results
+// are streamed.
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var working *bool
+	if v := r.URL.Query().Get("working"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid working: "+err.Error())
+			return
+		}
+		working = &b
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	results, cancel := s.pool.Subscribe(eventBuffer)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disables buffering by nginx
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var lastProgress Progress
+	progress := time.NewTicker(time.Second)
+	defer progress.Stop()
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case result := <-results:
+			if working != nil && result.Working != *working {
+				continue
+			}
+			err = writeEvent(w, "result", ResultEvent{ProxyRecord: NewProxyRecord(result), Working: result.Working})
+		case <-progress.C:
+			checker := s.checker.Load()
+			if checker == nil {
+				continue
+			}
+			current := checker.Progress()
+			if reflect.DeepEqual(current, lastProgress) {
+				continue
+			}
+			lastProgress = current
+			err = writeEvent(w, "progress", current)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes v as a Server-Sent Event of the given type
+func writeEvent(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error message as a JSON response
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
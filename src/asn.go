@@ -0,0 +1,110 @@
+package src
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// datacenterASNList is the bundled list of hosting provider AS numbers
+//
+//go:embed datacenter_asns.txt
+var datacenterASNList string
+
+// datacenterASNs holds the AS numbers of datacenterASNList
+var datacenterASNs = parseASNList(datacenterASNList)
+
+// parseASNList reads AS numbers, one per line at the start of the line.
+// Blank lines and lines starting with # are skipped.
+func parseASNList(list string) map[uint]bool {
+	asns := make(map[uint]bool)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if asn, _ := parseAS(line); asn != 0 {
+			asns[asn] = true
+		}
+	}
+	return asns
+}
+
+// parseAS splits strings such as "AS15169 Google LLC", "AS15169" or
+// "15169" into the AS number and the organization name following it. The
+// number is 0 if s does not start with one.
+func parseAS(s string) (uint, string) {
+	field, name, _ := strings.Cut(strings.TrimSpace(s), " ")
+	if len(field) > 2 && strings.EqualFold(field[:2], "AS") {
+		field = field[2:]
+	}
+	asn, err := strconv.ParseUint(field, 10, 32)
+	if err != nil {
+		return 0, ""
+	}
+	return uint(asn), strings.TrimSpace(name)
+}
+
+// ClassifyASN returns the network class of an autonomous system:
+// datacenter if it belongs to a hosting provider of the bundled list,
+// residential otherwise. AS number 0 is unknown.
+func ClassifyASN(asn uint) NetworkClass {
+	switch {
+	case asn == 0:
+		return NetworkUnknown
+	case datacenterASNs[asn]:
+		return NetworkDatacenter
+	default:
+		return NetworkResidential
+	}
+}
+
+// ASNDatabase resolves the autonomous system of IPs offline from a MaxMind
+// GeoLite2-ASN database
+type ASNDatabase struct {
+	reader *maxminddb.Reader
+}
+
+// asnRecord holds the fields read from GeoLite2-ASN databases
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// OpenASNDatabase opens a GeoLite2-ASN mmdb file
+func OpenASNDatabase(path string) (*ASNDatabase, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ASNDatabase{reader: reader}, nil
+}
+
+// Lookup returns the number and organization of the AS announcing an IP
+// address
+func (a *ASNDatabase) Lookup(ipStr string) (uint, string, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return 0, "", fmt.Errorf("invalid IP address %q", ipStr)
+	}
+
+	var record asnRecord
+	if err := a.reader.Lookup(ip, &record); err != nil {
+		return 0, "", err
+	}
+	if record.Number == 0 {
+		return 0, "", fmt.Errorf("no AS found for %s", ipStr)
+	}
+	return record.Number, record.Organization, nil
+}
+
+// Close closes the underlying database
+func (a *ASNDatabase) Close() error {
+	return a.reader.Close()
+}
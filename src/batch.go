@@ -0,0 +1,86 @@
+package src
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batchGate schedules the checks of all lists in batches of
+// checker.batch_size proxies: a batch starts once every check of the
+// previous one is done and checker.batch_pause has passed. All methods are
+// safe to call on a nil *batchGate, which lets every proxy through.
+type batchGate struct {
+	size  int
+	pause time.Duration
+
+	mu       sync.Mutex
+	admitted int            // Proxies let through in the current batch
+	pending  sync.WaitGroup // Checks of the current batch not done yet
+	closing  bool           // The current batch is full and waits to end
+	next     chan struct{}  // Closed when the next batch starts
+}
+
+// newBatchGate returns the gate configured by checker.batch_size, nil if
+// it is 0
+func newBatchGate(config *Config) *batchGate {
+	if config.Checker.BatchSize <= 0 {
+		return nil
+	}
+	return &batchGate{size: config.Checker.BatchSize, pause: config.Checker.BatchPause, next: make(chan struct{})}
+}
+
+// enter waits until a proxy can be checked in the current batch, and
+// reports false if ctx was cancelled first. done must be called once the
+// check of an admitted proxy ends.
+func (g *batchGate) enter(ctx context.Context) bool {
+	if g == nil {
+		return true
+	}
+	for {
+		g.mu.Lock()
+		if g.admitted < g.size {
+			g.admitted++
+			g.pending.Add(1)
+			g.mu.Unlock()
+			return true
+		}
+		next := g.next
+		if !g.closing {
+			g.closing = true
+			go g.startNext(ctx)
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-next:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// done records the end of the check of an admitted proxy
+func (g *batchGate) done() {
+	if g != nil {
+		g.pending.Done()
+	}
+}
+
+// startNext starts the next batch once the checks of the current one are
+// done and the pause has passed
+func (g *batchGate) startNext(ctx context.Context) {
+	g.pending.Wait()
+	select {
+	case <-time.After(g.pause):
+	case <-ctx.Done():
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.admitted = 0
+	g.closing = false
+	close(g.next)
+	g.next = make(chan struct{})
+}
@@ -0,0 +1,93 @@
+package src
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+)
+
+// probeCapabilities records whether a working proxy keeps connections
+// alive and whether tunnels through it negotiate HTTP/2 with the origin:
+// checker.test_url is requested twice on one connection, then
+// checker.http2_check_url once with HTTP/2 enabled.
+func (c *ProxyChecker) probeCapabilities(ctx context.Context, client *http.Client, result *CheckResult) {
+	if !c.config.Checker.CapabilityCheck {
+		return
+	}
+	base, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+
+	transport := base.Clone()
+	transport.DisableKeepAlives = false
+	probe := &http.Client{Transport: transport, Timeout: client.Timeout}
+	for i := 0; i < 2; i++ {
+		reused, err := c.fetchReused(ctx, probe, c.config.Checker.TestURL)
+		if err != nil {
+			slog.Debug("Keep-alive probe failed", "proxy", result.Proxy, "error", err)
+			break
+		}
+		result.KeepAlive = reused
+	}
+	transport.CloseIdleConnections()
+
+	// Transports with a custom dialer only negotiate HTTP/2 when forced to
+	transport = base.Clone()
+	transport.ForceAttemptHTTP2 = true
+	defer transport.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Checker.HTTP2CheckURL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	resp, err := c.do(&http.Client{Transport: transport, Timeout: client.Timeout}, req)
+	if err != nil {
+		slog.Debug("HTTP/2 probe failed", "proxy", result.Proxy, "error", err)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeBody))
+	resp.Body.Close()
+	result.HTTP2 = resp.ProtoMajor == 2
+}
+
+// fetchReused requests url with client, reads the whole response and
+// reports whether it was sent on a connection kept alive from a previous
+// request
+func (c *ProxyChecker) fetchReused(ctx context.Context, client *http.Client, url string) (bool, error) {
+	var reused bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	resp, err := c.do(client, req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	// A connection is only reused once its response was read to the end
+	if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeBody)); err != nil {
+		return false, err
+	}
+	return reused, nil
+}
+
+// capabilities returns the capability flags of a result for detailed
+// output, e.g. "keep-alive,h2"
+func capabilities(result CheckResult) string {
+	var flags []string
+	if result.KeepAlive {
+		flags = append(flags, "keep-alive")
+	}
+	if result.HTTP2 {
+		flags = append(flags, "h2")
+	}
+	return strings.Join(flags, ",")
+}
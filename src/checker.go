@@ -2,19 +2,22 @@ package src
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"golang.org/x/net/proxy"
+	"ProxyScraperChecker/src/store"
 )
 
 // ProxyLocation contains geolocation information
@@ -23,17 +26,49 @@ type ProxyLocation struct {
 	CountryCode string `json:"countryCode"`
 	City        string `json:"city"`
 	Region      string `json:"regionName"`
+	ASN         uint   `json:"asn,omitempty"`   // Autonomous system announcing the exit IP
+	ASOrg       string `json:"asOrg,omitempty"` // Organization owning the autonomous system
+	ISP         string `json:"isp,omitempty"`
 }
 
 // CheckResult represents the result of a proxy check
 type CheckResult struct {
-	Proxy     string
-	Working   bool
-	Type      ProxyType
-	ProxyIP   string
-	Speed     time.Duration
-	Anonymous bool
-	Location  *ProxyLocation
+	Proxy        string
+	Working      bool
+	Type         ProxyType
+	ProxyIP      string
+	Speed        time.Duration
+	Anonymous    bool
+	Anonymity    AnonymityLevel
+	RealIPLeak   bool              // The judge saw the public IP of this machine, requires strict_check
+	Network      NetworkClass      // Datacenter or residential, from the AS of the exit IP
+	Headers      map[string]string // Headers of checker.capture_headers seen through the proxy, by canonical name
+	Location     *ProxyLocation
+	ConnectTime  time.Duration // Time to establish the connection through the proxy
+	TTFB         time.Duration // Time to first response byte of the latency probe
+	TotalTime    time.Duration // Total time of the latency probe request
+	Stability    float64       // Percentage of checks passed across runs, requires a history store
+	Streak       int           // Consecutive runs passed, the current one included, requires a history store
+	Score        float64       // Composite score of a working proxy, weighted by the score settings
+	Throughput   float64       // Download speed through the proxy in KB/s, requires a bandwidth test
+	Targets      []string      // Names of the checker.targets the proxy passed
+	SearchPassed []string      // Names of the checker.search_engines answering without a captcha, requires checker.search_check
+	SharedExit   string        // First working proxy seen with the same exit IP, with checker.exit_ip_dedup: annotate
+	Blocklists   []string      // Blocklists listing the exit IP, requires reputation checking
+	TorExit      bool          // Exit IP is a Tor exit node, requires checker.tor_exits
+	Risk         int           // Risk score from 0 to 100, the sum of the weights of RiskFlags, requires risk.enabled
+	RiskFlags    []string      // Heuristics flagging the proxy as a possible honeypot, see RiskPorts
+	TLS          bool          // An https:// request through the proxy succeeded, requires checker.tls_check
+	MITM         bool          // The proxy intercepts TLS with its own certificates, requires checker.tls_check
+	Clean        bool          // The test resource came through unmodified, requires checker.content_check
+	UDP          bool          // The SOCKS5 proxy relays UDP, requires checker.udp_check
+	DNS          string        // Where the SOCKS5 proxy resolves hostnames: remote or local, empty if unknown
+	KeepAlive    bool          // Connections through the proxy are kept alive, requires checker.capability_check
+	HTTP2        bool          // Tunnels through the proxy negotiate HTTP/2, requires checker.capability_check
+	CFFriendly   bool          // A site fronted by Cloudflare answers without a block or challenge, requires checker.cloudflare_check
+	Vantages     []string      // Names of the checker.vantage_points the proxy works from
+	CheckedAt    time.Time     // Time the check ended
+	ExpiresAt    time.Time     // Time after which the result should be checked again, from output.ttl
 }
 
 // ProxyInfo contains detailed information about a proxy
@@ -47,242 +82,1118 @@ type ProxyInfo struct {
 
 // ProxyChecker handles the checking of proxies
 type ProxyChecker struct {
-	config      *Config
-	httpClient  *http.Client
-	ResultChan  chan CheckResult
-	progressMu  sync.Mutex
-	checkedHTTP int
+	config        *Config
+	httpClient    *http.Client
+	ResultChan    chan CheckResult // Optional, receives the results of CheckProxies and is closed when it returns
+	GeoIP         *GeoIP           // Optional offline geolocation, replaces IP lookup requests
+	ASN           *ASNDatabase     // Optional offline AS lookup, used for network classes
+	Reputation    *Reputation      // Optional blocklists exit IPs are checked against
+	TorExits      *TorExits        // Optional Tor exit list exit IPs are checked against
+	Store         *store.Store     // Optional check history, enables stability scores
+	Checkpoint    *Checkpoint      // Optional, records checked proxies so an interrupted run can resume
+	Revalidate    map[string]bool  // Optional previously working proxies, published as soon as all of them are checked
+	Workers       *RemoteWorkers   // Optional remote workers CheckProxies checks proxies on instead of locally
+	limiter       *RateLimiter     // Global limit of check requests, nil for no limit
+	sockets       *socketLimiter   // Global limit of open sockets, nil for no limit
+	hosts         *hostChecks      // Limit of checks of a host at once, nil for no limit
+	dns           *dnsCache        // Resolver of checker.resolver, caching the addresses of hostnames
+	judges        *judgePool       // Judges of checker.judge_urls that answer, nil outside strict mode
+	upstream      *url.URL         // Proxy check connections go through, nil to connect directly
+	vantages      []vantagePoint   // Local addresses of checker.vantage_points each proxy is checked from
+	searchEngines []searchEngine   // Search engines of checker.search_engines
+	steps         []CheckStep      // Steps of checker.steps run on every proxy
+	honeypotASNs  map[uint]bool    // AS numbers of risk.honeypot_asns
+	nextJudge     atomic.Uint32
+	progressMu    sync.Mutex
+	checkedHTTP   int
 	checkedSOCKS5 int
-	workingHTTP int
+	workingHTTP   int
 	workingSOCKS5 int
-	totalHTTP int
-	totalSOCKS5 int
+	totalHTTP     int
+	totalSOCKS5   int
+	checkedAuto   int
+	workingAuto   int
+	totalAuto     int
+	detected      map[ProxyType]int // Working auto proxies by detected type
+	realIPOnce    sync.Once
+	realIPs       []net.IP // Public IPs of this machine, detected on first use
+	contentOnce   sync.Once
+	content       *contentReference // Test resource of the content check, fetched on first use
+	contentErr    error
 }
 
 // NewProxyChecker creates a new ProxyChecker instance
 func NewProxyChecker(config *Config) *ProxyChecker {
-	return &ProxyChecker{
-		config:     config,
-		ResultChan: make(chan CheckResult, 100),
+	c := &ProxyChecker{
+		config:   config,
+		detected: make(map[ProxyType]int),
+		sockets:  newSocketLimiter(config.Checker.MaxOpenSockets),
+		hosts:    newHostChecks(config.Checker.MaxChecksPerHost),
+	}
+	// checker.upstream_proxy was validated with the configuration
+	c.upstream, _ = CheckerUpstream(config)
+	c.vantages, _ = resolveVantagePoints(config.Checker.VantagePoints)
+	c.searchEngines, _ = compileSearchEngines(config.Checker.SearchEngines)
+	c.honeypotASNs, _ = loadASNList(config.Risk.HoneypotASNs)
+	resolver, _ := newResolver(config.Checker.Resolver, config.Checker.ConnectTimeout)
+	c.dns = newDNSCache(resolver, config.Checker.DNSCacheTTL, config.Checker.ConnectTimeout)
+	c.steps = c.newCheckSteps()
+	c.judges = newJudgePool(config)
+	if config.Checker.MaxRequestsPerSecond > 0 {
+		c.limiter = NewRateLimiter(config.Checker.MaxRequestsPerSecond)
+	}
+	return c
+}
+
+// dialer returns the dialer of check connections: d, from the vantage
+// point of the check, through checker.upstream_proxy if set, resolving
+// hostnames with the DNS cache, once a socket is free
+func (c *ProxyChecker) dialer(d *net.Dialer) contextDialer {
+	var dialer contextDialer = d
+	if len(c.vantages) > 0 {
+		dialer = vantageDialer{d}
+	}
+	return newUpstreamDialer(c.dns.dialer(c.sockets.dialer(dialer)), c.upstream, c.config.Checker.ConnectTimeout)
+}
+
+// do sends a check request once the global rate limit allows it. Waiting
+// for the limiter does not count towards the client timeout.
+func (c *ProxyChecker) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if err := c.limiter.Wait(req.Context()); err != nil {
+		return nil, err
 	}
+	return client.Do(req)
 }
 
 // formatProxyOutput formats proxy information for output
 func (c *ProxyChecker) formatProxyOutput(result CheckResult) string {
+	proxy := result.Proxy
+	if c.config.Output.URIScheme {
+		proxy = ProxyURI(result.Type, proxy)
+	}
 	if !c.config.Checker.StrictCheck || !c.config.Checker.DetailedOutput {
-		return result.Proxy
+		if c.config.Output.Timestamps {
+			return strings.Join(append([]string{proxy}, resultTimestamps(result)...), "|")
+		}
+		return proxy
 	}
 
-	// Format: proxy|ip|country|city|speed|anonymous
+	// Format: proxy|ip|location|speed|anonymity|network|as|isp[|stability|streak][|throughput][|targets][|shared exit][|blocklists][|tor][|checked at|expires at]
 	speed := result.Speed.Round(time.Millisecond).String()
-	anonymous := "No"
-	if result.Anonymous {
-		anonymous = "Yes"
-	}
 
 	location := "Unknown"
+	var as, isp string
 	if result.Location != nil {
 		if result.Location.City != "" {
 			location = fmt.Sprintf("%s, %s", result.Location.City, result.Location.Country)
-		} else {
+		} else if result.Location.Country != "" {
 			location = result.Location.Country
 		}
+		if result.Location.ASN != 0 {
+			as = strings.TrimSpace(fmt.Sprintf("AS%d %s", result.Location.ASN, result.Location.ASOrg))
+		}
+		isp = result.Location.ISP
 	}
 
-	return fmt.Sprintf("%s|%s|%s|%s|%s", 
-		result.Proxy,
+	fields := []string{
+		proxy,
 		result.ProxyIP,
 		location,
 		speed,
-		anonymous,
-	)
+		result.Anonymity.String(),
+		result.Network.String(),
+		as,
+		isp,
+	}
+	if c.Store != nil {
+		fields = append(fields, fmt.Sprintf("%.0f%%", result.Stability), strconv.Itoa(result.Streak))
+	}
+	if c.config.Checker.BandwidthURL != "" {
+		fields = append(fields, fmt.Sprintf("%.1f KB/s", result.Throughput))
+	}
+	if len(c.config.Checker.Targets) > 0 {
+		fields = append(fields, strings.Join(result.Targets, ","))
+	}
+	if c.config.Checker.ExitIPDedup == "annotate" {
+		fields = append(fields, result.SharedExit)
+	}
+	if c.tagsBlocklists() {
+		fields = append(fields, strings.Join(result.Blocklists, ","))
+	}
+	if c.flagsTorExits() {
+		tor := ""
+		if result.TorExit {
+			tor = "tor"
+		}
+		fields = append(fields, tor)
+	}
+	if c.config.Checker.TLSCheck == "flag" {
+		mitm := ""
+		if result.MITM {
+			mitm = "mitm"
+		}
+		fields = append(fields, mitm)
+	}
+	if c.config.Checker.ContentCheck == "flag" {
+		content := "tampered"
+		if result.Clean {
+			content = "clean"
+		}
+		fields = append(fields, content)
+	}
+	if c.config.Checker.UDPCheck {
+		udp := ""
+		if result.UDP {
+			udp = "udp"
+		}
+		fields = append(fields, udp)
+	}
+	if c.config.Checker.CapabilityCheck {
+		fields = append(fields, capabilities(result))
+	}
+	if c.config.Checker.CloudflareCheck {
+		cloudflare := ""
+		if result.CFFriendly {
+			cloudflare = "cf"
+		}
+		fields = append(fields, cloudflare)
+	}
+	if c.config.Checker.SearchCheck {
+		fields = append(fields, strings.Join(result.SearchPassed, ","))
+	}
+	if c.config.Risk.Enabled {
+		fields = append(fields, formatRisk(result))
+	}
+	if len(c.config.Checker.CaptureHeaders) > 0 {
+		fields = append(fields, formatHeaders(result.Headers))
+	}
+	if c.config.Output.Timestamps {
+		fields = append(fields, resultTimestamps(result)...)
+	}
+	return strings.Join(fields, "|")
+}
+
+// resultTimestamps returns the check and expiry times of a result in
+// RFC 3339 format, the latter empty without output.ttl
+func resultTimestamps(result CheckResult) []string {
+	expires := ""
+	if !result.ExpiresAt.IsZero() {
+		expires = result.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return []string{result.CheckedAt.UTC().Format(time.RFC3339), expires}
+}
+
+// detailedHeader returns the header line of detailed output, matching the
+// fields written by formatProxyOutput
+func (c *ProxyChecker) detailedHeader() string {
+	columns := []string{"Proxy", "IP", "Location", "Response Time", "Anonymity", "Network", "AS", "ISP"}
+	if c.Store != nil {
+		columns = append(columns, "Stability", "Streak")
+	}
+	if c.config.Checker.BandwidthURL != "" {
+		columns = append(columns, "Throughput")
+	}
+	if len(c.config.Checker.Targets) > 0 {
+		columns = append(columns, "Targets")
+	}
+	if c.config.Checker.ExitIPDedup == "annotate" {
+		columns = append(columns, "Shared Exit")
+	}
+	if c.tagsBlocklists() {
+		columns = append(columns, "Blocklists")
+	}
+	if c.flagsTorExits() {
+		columns = append(columns, "Tor")
+	}
+	if c.config.Checker.TLSCheck == "flag" {
+		columns = append(columns, "MITM")
+	}
+	if c.config.Checker.ContentCheck == "flag" {
+		columns = append(columns, "Content")
+	}
+	if c.config.Checker.UDPCheck {
+		columns = append(columns, "UDP")
+	}
+	if c.config.Checker.CapabilityCheck {
+		columns = append(columns, "Capabilities")
+	}
+	if c.config.Checker.CloudflareCheck {
+		columns = append(columns, "Cloudflare")
+	}
+	if c.config.Checker.SearchCheck {
+		columns = append(columns, "Search")
+	}
+	if c.config.Risk.Enabled {
+		columns = append(columns, "Risk")
+	}
+	if len(c.config.Checker.CaptureHeaders) > 0 {
+		columns = append(columns, "Headers")
+	}
+	if c.config.Output.Timestamps {
+		columns = append(columns, "Checked At", "Expires At")
+	}
+	return strings.Join(columns, "|")
+}
+
+// tagsBlocklists reports whether proxies listed by the reputation
+// blocklists are kept and tagged with them
+func (c *ProxyChecker) tagsBlocklists() bool {
+	return c.Reputation != nil && c.config.Reputation.Action == "tag"
+}
+
+// flagsTorExits reports whether proxies exiting through Tor are kept and
+// flagged
+func (c *ProxyChecker) flagsTorExits() bool {
+	return c.TorExits != nil && c.config.Checker.TorExits == "flag"
 }
 
-// CheckProxies checks a list of proxies concurrently
-func (c *ProxyChecker) CheckProxies(httpProxies, socks5Proxies []string) {
+// CheckProxies checks lists of HTTP, SOCKS5 and unknown protocol proxies
+// concurrently. The protocol of autoProxies is detected by trying each one
+// of DetectOrder. Working proxies are saved to <output dir>/<type>.txt. When ctx is
+// cancelled no new checks are started, but checks already in flight are
+// allowed to finish and their results are saved before CheckProxies returns.
+// If proxies are left unchecked otherwise, as when no remote worker is left,
+// the run is handled as an interrupted one and the error is returned.
+func (c *ProxyChecker) CheckProxies(ctx context.Context, httpProxies, socks5Proxies, autoProxies []string) error {
+	c.progressMu.Lock()
 	c.totalHTTP = len(httpProxies)
 	c.totalSOCKS5 = len(socks5Proxies)
-	
+	c.totalAuto = len(autoProxies)
+	c.progressMu.Unlock()
+
 	var wg sync.WaitGroup
-	semHTTP := make(chan struct{}, c.config.Checker.ConcurrentHTTP)
-	semSOCKS5 := make(chan struct{}, c.config.Checker.ConcurrentSOCKS5)
 
-	// Create mutex for file writing
-	var httpMu sync.Mutex
-	var socks5Mu sync.Mutex
+	// Create a mutex per output file
+	fileLocks := make(map[ProxyType]*sync.Mutex, len(DetectOrder))
+	for _, proxyType := range DetectOrder {
+		fileLocks[proxyType] = &sync.Mutex{}
+	}
+
+	// A resumed run appends to the output files of the interrupted one
+	resumed := c.Checkpoint.Resumed()
+	started := time.Now()
+
+	// With output.write_mode: atomic, the files of the run are written to a
+	// staging directory, and replace those of the previous run once
+	// checking ends
+	dir := c.config.Output.Dir
+	staged := c.config.Output.WriteMode == "atomic"
+	if staged {
+		var err error
+		if dir, err = openStaging(c.config.Output.Dir, resumed); err != nil {
+			slog.Error("Error preparing staging directory", "error", err)
+		}
+	}
 
-	// Write headers if detailed output is enabled
-	if c.config.Checker.StrictCheck && c.config.Checker.DetailedOutput {
-		header := "Proxy|IP|Location|Response Time|Anonymous"
-		if err := WriteFile(filepath.Join("out", "http.txt"), header); err != nil {
-			log.Printf("Error writing HTTP header: %v", err)
+	// Group working proxies by exit IP, and record them for sorting. Once
+	// all output files are closed, proxies replaced by a faster one with the
+	// same exit IP are removed, the files are sorted and published, and
+	// client configurations are rendered from them.
+	exits := newExitTracker(c.config)
+	order := newResultOrder(c.config)
+	pac, err := newPACWriter(c.config)
+	if err != nil {
+		slog.Error("Error parsing PAC template", "error", err)
+	}
+	top := newTopWriter(c.config)
+	matrix := newTargetMatrix(c.config)
+	csvPath := filepath.Join(dir, "proxies.csv")
+	// Set once checking ends, if proxies were left unchecked
+	var interrupted bool
+	publish := func() {
+		if err := exits.removeReplaced(dir, csvPath); err != nil {
+			slog.Error("Error removing proxies sharing an exit IP", "error", err)
+		}
+		if err := order.sortOutputs(dir, csvPath); err != nil {
+			slog.Error("Error sorting output files", "error", err)
 		}
-		if err := WriteFile(filepath.Join("out", "socks5.txt"), header); err != nil {
-			log.Printf("Error writing SOCKS5 header: %v", err)
+		if staged {
+			// The files of an interrupted run stay staged for --resume
+			// instead of replacing the complete ones of the previous run
+			if interrupted {
+				slog.Info("Keeping the output files of the interrupted run staged", "dir", dir)
+				return
+			}
+			if err := publishStaging(dir, c.config.Output.Dir); err != nil {
+				slog.Error("Error publishing output files", "error", err)
+			}
+		}
+		if err := writeExports(c.config.Output.Dir, c.config.Output.Format); err != nil {
+			slog.Error("Error exporting client configurations", "error", err)
+		}
+		if err := pac.write(c.config.Output.Dir); err != nil {
+			slog.Error("Error writing PAC file", "error", err)
+		}
+		if err := top.write(c.config.Output.Dir); err != nil {
+			slog.Error("Error writing top proxies", "error", err)
+		}
+		if err := matrix.write(c.config.Output.Dir); err != nil {
+			slog.Error("Error writing target matrix", "error", err)
 		}
 	}
 
-	// Start HTTP proxy checks
-	for _, proxy := range httpProxies {
+	// Write headers if detailed output is enabled. HTTPS and SOCKS4 proxies
+	// are only found by protocol detection.
+	if c.config.Checker.StrictCheck && c.config.Checker.DetailedOutput && !resumed {
+		header := c.detailedHeader()
+		outputTypes := []ProxyType{ProxyTypeHTTP, ProxyTypeSOCKS5}
+		if len(autoProxies) > 0 {
+			outputTypes = DetectOrder
+		}
+		for _, proxyType := range outputTypes {
+			if err := WriteFile(OutputFile(dir, proxyType), header); err != nil {
+				slog.Error("Error writing header", "type", proxyType, "error", err)
+			}
+		}
+	}
+
+	// Open CSV output if enabled
+	var csvWriter *CSVWriter
+	if c.config.Output.CSV {
+		var err error
+		open := NewCSVWriter
+		if resumed {
+			open = AppendCSVWriter
+		}
+		csvWriter, err = open(csvPath, c.config.Output.CSVColumns)
+		if err != nil {
+			slog.Error("Error creating CSV output", "error", err)
+		}
+	}
+	saveCSV := func(result CheckResult) {
+		if csvWriter == nil {
+			return
+		}
+		if err := csvWriter.Write(result); err != nil {
+			slog.Error("Error writing CSV row", "error", err)
+		}
+	}
+
+	// Create per-target output files if targets are configured
+	var targets *targetOutput
+	if len(c.config.Checker.Targets) > 0 {
+		var err error
+		targets, err = newTargetOutput(filepath.Join(dir, "targets"), c.config.Checker.Targets, !resumed)
+		if err != nil {
+			slog.Error("Error creating target output", "error", err)
+		}
+	}
+	// Create the per-country output directory if enabled
+	var countries *countryOutput
+	if c.config.Output.SplitByCountry {
+		var err error
+		countries, err = newCountryOutput(filepath.Join(dir, "by_country"), !resumed)
+		if err != nil {
+			slog.Error("Error creating per-country output", "error", err)
+		}
+	}
+	saveCountry := func(result CheckResult, output string) {
+		if countries == nil {
+			return
+		}
+		if err := countries.Save(result, output); err != nil {
+			slog.Error("Error saving proxy by country", "error", err)
+		}
+	}
+
+	saveTargets := func(result CheckResult, output string) {
+		if targets == nil {
+			return
+		}
+		if err := targets.Save(result, output); err != nil {
+			slog.Error("Error saving target proxy", "error", err)
+		}
+	}
+
+	// In-flight checks must not be aborted by cancellation of ctx
+	checkCtx := context.WithoutCancel(ctx)
+
+	// Once the previously working proxies, listed first, are all checked,
+	// those still working are published without waiting for the others
+	var revalidating atomic.Int64
+	for _, proxies := range [][]string{httpProxies, socks5Proxies, autoProxies} {
+		for _, proxy := range proxies {
+			if c.Revalidate[proxy] {
+				revalidating.Add(1)
+			}
+		}
+	}
+	revalidate := revalidating.Load()
+	revalidated := func() {
+		if staged {
+			if err := snapshotStaging(dir, c.config.Output.Dir, fileLocks); err != nil {
+				slog.Error("Error publishing revalidated proxies", "error", err)
+				return
+			}
+		}
+		slog.Info("Revalidated previously working proxies", "checked", revalidate)
+	}
+
+	// handle saves the result of the check of a proxy of a list
+	handle := func(proxyType ProxyType, p string, result CheckResult) {
+		result = c.record(result)
+		if result.Working {
+			result.Score = c.score(result)
+		}
+		save := result.Working
+		if save {
+			var shared string
+			shared, save = exits.track(result)
+			if c.config.Checker.ExitIPDedup == "annotate" {
+				result.SharedExit = shared
+			}
+		}
+		c.report(proxyType, result)
+		if save {
+			output := c.formatProxyOutput(result)
+			if err := AppendLine(OutputFile(dir, result.Type), output, fileLocks[result.Type]); err != nil {
+				slog.Error("Error saving proxy", "type", result.Type, "error", err)
+			}
+			saveCSV(result)
+			saveTargets(result, output)
+			saveCountry(result, output)
+			order.add(result)
+			pac.add(result)
+			top.add(result)
+			matrix.add(result)
+		}
+		c.Checkpoint.MarkChecked(proxyType, p)
+		if c.Revalidate[p] && revalidating.Add(-1) == 0 {
+			revalidated()
+		}
+	}
+
+	// Start a fixed pool of workers per list, sized by its own concurrency,
+	// fed with the proxies of the list in order, in batches across all lists
+	// with checker.batch_size
+	batches := newBatchGate(c.config)
+	lists := []struct {
+		proxyType ProxyType
+		proxies   []string
+		workers   int
+	}{
+		{ProxyTypeHTTP, httpProxies, c.config.Checker.ConcurrentHTTP},
+		{ProxyTypeSOCKS5, socks5Proxies, c.config.Checker.ConcurrentSOCKS5},
+		{ProxyTypeAuto, autoProxies, c.config.Checker.ConcurrentAuto},
+	}
+	var remoteErr error
+	if c.Workers != nil {
+		// The lists are checked on the remote workers instead
+		remote := make(map[ProxyType][]string, len(lists))
+		for _, list := range lists {
+			remote[list.proxyType] = list.proxies
+		}
 		wg.Add(1)
-		go func(p string) {
+		go func() {
 			defer wg.Done()
-			semHTTP <- struct{}{}
-			defer func() { <-semHTTP }()
-			if result := c.checkHTTPProxy(p); result.Working {
-				output := c.formatProxyOutput(result)
-				if err := AppendLine(filepath.Join("out", "http.txt"), output, &httpMu); err != nil {
-					log.Printf("Error saving HTTP proxy: %v", err)
-				}
+			if remoteErr = c.Workers.check(ctx, remote, handle); remoteErr != nil {
+				slog.Error("Error checking on remote workers", "error", remoteErr)
 			}
-		}(proxy)
+		}()
+		lists = nil
 	}
-
-	// Start SOCKS5 proxy checks
-	for _, proxy := range socks5Proxies {
+	if len(lists) > 0 {
+		// Judges that are down are skipped until they answer again
+		judgeCtx, stopJudges := context.WithCancel(ctx)
+		defer stopJudges()
+		c.watchJudges(judgeCtx)
+		if c.judges != nil {
+			c.publicIPs(ctx)
+		}
+	}
+	for _, list := range lists {
+		jobs := make(chan string)
+		for i := 0; i < min(list.workers, len(list.proxies)); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for p := range jobs {
+					if ctx.Err() != nil {
+						batches.done()
+						return
+					}
+					handle(list.proxyType, p, c.Check(checkCtx, p, list.proxyType))
+					batches.done()
+				}
+			}()
+		}
 		wg.Add(1)
-		go func(p string) {
+		go func() {
 			defer wg.Done()
-			semSOCKS5 <- struct{}{}
-			defer func() { <-semSOCKS5 }()
-			if result := c.checkSOCKS5Proxy(p); result.Working {
-				output := c.formatProxyOutput(result)
-				if err := AppendLine(filepath.Join("out", "socks5.txt"), output, &socks5Mu); err != nil {
-					log.Printf("Error saving SOCKS5 proxy: %v", err)
+			defer close(jobs)
+			for _, p := range list.proxies {
+				if !batches.enter(ctx) {
+					return
+				}
+				select {
+				case jobs <- p:
+				case <-ctx.Done():
+					batches.done()
+					return
 				}
 			}
-		}(proxy)
+		}()
 	}
 
 	// Start progress display
-	go c.displayProgress()
+	done := make(chan struct{})
+	displayed := make(chan struct{})
+	go func() {
+		c.displayProgress(done)
+		close(displayed)
+	}()
 
 	wg.Wait()
-	close(c.ResultChan)
-}
+	close(done)
+	<-displayed
+	interrupted = ctx.Err() != nil || remoteErr != nil
 
-// testProxy tests if a proxy is working
-func (c *ProxyChecker) testProxy(client *http.Client) (bool, string, time.Duration, bool, *ProxyLocation) {
-	if !c.config.Checker.StrictCheck {
-		// Simple check - just verify if proxy returns 200 OK
-		req, err := http.NewRequest("GET", c.config.Checker.TestURL, nil)
-		if err != nil {
-			return false, "", 0, false, nil
+	// Output files are closed and published before the stable proxies and
+	// the run metadata are written, so that meta.json never describes
+	// files that are not in place yet
+	if csvWriter != nil {
+		if err := csvWriter.Close(); err != nil {
+			slog.Error("Error closing CSV output", "error", err)
 		}
+	}
+	publish()
 
-		req.Header.Set("User-Agent", c.config.Checker.UserAgent)
-		start := time.Now()
-		resp, err := client.Do(req)
-		if err != nil {
-			return false, "", 0, false, nil
+	// The checkpoint of a resumed run dates from its first part
+	since := started
+	if resumed {
+		since = c.Checkpoint.Created()
+	}
+	if err := c.writeStable(context.WithoutCancel(ctx), since); err != nil {
+		slog.Error("Error writing stable proxies", "error", err)
+	}
+	// The metadata of the previous run still describes its files until an
+	// interrupted staged run is resumed and published
+	if !staged || !interrupted {
+		if err := c.writeMeta(since, resumed, interrupted); err != nil {
+			slog.Error("Error writing run metadata", "error", err)
 		}
-		defer resp.Body.Close()
-
-		return resp.StatusCode == http.StatusOK, "", time.Since(start), false, nil
 	}
+	// All checks have returned, nothing sends on ResultChan anymore
+	if c.ResultChan != nil {
+		close(c.ResultChan)
+	}
+	return remoteErr
+}
 
-	// Strict check with multiple criteria
-	var proxyIP string
-	var location *ProxyLocation
-	var isAnonymous bool
-	var totalTime time.Duration
+// record records a check result in the history store, if any, and fills in
+// its stability and streak. Working proxies below store.min_stability are
+// then marked as not working; the check itself is still recorded as passed.
+func (c *ProxyChecker) record(result CheckResult) CheckResult {
+	if c.Store == nil {
+		return result
+	}
 
-	// 1. Check IP and location using ip-api.com
-	req, err := http.NewRequest("GET", "http://ip-api.com/json", nil)
+	entry, err := c.Store.Record(context.Background(), result.Proxy, result.Type.String(),
+		result.Working, result.Speed, time.Now())
 	if err != nil {
-		return false, "", 0, false, nil
+		slog.Error("Error recording proxy history", "proxy", result.Proxy, "error", err)
+		return result
 	}
+	result.Stability = entry.Uptime()
+	result.Streak = entry.Streak
+	if minStability := c.config.Store.MinStability; result.Working && result.Stability < minStability {
+		result.Working = false
+	}
+	return result
+}
 
-	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
-	start := time.Now()
-	resp, err := client.Do(req)
+// report publishes a check result to ResultChan, if set, and the progress
+// counters of the list the proxy came from
+func (c *ProxyChecker) report(listType ProxyType, result CheckResult) {
+	slog.Debug("Checked proxy", "proxy", result.Proxy, "type", result.Type,
+		"working", result.Working, "speed", result.Speed)
+	if c.ResultChan != nil {
+		c.ResultChan <- result
+	}
+	c.updateProgress(listType, result)
+}
+
+// Check checks a single proxy of the given type. Unlike CheckProxies it has
+// no side effects: nothing is written to files, ResultChan or the progress
+// counters, which makes it safe to call from library code.
+//
+// A failing proxy is retried up to checker.retries times, waiting
+// checker.retry_delay before the first retry and doubling the delay before
+// each following one. With checker.check_deadline, the check as a whole,
+// retries included, is abandoned once it runs out. The result is stamped
+// with the time the check ended and, with output.ttl, the time it expires.
+func (c *ProxyChecker) Check(ctx context.Context, proxyStr string, proxyType ProxyType) (result CheckResult) {
+	defer func() {
+		result.CheckedAt = time.Now()
+		if c.config.Output.TTL > 0 {
+			result.ExpiresAt = result.CheckedAt.Add(c.config.Output.TTL)
+		}
+	}()
+
+	// Time spent waiting for the other checks of the host does not count
+	// against the deadline
+	release, err := c.hosts.acquire(ctx, proxyStr)
 	if err != nil {
-		return false, "", 0, false, nil
+		return CheckResult{Proxy: proxyStr, Type: proxyType}
 	}
+	defer release()
 
-	body, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
+	if deadline := c.config.Checker.CheckDeadline; deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	proxyStr, err = c.resolveProxy(ctx, proxyStr)
 	if err != nil {
-		return false, "", 0, false, nil
+		return CheckResult{Proxy: proxyStr, Type: proxyType}
+	}
+
+	var interrupted bool
+	if len(c.vantages) > 0 {
+		result, interrupted = c.checkVantages(ctx, proxyStr, proxyType)
+	} else {
+		result, interrupted = c.checkRetrying(ctx, proxyStr, proxyType)
+	}
+	if interrupted {
+		return c.applyFilters(result)
+	}
+	return c.checkReputation(ctx, c.applyFilters(result))
+}
+
+// checkRetrying checks a proxy, retrying it while it fails, and reports
+// whether ctx was done before the retries ran out
+func (c *ProxyChecker) checkRetrying(ctx context.Context, proxyStr string, proxyType ProxyType) (result CheckResult, interrupted bool) {
+	delay := c.config.Checker.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if c.rejectPort(ctx, proxyStr) {
+			result = CheckResult{Proxy: proxyStr, Type: proxyType}
+		} else {
+			result = c.checkOnce(ctx, proxyStr, proxyType)
+		}
+		if result.Working || attempt >= c.config.Checker.Retries {
+			return result, false
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return result, true
+		}
+		delay *= 2
 	}
+}
 
-	// Parse IP-API response
-	var ipData struct {
-		Status      string  `json:"status"`
-		Country     string  `json:"country"`
-		CountryCode string  `json:"countryCode"`
-		Region      string  `json:"regionName"`
-		City        string  `json:"city"`
-		Query       string  `json:"query"`
+// checkOnce runs a single check of a proxy of the given type
+func (c *ProxyChecker) checkOnce(ctx context.Context, proxyStr string, proxyType ProxyType) CheckResult {
+	switch proxyType {
+	case ProxyTypeSOCKS5:
+		return c.checkSOCKS5Proxy(ctx, proxyStr)
+	case ProxyTypeHTTPS, ProxyTypeSOCKS4:
+		return c.checkTunnelProxy(ctx, proxyStr, proxyType)
+	case ProxyTypeAuto:
+		return c.detectProtocol(ctx, proxyStr)
+	default:
+		return c.checkHTTPProxy(ctx, proxyStr)
 	}
+}
 
-	if err := json.Unmarshal(body, &ipData); err != nil || ipData.Status != "success" {
-		return false, "", 0, false, nil
+// detectProtocol checks a proxy of unknown type as each protocol of
+// DetectOrder in turn and returns the result of the first one that works.
+// If none does, the result has type ProxyTypeAuto.
+func (c *ProxyChecker) detectProtocol(ctx context.Context, proxyStr string) CheckResult {
+	for _, proxyType := range DetectOrder {
+		if ctx.Err() != nil {
+			break
+		}
+		if result := c.checkOnce(ctx, proxyStr, proxyType); result.Working {
+			return result
+		}
 	}
+	return CheckResult{Proxy: proxyStr, Type: ProxyTypeAuto}
+}
 
-	proxyIP = ipData.Query
-	location = &ProxyLocation{
-		Country:     ipData.Country,
-		CountryCode: ipData.CountryCode,
-		City:        ipData.City,
-		Region:      ipData.Region,
+// resolveProxy resolves the hostname of a hostname-based proxy so that
+// unresolvable entries fail fast. With checker.resolve_hostnames enabled
+// the hostname is replaced by its first resolved IP, which then appears in
+// the output instead.
+func (c *ProxyChecker) resolveProxy(ctx context.Context, proxyStr string) (string, error) {
+	addr, err := ParseProxyAddr(proxyStr)
+	if err != nil || net.ParseIP(addr.Host) != nil {
+		return proxyStr, nil
 	}
 
-	// 2. Check anonymity by comparing headers
-	req, err = http.NewRequest("GET", "https://httpbin.org/headers", nil)
+	resolveCtx, cancel := context.WithTimeout(ctx, c.config.Checker.ConnectTimeout)
+	defer cancel()
+	ips, err := c.dns.LookupHost(resolveCtx, addr.Host)
 	if err != nil {
-		return false, "", 0, false, nil
+		return proxyStr, err
+	}
+	if !c.config.Checker.ResolveHostnames {
+		return proxyStr, nil
+	}
+
+	addr.Host = ips[0]
+	return addr.String(), nil
+}
+
+// applyFilters marks working proxies that do not meet the configured
+// requirements as not working
+func (c *ProxyChecker) applyFilters(result CheckResult) CheckResult {
+	if !result.Working {
+		return result
+	}
+
+	if maxLatency := c.config.Checker.MaxLatency; maxLatency > 0 && result.Speed > maxLatency {
+		result.Working = false
+	}
+	minLevel, _ := ParseAnonymityLevel(c.config.Checker.MinAnonymity)
+	if result.Anonymity < minLevel {
+		result.Working = false
+	}
+	if !c.countryAllowed(result.Location) {
+		result.Working = false
+	}
+	network, _ := ParseNetworkClass(c.config.Checker.NetworkClass)
+	if network != NetworkUnknown && result.Network != network {
+		result.Working = false
+	}
+	if maxRisk := c.config.Risk.MaxRisk; maxRisk > 0 && result.Risk > maxRisk {
+		result.Working = false
+	}
+	return result
+}
+
+// checkReputation looks up a working proxy in the Tor exit list and the
+// reputation blocklists by its exit IP, or by its own IP when the exit IP
+// is unknown outside strict mode. Tor exits are flagged, and marked as not
+// working with checker.tor_exits: exclude. Listed proxies are tagged, and
+// marked as not working with reputation.action: drop.
+func (c *ProxyChecker) checkReputation(ctx context.Context, result CheckResult) CheckResult {
+	if !result.Working || (c.Reputation == nil && c.TorExits == nil) {
+		return result
+	}
+
+	ip := result.ProxyIP
+	if ip == "" {
+		addr, err := ParseProxyAddr(result.Proxy)
+		if err != nil {
+			return result
+		}
+		ip = addr.Host
+	}
+
+	result.TorExit = c.TorExits.Contains(ip)
+	if result.TorExit {
+		slog.Debug("Proxy exits through Tor", "proxy", result.Proxy, "ip", ip)
+		if c.config.Checker.TorExits == "exclude" {
+			result.Working = false
+			return result
+		}
+	}
+
+	result.Blocklists = c.Reputation.Listed(ctx, ip)
+	if len(result.Blocklists) > 0 {
+		slog.Debug("Proxy exit IP is blocklisted", "proxy", result.Proxy, "ip", ip, "blocklists", result.Blocklists)
+		if c.config.Reputation.Action == "drop" {
+			result.Working = false
+		}
+	}
+	return result
+}
+
+// countryAllowed checks a proxy location against the country allow and deny
+// lists. Proxies with unknown location never pass a non-empty allow list.
+func (c *ProxyChecker) countryAllowed(location *ProxyLocation) bool {
+	allow := c.config.Checker.CountriesAllow
+	deny := c.config.Checker.CountriesDeny
+	if len(allow) == 0 && len(deny) == 0 {
+		return true
 	}
 
+	code := ""
+	if location != nil {
+		code = strings.ToUpper(location.CountryCode)
+	}
+	if len(allow) > 0 && !slices.Contains(allow, code) {
+		return false
+	}
+	return !slices.Contains(deny, code)
+}
+
+// checkConnectivity is the connectivity step: the proxy works if the test
+// URL answers through it, see probeLatency
+func (c *ProxyChecker) checkConnectivity(ctx context.Context, client *http.Client, result *CheckResult) {
+	result.Working = c.probeLatency(ctx, client, result)
+}
+
+// checkGeo is the geo step of strict mode: the exit IP and location of the
+// proxy are queried from the IP lookup service, unless a local GeoIP
+// database is available, in which case the anonymity step resolves them
+func (c *ProxyChecker) checkGeo(ctx context.Context, client *http.Client, result *CheckResult) {
+	if !c.config.Checker.StrictCheck || c.GeoIP != nil {
+		return
+	}
+	proxyIP, location, ok := c.lookupIP(ctx, client)
+	if !ok || proxyIP == "" {
+		result.Working = false
+		return
+	}
+	result.ProxyIP = proxyIP
+	result.Location = location
+	c.classifyNetwork(result)
+}
+
+// checkAnonymity is the anonymity step of strict mode: the headers seen by
+// the judge classify the anonymity of the proxy. Without an exit IP from
+// the geo step, the one seen by the judge is used, and resolved offline
+// with a local GeoIP database.
+func (c *ProxyChecker) checkAnonymity(ctx context.Context, client *http.Client, result *CheckResult) {
+	// Proxies are not failed when no judge answers at all: their anonymity
+	// is left unknown
+	if !c.config.Checker.StrictCheck || c.judges.allDown() {
+		return
+	}
+	origin, headers, ok := c.queryJudge(ctx, client)
+	if !ok {
+		result.Working = false
+		return
+	}
+	// The headers the proxy added to the request
+	seen := make(http.Header, len(headers))
+	for name, value := range headers {
+		seen.Set(name, value)
+	}
+	capturedHeaders(ctx).add(seen)
+	// A proxy rewriting the User-Agent sent to the judge tampers with requests
+	if ua := seen.Get("User-Agent"); ua != "" && ua != c.config.Checker.UserAgent {
+		c.flagRisk(result, RiskTampered)
+	}
+
+	if result.ProxyIP == "" {
+		result.ProxyIP = exitIPFromOrigin(origin)
+		if result.ProxyIP == "" {
+			result.Working = false
+			return
+		}
+		if c.GeoIP != nil {
+			if loc, err := c.GeoIP.Lookup(result.ProxyIP); err == nil {
+				result.Location = loc
+			}
+		}
+		c.classifyNetwork(result)
+	}
+
+	result.Anonymity, result.RealIPLeak = classifyAnonymity(result.ProxyIP, origin, headers, c.publicIPs(ctx))
+	result.Anonymous = result.Anonymity >= AnonymityAnonymous
+}
+
+// classifyNetwork sets the network class of the exit IP from its AS, given
+// by the IP lookup service unless a local ASN database is available
+func (c *ProxyChecker) classifyNetwork(result *CheckResult) {
+	if c.ASN != nil {
+		if asn, org, err := c.ASN.Lookup(result.ProxyIP); err == nil {
+			if result.Location == nil {
+				result.Location = &ProxyLocation{}
+			}
+			result.Location.ASN, result.Location.ASOrg = asn, org
+		}
+	}
+	if result.Location != nil {
+		result.Network = ClassifyASN(result.Location.ASN)
+	}
+}
+
+// probeLatency sends a single GET to the test URL through the proxy and
+// records the time to establish the connection, the time to first response
+// byte and the total request time. Speed is set to the total time. It
+// returns true if the test URL answered with 200 OK.
+func (c *ProxyChecker) probeLatency(ctx context.Context, client *http.Client, result *CheckResult) bool {
+	var start, connected, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { connected = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "GET", c.config.Checker.TestURL, nil)
+	if err != nil {
+		return false
+	}
 	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
-	resp, err = client.Do(req)
+
+	// The time spent waiting for the rate limiter is not latency
+	if err := c.limiter.Wait(ctx); err != nil {
+		return false
+	}
+	start = time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
-		return false, "", 0, false, nil
+		return false
+	}
+	defer resp.Body.Close()
+	capturedHeaders(ctx).add(resp.Header)
+	if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeBody)); err != nil {
+		return false
 	}
 
-	body, err = io.ReadAll(resp.Body)
-	resp.Body.Close()
+	result.TotalTime = time.Since(start)
+	result.Speed = result.TotalTime
+	if !connected.IsZero() {
+		result.ConnectTime = connected.Sub(start)
+	}
+	if !firstByte.IsZero() {
+		result.TTFB = firstByte.Sub(start)
+	}
+	return resp.StatusCode == http.StatusOK
+}
+
+// maxProbeBody limits how much of the test URL response is read
+const maxProbeBody = 1 << 20
+
+// measureBandwidth downloads up to checker.bandwidth_bytes from the
+// bandwidth test URL through the proxy and records the throughput. The timer
+// starts once response headers arrive, so connection setup and handshake
+// latency are not counted. A failed download leaves Throughput at zero
+// without affecting the check outcome.
+func (c *ProxyChecker) measureBandwidth(ctx context.Context, client *http.Client, result *CheckResult) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Checker.BandwidthURL, nil)
 	if err != nil {
-		return false, "", 0, false, nil
+		return
 	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
 
-	var headers struct {
-		Headers map[string]string `json:"headers"`
+	resp, err := c.do(client, req)
+	if err != nil {
+		return
 	}
-	if err := json.Unmarshal(body, &headers); err != nil {
-		return false, "", 0, false, nil
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
 	}
 
-	// Check if proxy reveals real IP in headers
-	isAnonymous = true
-	for _, v := range headers.Headers {
-		if strings.Contains(v, proxyIP) {
-			isAnonymous = false
-			break
+	start := time.Now()
+	n, err := io.CopyN(io.Discard, resp.Body, c.config.Checker.BandwidthBytes)
+	elapsed := time.Since(start)
+	if err != nil && err != io.EOF || n == 0 || elapsed <= 0 {
+		return
+	}
+	result.Throughput = float64(n) / 1024 / elapsed.Seconds()
+}
+
+// exitIPFromOrigin returns the IP that connected to the judge. Judges report
+// forwarded client IPs first and the connecting IP last.
+func exitIPFromOrigin(origin string) string {
+	fields := strings.Split(origin, ",")
+	ip := net.ParseIP(strings.TrimSpace(fields[len(fields)-1]))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// proxyHeaders are request headers that reveal the use of a proxy
+var proxyHeaders = []string{
+	"Via",
+	"X-Forwarded-For",
+	"X-Real-Ip",
+	"Forwarded",
+	"Client-Ip",
+	"X-Proxy-Id",
+	"Proxy-Connection",
+}
+
+// ipHeaders are request headers that may carry the original client IP
+var ipHeaders = []string{
+	"X-Forwarded-For",
+	"X-Real-Ip",
+	"Forwarded",
+	"Client-Ip",
+}
+
+// classifyAnonymity determines the anonymity level of a proxy from the
+// client IP and headers echoed by a judge, and reports whether they reveal
+// one of realIPs, the public IPs of this machine. A proxy is transparent if
+// it reveals them, anonymous if it only reveals that a proxy is used, such
+// as with the IP of another proxy in the chain, and elite if the request
+// looks like a direct one. Without realIPs, any IP other than the exit IP
+// makes it transparent.
+func classifyAnonymity(exitIP, origin string, headers map[string]string, realIPs []net.IP) (AnonymityLevel, bool) {
+	exit := net.ParseIP(exitIP)
+	canonical := make(map[string]string, len(headers))
+	for name, value := range headers {
+		canonical[http.CanonicalHeaderKey(name)] = value
+	}
+
+	// Proxies leak the client IP in all sorts of headers, not only the
+	// usual ones
+	if len(realIPs) > 0 {
+		values := []string{origin}
+		for _, value := range canonical {
+			values = append(values, value)
+		}
+		for _, value := range values {
+			for _, ip := range headerIPs(value) {
+				if slices.ContainsFunc(realIPs, ip.Equal) {
+					return AnonymityTransparent, true
+				}
+			}
+		}
+	}
+
+	values := []string{origin}
+	for _, name := range ipHeaders {
+		values = append(values, canonical[name])
+	}
+	for _, value := range values {
+		for _, ip := range headerIPs(value) {
+			if ip.Equal(exit) {
+				continue
+			}
+			if len(realIPs) == 0 {
+				return AnonymityTransparent, false
+			}
+			return AnonymityAnonymous, false
 		}
 	}
 
-	totalTime = time.Since(start)
-	// Proxy is considered working if:
-	// 1. Response time is under 2 seconds
-	// 2. We got valid IP and location data
-	working := totalTime < 2*time.Second && proxyIP != ""
+	for _, name := range proxyHeaders {
+		if _, ok := canonical[name]; ok {
+			return AnonymityAnonymous, false
+		}
+	}
+	return AnonymityElite, false
+}
 
-	return working, proxyIP, totalTime, isAnonymous, location
+// headerIPs returns the IP addresses listed in a header value, with or
+// without a port
+func headerIPs(value string) []net.IP {
+	var ips []net.IP
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '='
+	}) {
+		field = strings.Trim(field, `"`)
+		if host, _, err := net.SplitHostPort(field); err == nil {
+			field = host
+		}
+		if ip := net.ParseIP(strings.Trim(field, "[]")); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
 }
 
 // checkHTTPProxy checks a single HTTP proxy
-func (c *ProxyChecker) checkHTTPProxy(proxyStr string) CheckResult {
-	proxyURL, err := url.Parse("http://" + proxyStr)
+func (c *ProxyChecker) checkHTTPProxy(ctx context.Context, proxyStr string) CheckResult {
+	addr, err := ParseProxyAddr(proxyStr)
 	if err != nil {
-		log.Printf("Error parsing HTTP proxy %s: %v", proxyStr, err)
-		c.updateProgress(ProxyTypeHTTP, false)
+		slog.Debug("Error parsing HTTP proxy", "proxy", proxyStr, "error", err)
 		return CheckResult{Proxy: proxyStr, Working: false, Type: ProxyTypeHTTP}
 	}
+	proxyURL := addr.URL("http")
 
 	transport := &http.Transport{
 		Proxy: http.ProxyURL(proxyURL),
-		DialContext: (&net.Dialer{
+		DialContext: c.dialer(&net.Dialer{
 			Timeout:   c.config.Checker.ConnectTimeout,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
@@ -291,43 +1202,79 @@ func (c *ProxyChecker) checkHTTPProxy(proxyStr string) CheckResult {
 		TLSHandshakeTimeout:   c.config.Checker.ConnectTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
 		ResponseHeaderTimeout: c.config.Checker.Timeout,
+		OnProxyConnectResponse: func(ctx context.Context, _ *url.URL, _ *http.Request, resp *http.Response) error {
+			capturedHeaders(ctx).add(resp.Header)
+			return nil
+		},
 	}
+	// Idle connections would keep their sockets open until the transport is
+	// collected
+	defer transport.CloseIdleConnections()
 
 	client := &http.Client{
 		Transport: transport,
 		Timeout:   c.config.Checker.Timeout,
 	}
 
-	working, proxyIP, speed, anonymous, location := c.testProxy(client)
-	result := CheckResult{
-		Proxy:     proxyStr,
-		Working:   working,
-		Type:      ProxyTypeHTTP,
-		ProxyIP:   proxyIP,
-		Speed:     speed,
-		Anonymous: anonymous,
-		Location:  location,
-	}
-	c.ResultChan <- result
-	c.updateProgress(ProxyTypeHTTP, working)
+	result := CheckResult{Proxy: proxyStr, Type: ProxyTypeHTTP}
+	c.runSteps(ctx, client, &result)
 	return result
 }
 
 // checkSOCKS5Proxy checks a single SOCKS5 proxy
-func (c *ProxyChecker) checkSOCKS5Proxy(proxyStr string) CheckResult {
-	dialer, err := proxy.SOCKS5("tcp", proxyStr, nil, &net.Dialer{
+func (c *ProxyChecker) checkSOCKS5Proxy(ctx context.Context, proxyStr string) CheckResult {
+	addr, err := ParseProxyAddr(proxyStr)
+	if err != nil {
+		slog.Debug("Error parsing SOCKS5 proxy", "proxy", proxyStr, "error", err)
+		return CheckResult{Proxy: proxyStr, Working: false, Type: ProxyTypeSOCKS5}
+	}
+
+	// The SOCKS5 handshake is bounded by checker.connect_timeout and aborted
+	// with the request, so a stuck proxy cannot hold a check past its timeout
+	dialer := c.dialer(&net.Dialer{
 		Timeout:   c.config.Checker.ConnectTimeout,
 		KeepAlive: 30 * time.Second,
 	})
+	dns := &socks5DNS{resolver: c.dns}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, target string) (net.Conn, error) {
+			return dns.dial(ctx, dialer, addr, target, c.config.Checker.ConnectTimeout)
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   c.config.Checker.ConnectTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: c.config.Checker.Timeout,
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   c.config.Checker.Timeout,
+	}
+
+	result := CheckResult{Proxy: proxyStr, Type: ProxyTypeSOCKS5}
+	c.runSteps(ctx, client, &result)
+	result.DNS = dns.mode()
+	return result
+}
+
+// checkTunnelProxy checks a single HTTPS or SOCKS4 proxy. Every request is
+// sent through a tunnel opened by the proxy, with CONNECT for HTTPS proxies.
+func (c *ProxyChecker) checkTunnelProxy(ctx context.Context, proxyStr string, proxyType ProxyType) CheckResult {
+	addr, err := ParseProxyAddr(proxyStr)
 	if err != nil {
-		log.Printf("Error creating SOCKS5 dialer for %s: %v", proxyStr, err)
-		c.updateProgress(ProxyTypeSOCKS5, false)
-		return CheckResult{Proxy: proxyStr, Working: false, Type: ProxyTypeSOCKS5}
+		slog.Debug("Error parsing proxy", "proxy", proxyStr, "type", proxyType, "error", err)
+		return CheckResult{Proxy: proxyStr, Working: false, Type: proxyType}
 	}
 
+	dialer := c.dialer(&net.Dialer{
+		Timeout:   c.config.Checker.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	})
 	transport := &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.Dial(network, addr)
+		DialContext: func(ctx context.Context, network, target string) (net.Conn, error) {
+			return dialProxy(ctx, dialer, proxyType, addr, target, c.config.Checker.ConnectTimeout)
 		},
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
@@ -335,73 +1282,94 @@ func (c *ProxyChecker) checkSOCKS5Proxy(proxyStr string) CheckResult {
 		ExpectContinueTimeout: 1 * time.Second,
 		ResponseHeaderTimeout: c.config.Checker.Timeout,
 	}
+	defer transport.CloseIdleConnections()
 
 	client := &http.Client{
 		Transport: transport,
 		Timeout:   c.config.Checker.Timeout,
 	}
 
-	working, proxyIP, speed, anonymous, location := c.testProxy(client)
-	result := CheckResult{
-		Proxy:     proxyStr,
-		Working:   working,
-		Type:      ProxyTypeSOCKS5,
-		ProxyIP:   proxyIP,
-		Speed:     speed,
-		Anonymous: anonymous,
-		Location:  location,
-	}
-	c.ResultChan <- result
-	c.updateProgress(ProxyTypeSOCKS5, working)
+	result := CheckResult{Proxy: proxyStr, Type: proxyType}
+	c.runSteps(ctx, client, &result)
 	return result
 }
 
-// updateProgress updates the progress counters
-func (c *ProxyChecker) updateProgress(proxyType ProxyType, working bool) {
+// Progress is a snapshot of the checking progress counters
+type Progress struct {
+	CheckedHTTP   int `json:"checked_http"`
+	CheckedSOCKS5 int `json:"checked_socks5"`
+	WorkingHTTP   int `json:"working_http"`
+	WorkingSOCKS5 int `json:"working_socks5"`
+	TotalHTTP     int `json:"total_http"`
+	TotalSOCKS5   int `json:"total_socks5"`
+	CheckedAuto   int `json:"checked_auto"`
+	WorkingAuto   int `json:"working_auto"`
+	TotalAuto     int `json:"total_auto"`
+
+	// Working proxies of unknown protocol by detected type
+	Detected map[string]int `json:"detected,omitempty"`
+}
+
+// Progress returns a snapshot of the current checking progress
+func (c *ProxyChecker) Progress() Progress {
 	c.progressMu.Lock()
 	defer c.progressMu.Unlock()
+	detected := make(map[string]int, len(c.detected))
+	for proxyType, n := range c.detected {
+		detected[proxyType.String()] = n
+	}
+	return Progress{
+		CheckedHTTP:   c.checkedHTTP,
+		CheckedSOCKS5: c.checkedSOCKS5,
+		WorkingHTTP:   c.workingHTTP,
+		WorkingSOCKS5: c.workingSOCKS5,
+		TotalHTTP:     c.totalHTTP,
+		TotalSOCKS5:   c.totalSOCKS5,
+		CheckedAuto:   c.checkedAuto,
+		WorkingAuto:   c.workingAuto,
+		TotalAuto:     c.totalAuto,
+		Detected:      detected,
+	}
+}
 
-	switch proxyType {
+// updateProgress updates the progress counters of the given list
+func (c *ProxyChecker) updateProgress(listType ProxyType, result CheckResult) {
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+
+	switch listType {
 	case ProxyTypeHTTP:
 		c.checkedHTTP++
-		if working {
+		if result.Working {
 			c.workingHTTP++
 		}
 	case ProxyTypeSOCKS5:
 		c.checkedSOCKS5++
-		if working {
+		if result.Working {
 			c.workingSOCKS5++
 		}
+	case ProxyTypeAuto:
+		c.checkedAuto++
+		if result.Working {
+			c.workingAuto++
+			c.detected[result.Type]++
+		}
 	}
 }
 
-// displayProgress displays the progress of proxy checking
-func (c *ProxyChecker) displayProgress() {
+// displayProgress sends the progress of proxy checking to the display
+// until done is closed, once all checks have returned
+func (c *ProxyChecker) displayProgress(done <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
 	for {
-		c.progressMu.Lock()
-		if c.checkedHTTP == c.totalHTTP && c.checkedSOCKS5 == c.totalSOCKS5 {
-			c.progressMu.Unlock()
-			break
+		select {
+		case <-done:
+			display.CheckProgress(c.Progress(), true)
+			return
+		case <-ticker.C:
+			display.CheckProgress(c.Progress(), false)
 		}
-
-		httpPercentage := float64(c.checkedHTTP) / float64(c.totalHTTP) * 100
-		socks5Percentage := float64(c.checkedSOCKS5) / float64(c.totalSOCKS5) * 100
-
-		fmt.Print("\033[1A\033[K")
-		fmt.Printf("\rHTTP [%d/%d] - Working: %d %s %.0f%%", 
-			c.checkedHTTP, c.totalHTTP, c.workingHTTP, 
-			ProgressBar(httpPercentage, 30), httpPercentage)
-		
-		fmt.Print("\n")
-		fmt.Printf("\rSOCKS5 [%d/%d] - Working: %d %s %.0f%%", 
-			c.checkedSOCKS5, c.totalSOCKS5, c.workingSOCKS5, 
-			ProgressBar(socks5Percentage, 30), socks5Percentage)
-
-		c.progressMu.Unlock()
-		time.Sleep(100 * time.Millisecond)
 	}
-
-	// Final progress update
-	fmt.Printf("\n✓ Found %d working HTTP proxies\n", c.workingHTTP)
-	fmt.Printf("✓ Found %d working SOCKS5 proxies\n", c.workingSOCKS5)
-} 
\ No newline at end of file
+}
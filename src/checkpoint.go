@@ -0,0 +1,227 @@
+package src
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointInterval is how often checked proxies are flushed to disk
+const checkpointInterval = 5 * time.Second
+
+// checkpointState is the layout of the checkpoint file: the proxies of the
+// run, in checking order
+type checkpointState struct {
+	Created time.Time `json:"created"`
+	HTTP    []string  `json:"http"`
+	SOCKS5  []string  `json:"socks5"`
+	Auto    []string  `json:"auto,omitempty"` // Proxies of unknown protocol
+}
+
+// Checkpoint records the progress of a checking run so that an interrupted
+// run can be resumed. The proxies to check are written once to a JSON file
+// and every checked proxy is appended to a log next to it (<path>.checked),
+// flushed periodically. All methods are safe to call on a nil *Checkpoint,
+// which disables checkpointing.
+type Checkpoint struct {
+	path    string
+	state   checkpointState
+	checked map[string]bool // "TYPE proxy" of the proxies already checked
+	resumed bool
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	done    chan struct{}
+	stopped chan struct{}
+
+	// Close only runs once, so that it can also be deferred
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// checkpointKey identifies a proxy of a given type in the checked log
+func checkpointKey(proxyType ProxyType, proxy string) string {
+	return proxyType.String() + " " + proxy
+}
+
+// CreateCheckpoint starts a new checkpoint at path for a run checking the
+// given proxies, replacing any previous one
+func CreateCheckpoint(path string, httpProxies, socks5Proxies, autoProxies []string) (*Checkpoint, error) {
+	state := checkpointState{Created: time.Now(), HTTP: httpProxies, SOCKS5: socks5Proxies, Auto: autoProxies}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the state atomically so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path + ".checked")
+	if err != nil {
+		return nil, err
+	}
+	return newCheckpoint(path, state, make(map[string]bool), false, file), nil
+}
+
+// LoadCheckpoint opens the checkpoint at path left by an interrupted run.
+// Proxies checked from now on are appended to its log.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint: %w", err)
+	}
+
+	checked := make(map[string]bool)
+	lines, err := ReadLines(path + ".checked")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, line := range lines {
+		checked[line] = true
+	}
+
+	file, err := os.OpenFile(path+".checked", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return newCheckpoint(path, state, checked, true, file), nil
+}
+
+// newCheckpoint returns a checkpoint appending to file and starts flushing
+// it periodically
+func newCheckpoint(path string, state checkpointState, checked map[string]bool, resumed bool, file *os.File) *Checkpoint {
+	cp := &Checkpoint{
+		path:    path,
+		state:   state,
+		checked: checked,
+		resumed: resumed,
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(cp.stopped)
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cp.done:
+				return
+			case <-ticker.C:
+				if err := cp.flush(); err != nil {
+					slog.Error("Error writing checkpoint", "error", err)
+				}
+			}
+		}
+	}()
+	return cp
+}
+
+// Resumed reports whether the checkpoint was left by an interrupted run
+func (cp *Checkpoint) Resumed() bool {
+	return cp != nil && cp.resumed
+}
+
+// Created returns the start time of the checkpointed run
+func (cp *Checkpoint) Created() time.Time {
+	if cp == nil {
+		return time.Time{}
+	}
+	return cp.state.Created
+}
+
+// Pending returns the proxies of the run that were not checked yet, in
+// their original order
+func (cp *Checkpoint) Pending() (httpProxies, socks5Proxies, autoProxies []string) {
+	if cp == nil {
+		return nil, nil, nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	pending := func(proxies []string, proxyType ProxyType) []string {
+		var left []string
+		for _, proxy := range proxies {
+			if !cp.checked[checkpointKey(proxyType, proxy)] {
+				left = append(left, proxy)
+			}
+		}
+		return left
+	}
+	return pending(cp.state.HTTP, ProxyTypeHTTP), pending(cp.state.SOCKS5, ProxyTypeSOCKS5), pending(cp.state.Auto, ProxyTypeAuto)
+}
+
+// MarkChecked records that a proxy was checked. It must be called once the
+// result was saved, so that a resumed run never loses it.
+func (cp *Checkpoint) MarkChecked(proxyType ProxyType, proxy string) {
+	if cp == nil {
+		return
+	}
+
+	key := checkpointKey(proxyType, proxy)
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.checked[key] = true
+	cp.writer.WriteString(key + "\n")
+}
+
+// flush writes the buffered checked proxies to disk
+func (cp *Checkpoint) flush() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.writer.Flush()
+}
+
+// Close stops the periodic flush and writes the remaining checked proxies.
+// Calls after the first one return its error.
+func (cp *Checkpoint) Close() error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.closeOnce.Do(func() {
+		close(cp.done)
+		<-cp.stopped
+		if err := cp.flush(); err != nil {
+			cp.file.Close()
+			cp.closeErr = err
+			return
+		}
+		cp.closeErr = cp.file.Close()
+	})
+	return cp.closeErr
+}
+
+// Remove closes the checkpoint and deletes its files, once the run it
+// records completed
+func (cp *Checkpoint) Remove() error {
+	if cp == nil {
+		return nil
+	}
+
+	if err := cp.Close(); err != nil {
+		return err
+	}
+	for _, path := range []string{cp.path, cp.path + ".checked"} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
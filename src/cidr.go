@@ -0,0 +1,123 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// errCIDRDisabled is returned by cidr sources unless scraper.allow_cidr is
+// set
+var errCIDRDisabled = errors.New("cidr sources are disabled, set scraper.allow_cidr: true to scan your own ranges")
+
+// cidrSource expands ranges of addresses and lists of ports into every
+// combination of them, to check proxies of your own infrastructure
+type cidrSource struct {
+	source Source
+}
+
+// Fetch returns the candidates of the source, or an error if they are more
+// than scraper.cidr_max_candidates. Network and broadcast addresses of IPv4
+// ranges are left out.
+func (s cidrSource) Fetch(ctx context.Context) ([]ProxyCandidate, error) {
+	if s.source.cidrLimit <= 0 {
+		return nil, errCIDRDisabled
+	}
+	prefixes, ports, err := parseCIDRSource(s.source)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, prefix := range prefixes {
+		total += cidrHosts(prefix)
+		if total > s.source.cidrLimit || total*len(ports) > s.source.cidrLimit {
+			return nil, fmt.Errorf("ranges expand to more than scraper.cidr_max_candidates (%d) candidates", s.source.cidrLimit)
+		}
+	}
+
+	found := make([]ProxyCandidate, 0, total*len(ports))
+	for _, prefix := range prefixes {
+		first, last := prefix.Addr(), lastAddr(prefix)
+		if first.Is4() && prefix.Bits() <= 30 {
+			first, last = first.Next(), last.Prev()
+		}
+		for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			for _, port := range ports {
+				found = append(found, ProxyCandidate{Proxy: net.JoinHostPort(addr.String(), strconv.Itoa(port))})
+			}
+		}
+	}
+	return found, nil
+}
+
+// parseCIDRSource parses the ranges and ports of a cidr source. Ranges are
+// written in CIDR notation or as single addresses, and ports as numbers or
+// ranges such as 8000-8010.
+func parseCIDRSource(source Source) ([]netip.Prefix, []int, error) {
+	prefixes := make([]netip.Prefix, 0, len(source.CIDRs))
+	for _, cidr := range source.CIDRs {
+		cidr = strings.TrimSpace(cidr)
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, nil, fmt.Errorf("cidrs: invalid range %q", cidr)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	var ports []int
+	seen := make(map[int]bool)
+	for _, field := range source.Ports {
+		start, end, isRange := strings.Cut(strings.TrimSpace(field), "-")
+		if !isRange {
+			end = start
+		}
+		first, err1 := strconv.Atoi(strings.TrimSpace(start))
+		last, err2 := strconv.Atoi(strings.TrimSpace(end))
+		if err1 != nil || err2 != nil || first < 1 || last > 65535 || last < first {
+			return nil, nil, fmt.Errorf("ports: invalid port or range %q", field)
+		}
+		for port := first; port <= last; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return prefixes, ports, nil
+}
+
+// cidrHosts returns the number of addresses of prefix expanded into
+// candidates, capped at the maximum int for large IPv6 ranges
+func cidrHosts(prefix netip.Prefix) int {
+	bits := prefix.Addr().BitLen() - prefix.Bits()
+	switch {
+	case bits >= strconv.IntSize-2:
+		return 1 << (strconv.IntSize - 2)
+	case prefix.Addr().Is4() && bits >= 2:
+		return 1<<bits - 2
+	default:
+		return 1 << bits
+	}
+}
+
+// lastAddr returns the last address of prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,75 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// cloudflareMarkers are fragments of the block pages and JavaScript
+// challenges served by Cloudflare instead of the requested content. The
+// challenge platform scripts are left out, as they are also injected into
+// regular pages.
+var cloudflareMarkers = []struct {
+	fragment string
+	reason   string
+}{
+	{"error code: 1020", "access denied (1020)"},
+	{"<title>Just a moment...</title>", "JavaScript challenge"},
+	{"cf_chl_opt", "JavaScript challenge"},
+	{"<title>Attention Required! | Cloudflare</title>", "block page"},
+	{"cf-error-code", "error page"},
+}
+
+// probeCloudflare requests checker.cloudflare_check_url, a site fronted by
+// Cloudflare, through a working proxy and records whether it got the
+// content: a proxy answered with a 403, a 1020 access denied page or a
+// JavaScript challenge is not Cloudflare-friendly. Like the other
+// capabilities, this only tags the result.
+func (c *ProxyChecker) probeCloudflare(ctx context.Context, client *http.Client, result *CheckResult) {
+	if !c.config.Checker.CloudflareCheck {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Checker.CloudflareCheckURL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := c.do(client, req)
+	if err != nil {
+		slog.Debug("Cloudflare probe failed", "proxy", result.Proxy, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		slog.Debug("Cloudflare probe failed", "proxy", result.Proxy, "error", err)
+		return
+	}
+	if reason := cloudflareBlock(resp, body); reason != "" {
+		slog.Debug("Proxy blocked by Cloudflare", "proxy", result.Proxy, "reason", reason)
+		return
+	}
+	result.CFFriendly = true
+}
+
+// cloudflareBlock returns why a response is a Cloudflare block or
+// challenge rather than the content of the site, empty if it is not
+func cloudflareBlock(resp *http.Response, body []byte) string {
+	if mitigated := resp.Header.Get("Cf-Mitigated"); mitigated != "" {
+		return mitigated
+	}
+	for _, marker := range cloudflareMarkers {
+		if bytes.Contains(body, []byte(marker.fragment)) {
+			return marker.reason
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Status
+	}
+	return ""
+}
@@ -1,7 +1,20 @@
 package src
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -9,46 +22,788 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Scraper ScraperConfig `yaml:"scraper"`
-	Checker CheckerConfig `yaml:"checker"`
+	Scraper     ScraperConfig     `yaml:"scraper"`
+	Checker     CheckerConfig     `yaml:"checker"`
+	Output      OutputConfig      `yaml:"output"`
+	Score       ScoreConfig       `yaml:"score"`
+	Daemon      DaemonConfig      `yaml:"daemon"`
+	Server      ServerConfig      `yaml:"server"`
+	API         APIConfig         `yaml:"api"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	Distributed DistributedConfig `yaml:"distributed"`
+	GeoIP       GeoIPConfig       `yaml:"geoip"`
+	Reputation  ReputationConfig  `yaml:"reputation"`
+	Risk        RiskConfig        `yaml:"risk"`
+	Store       StoreConfig       `yaml:"store"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Notify      NotifyConfig      `yaml:"notify"`
+	Log         LogConfig         `yaml:"log"`
+
+	Profiles map[string]ProfileConfig `yaml:"profiles,omitempty"` // Named overrides selected with --profile
+}
+
+// ProfileConfig defines settings applied over the scraper and checker
+// sections when the profile is selected. Only the keys it sets are
+// overridden.
+type ProfileConfig struct {
+	Scraper yaml.Node `yaml:"scraper"`
+	Checker yaml.Node `yaml:"checker"`
 }
 
 // ScraperConfig defines settings for proxy scraping
 type ScraperConfig struct {
-	Timeout    time.Duration `yaml:"timeout"`
-	UserAgent  string        `yaml:"user_agent"`
-	Concurrent int          `yaml:"concurrent"`
-	UserAgents []string     `yaml:"user_agents"`
+	Timeout               time.Duration `yaml:"timeout"`
+	UserAgent             string        `yaml:"user_agent"`
+	Concurrent            int           `yaml:"concurrent"`
+	Retries               int           `yaml:"retries"`                  // Extra attempts of requests failing with a network error, 429 or 5xx status
+	RetryDelay            time.Duration `yaml:"retry_delay"`              // Delay before the first retry, doubled for each further one
+	HostRequestsPerMinute int           `yaml:"host_requests_per_minute"` // Limit of requests per minute to each host, 0 for no limit
+	Jitter                time.Duration `yaml:"jitter"`                   // Random delay of up to this before each request
+	UseProxies            bool          `yaml:"use_proxies"`              // Fetch sources through the working proxies of the previous results
+	UpstreamProxy         string        `yaml:"upstream_proxy"`           // Proxy URL sources are fetched through instead, e.g. socks5://host:1080
+	UserAgents            []string      `yaml:"user_agents"`
+	TelegramBotToken      string        `yaml:"telegram_bot_token"`  // Bot API token used by telegram sources without their own
+	GitHubToken           string        `yaml:"github_token"`        // API token used by github sources without their own
+	HealthFile            string        `yaml:"health_file"`         // Per-source statistics kept between runs, empty to disable
+	DisableAfter          int           `yaml:"disable_after"`       // Skip sources without working proxies for this many runs, 0 to never skip
+	SourcesDir            string        `yaml:"sources_dir"`         // Directory of the source lists
+	AllowCIDR             bool          `yaml:"allow_cidr"`          // Allow cidr sources, which expand into every address and port of their ranges
+	CIDRMaxCandidates     int           `yaml:"cidr_max_candidates"` // Maximum candidates a cidr source expands into
+	Dedup                 string        `yaml:"dedup"`               // Deduplication of scraped proxies: exact, fingerprint or bloom, see DedupExact
+	DedupCapacity         int           `yaml:"dedup_capacity"`      // Proxies expected per protocol, sizing the Bloom filter of dedup: bloom
 }
 
 // CheckerConfig defines settings for proxy checking
 type CheckerConfig struct {
-	Timeout          time.Duration `yaml:"timeout"`
-	ConnectTimeout   time.Duration `yaml:"connect_timeout"`
-	Concurrent       int           `yaml:"concurrent"`
-	ConcurrentHTTP   int           `yaml:"concurrent_http"`
-	ConcurrentSOCKS5 int           `yaml:"concurrent_socks5"`
-	CheckURLs        []string      `yaml:"check_urls"`
-	TestURL          string        `yaml:"test_url"`
-	UserAgent        string        `yaml:"user_agent"`
-	StrictCheck      bool          `yaml:"strict_check"`      // Enable strict checking mode
-	DetailedOutput   bool          `yaml:"detailed_output"`   // Enable detailed output (only works with strict_check)
-}
-
-// LoadConfig loads the configuration from a YAML file
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	Timeout              time.Duration        `yaml:"timeout"`
+	ConnectTimeout       time.Duration        `yaml:"connect_timeout"`
+	Concurrent           int                  `yaml:"concurrent"`
+	ConcurrentHTTP       int                  `yaml:"concurrent_http"`
+	ConcurrentSOCKS5     int                  `yaml:"concurrent_socks5"`
+	ConcurrentAuto       int                  `yaml:"concurrent_auto"`         // Concurrent checks of proxies of unknown protocol
+	DetectProtocol       bool                 `yaml:"detect_protocol"`         // Detect the protocol of every proxy instead of trusting its source
+	MaxRequestsPerSecond float64              `yaml:"max_requests_per_second"` // Limit of check requests per second across all workers, 0 for no limit
+	MaxOpenSockets       int                  `yaml:"max_open_sockets"`        // Limit of sockets open at once across all workers, 0 for no limit
+	MaxChecksPerHost     int                  `yaml:"max_checks_per_host"`     // Limit of checks of proxies of the same host at once, 0 for no limit
+	Shuffle              bool                 `yaml:"shuffle"`                 // Check proxies in random order instead of the order of their sources
+	BatchSize            int                  `yaml:"batch_size"`              // Proxies checked per batch across all workers, 0 to check them all at once
+	BatchPause           time.Duration        `yaml:"batch_pause"`             // Pause between batches
+	UpstreamProxy        string               `yaml:"upstream_proxy"`          // Proxy URL all check connections go through, or env for HTTPS_PROXY/HTTP_PROXY/ALL_PROXY
+	VantagePoints        []VantagePointConfig `yaml:"vantage_points"`          // Local addresses each proxy is checked from, empty for the default route only
+	VantageMode          string               `yaml:"vantage_mode"`            // any: working if it works from a vantage point, all: from all of them
+	CheckURLs            []string             `yaml:"check_urls"`
+	TestURL              string               `yaml:"test_url"`
+	UserAgent            string               `yaml:"user_agent"`
+	StrictCheck          bool                 `yaml:"strict_check"`         // Enable strict checking mode
+	DetailedOutput       bool                 `yaml:"detailed_output"`      // Enable detailed output (only works with strict_check)
+	MaxLatency           time.Duration        `yaml:"max_latency"`          // Slower proxies are not working, 0 for no limit (2s by default in strict mode)
+	MinAnonymity         string               `yaml:"min_anonymity"`        // Minimum anonymity level: transparent, anonymous or elite (strict_check only)
+	NetworkClass         string               `yaml:"network_class"`        // Only keep datacenter or residential exit IPs, empty for both (strict_check only)
+	CountriesAllow       []string             `yaml:"countries_allow"`      // Only keep proxies exiting in these ISO country codes
+	CountriesDeny        []string             `yaml:"countries_deny"`       // Drop proxies exiting in these ISO country codes
+	Retries              int                  `yaml:"retries"`              // Extra attempts before a proxy is declared dead
+	RetryDelay           time.Duration        `yaml:"retry_delay"`          // Delay before the first retry, doubled for each further one
+	CheckDeadline        time.Duration        `yaml:"check_deadline"`       // Total time of all requests checking a proxy, retries included, 0 for no limit
+	ResolveHostnames     bool                 `yaml:"resolve_hostnames"`    // Replace proxy hostnames by their resolved IP in results
+	Resolver             string               `yaml:"resolver"`             // Nameserver of check connections: host[:port], udp://, tcp://, tls:// or a DNS-over-HTTPS https:// URL, empty for the system resolver
+	DNSCacheTTL          time.Duration        `yaml:"dns_cache_ttl"`        // How long the addresses of resolved hostnames are cached
+	ExitIPDedup          string               `yaml:"exit_ip_dedup"`        // Proxies sharing an exit IP: annotate or collapse to the fastest, empty to keep all (strict_check only)
+	TorExits             string               `yaml:"tor_exits"`            // Proxies exiting through Tor: flag or exclude, empty to skip detection
+	TorExitListURL       string               `yaml:"tor_exit_list_url"`    // List of Tor exit IPs, cached in the output directory
+	TLSCheck             string               `yaml:"tls_check"`            // Proxies intercepting TLS: flag or exclude, empty to skip the probe
+	TLSCheckURL          string               `yaml:"tls_check_url"`        // https:// URL requested through working proxies by the TLS probe
+	TLSPins              []string             `yaml:"tls_pins"`             // Base64 SHA-256 pins of public keys expected in the chain of tls_check_url
+	ContentCheck         string               `yaml:"content_check"`        // Proxies modifying content: flag or exclude, empty to skip the probe
+	ContentCheckURL      string               `yaml:"content_check_url"`    // http:// test resource requested through working proxies by the content probe
+	ContentSHA256        string               `yaml:"content_sha256"`       // Hex SHA-256 digest of the test resource, empty to fetch it directly once
+	ContentHeaders       []string             `yaml:"content_headers"`      // Response headers that must come through unmodified
+	UDPCheck             bool                 `yaml:"udp_check"`            // Test whether working SOCKS5 proxies relay UDP
+	UDPDNSServer         string               `yaml:"udp_dns_server"`       // DNS server queried through the UDP relay by the UDP probe
+	CapabilityCheck      bool                 `yaml:"capability_check"`     // Test whether working proxies support keep-alive and HTTP/2
+	HTTP2CheckURL        string               `yaml:"http2_check_url"`      // https:// URL requested through working proxies by the HTTP/2 probe
+	CloudflareCheck      bool                 `yaml:"cloudflare_check"`     // Test whether working proxies get through Cloudflare without a block or challenge
+	CloudflareCheckURL   string               `yaml:"cloudflare_check_url"` // Site fronted by Cloudflare requested through working proxies by the Cloudflare probe
+	SearchCheck          bool                 `yaml:"search_check"`         // Test whether search engines answer working proxies without a captcha
+	SearchEngines        []SearchEngineConfig `yaml:"search_engines"`       // Search engines queried by the search check, see DefaultSearchEngines
+	CaptureHeaders       []string             `yaml:"capture_headers"`      // Headers seen through working proxies recorded in results, e.g. Via, X-Cache, Proxy-Agent, Server
+	Steps                []string             `yaml:"steps"`                // Check steps run in order on every proxy, starting with connectivity, see DefaultCheckSteps
+	Fingerprint          bool                 `yaml:"fingerprint"`          // Reject ports clearly not running a proxy before the full checks
+	Prefilter            bool                 `yaml:"prefilter"`            // Skip proxies whose port does not accept a connection before checking
+	PrefilterTimeout     time.Duration        `yaml:"prefilter_timeout"`    // Connection timeout of the pre-filter
+	PrefilterConcurrent  int                  `yaml:"prefilter_concurrent"` // Concurrent connections of the pre-filter
+	IPv6                 string               `yaml:"ipv6"`                 // IPv6 proxies: auto (skip without IPv6 route), on or off
+	BandwidthURL         string               `yaml:"bandwidth_url"`        // Payload downloaded through working proxies to measure throughput, empty to disable
+	BandwidthBytes       int64                `yaml:"bandwidth_bytes"`      // Maximum number of bytes downloaded by the bandwidth test
+	IPLookupURL          string               `yaml:"ip_lookup_url"`        // Service returning the exit IP and location (strict_check only)
+	JudgeURLs            []string             `yaml:"judge_urls"`           // Judges echoing request headers, used in rotation (strict_check only)
+	JudgeCheckInterval   time.Duration        `yaml:"judge_check_interval"` // How often judges are requested directly, those down being skipped (strict_check only)
+	Targets              []TargetConfig       `yaml:"targets"`              // Sites each working proxy is tested against
+}
+
+// TargetConfig defines a site that working proxies are validated against
+type TargetConfig struct {
+	Name         string `yaml:"name"`          // Used to tag results and name output files, defaults to the URL host
+	URL          string `yaml:"url"`           // URL requested through the proxy
+	ExpectStatus []int  `yaml:"expect_status"` // Accepted status codes, default 200
+	ExpectBody   string `yaml:"expect_body"`   // Substring the response body must contain
+}
+
+// SearchEngineConfig defines a search engine that working proxies are
+// checked against for captcha walls and bans
+type SearchEngineConfig struct {
+	Name        string   `yaml:"name"`         // Used to tag results, defaults to the URL host
+	URL         string   `yaml:"url"`          // Search query requested through the proxy
+	BanPatterns []string `yaml:"ban_patterns"` // Regular expressions matching the final URL or body of captcha and ban pages
+}
+
+// VantagePointConfig defines a local address proxies are checked from
+type VantagePointConfig struct {
+	Name      string `yaml:"name"`      // Used to tag results, defaults to the address or interface
+	Address   string `yaml:"address"`   // Local IP connections are opened from
+	Interface string `yaml:"interface"` // Network interface whose first address connections are opened from, instead of address
+}
+
+// OutputConfig defines settings for result output files
+type OutputConfig struct {
+	Dir            string        `yaml:"dir"`              // Directory of the output files
+	CSV            bool          `yaml:"csv"`              // Also write results to <dir>/proxies.csv
+	CSVColumns     []string      `yaml:"csv_columns"`      // Columns of the CSV file, in order
+	SplitByCountry bool          `yaml:"split_by_country"` // Also write proxies to <dir>/by_country/<country code>_<type>.txt
+	Sort           string        `yaml:"sort"`             // Order of the output files once checking ends: latency, country or none
+	URIScheme      bool          `yaml:"uri_scheme"`       // Write proxies as <type>://host:port, e.g. socks5://1.2.3.4:1080
+	Format         []string      `yaml:"format"`           // Client configurations rendered once checking ends: clash, v2ray, surge, proxychains
+	PACProxies     int           `yaml:"pac_proxies"`      // Write <dir>/proxy.pac balancing across this many fastest proxies, 0 to disable
+	PACTemplate    string        `yaml:"pac_template"`     // text/template of proxy.pac, empty for the built-in one
+	TopProxies     int           `yaml:"top_proxies"`      // Write <dir>/top.txt with this many best-scored proxies, 0 to disable
+	TargetMatrix   bool          `yaml:"target_matrix"`    // Write <dir>/target_matrix.json and .csv with the checker.targets each proxy passed
+	Timestamps     bool          `yaml:"timestamps"`       // Append the check and expiry times to each line of the text output files
+	TTL            time.Duration `yaml:"ttl"`              // Time after which a result should be checked again, 0 for no expiry
+	WriteMode      string        `yaml:"write_mode"`       // atomic: replace the files once checking ends, append: write proxies as they are validated
+	KeepHistory    int           `yaml:"keep_history"`     // Previous result sets kept in <dir>/history/<time>/, 0 to keep none
+	Upload         UploadConfig  `yaml:"upload"`           // Upload of the output files to S3-compatible storage
+}
+
+// UploadConfig defines settings for uploading the output files to an
+// S3-compatible bucket once checking ends
+type UploadConfig struct {
+	Endpoint  string `yaml:"endpoint"`   // S3 endpoint, e.g. https://s3.eu-central-1.amazonaws.com or http://minio:9000; empty to disable
+	Region    string `yaml:"region"`     // Region requests are signed for, default us-east-1
+	Bucket    string `yaml:"bucket"`     // Bucket receiving the files
+	Prefix    string `yaml:"prefix"`     // Prefix of the object keys, e.g. proxies/
+	AccessKey string `yaml:"access_key"` // Access key ID, empty for anonymous uploads
+	SecretKey string `yaml:"secret_key"` // Secret access key
+}
+
+// ScoreConfig defines the weights of the composite score of working
+// proxies, from 0 to 100 before the country weight, which ranks top.txt
+type ScoreConfig struct {
+	Latency   float64            `yaml:"latency"`   // Weight of the latency, relative to checker.max_latency or else checker.timeout
+	Anonymity float64            `yaml:"anonymity"` // Weight of the anonymity level, measured in strict mode
+	Stability float64            `yaml:"stability"` // Weight of the stability across runs, requires store.path
+	Countries map[string]float64 `yaml:"countries"` // Factors of the score per exit country code, 1 for countries not listed
+}
+
+// DaemonConfig defines settings for running as a daemon, when a rotating
+// server, the API or the gRPC service is enabled
+type DaemonConfig struct {
+	Interval time.Duration `yaml:"interval"` // Time between the end of a check and the next scrape and check, 0 to check once
+}
+
+// ServerConfig defines settings for the built-in rotating proxy server
+type ServerConfig struct {
+	HTTPListen   string `yaml:"http_listen"`   // Address of the HTTP proxy listener, empty to disable
+	SOCKS5Listen string `yaml:"socks5_listen"` // Address of the SOCKS5 listener, empty to disable
+	Retries      int    `yaml:"retries"`       // Upstream proxies tried per client connection
+	MaxFailures  int    `yaml:"max_failures"`  // Consecutive failures before a proxy is evicted
+}
+
+// Enabled reports whether any rotating server listener is configured
+func (s ServerConfig) Enabled() bool {
+	return s.HTTPListen != "" || s.SOCKS5Listen != ""
+}
+
+// APIConfig defines settings for the REST API
+type APIConfig struct {
+	Listen string `yaml:"listen"` // Address of the API listener, empty to disable
+}
+
+// GRPCConfig defines settings for the gRPC service
+type GRPCConfig struct {
+	Listen   string `yaml:"listen"`    // Address of the gRPC listener, empty to disable
+	Token    string `yaml:"token"`     // Token clients must send as "authorization: Bearer <token>", empty to accept any client
+	CertFile string `yaml:"cert_file"` // TLS certificate, empty to serve without TLS
+	KeyFile  string `yaml:"key_file"`  // TLS private key of cert_file
+}
+
+// DistributedConfig defines settings for checking proxies on remote
+// instances serving the gRPC service
+type DistributedConfig struct {
+	Workers          []string `yaml:"workers"`            // gRPC addresses of the workers, host:port; empty to check locally
+	Token            string   `yaml:"token"`              // grpc.token of the workers
+	TLS              bool     `yaml:"tls"`                // Connect to the workers over TLS
+	BatchSize        int      `yaml:"batch_size"`         // Proxies sent to a worker at once
+	BatchesPerWorker int      `yaml:"batches_per_worker"` // Batches each worker checks at once
+	MaxFailures      int      `yaml:"max_failures"`       // Failed batches in a row after which a worker is dropped
+}
+
+// GeoIPConfig defines settings for offline geolocation
+type GeoIPConfig struct {
+	Database    string `yaml:"database"`     // Path to a GeoLite2-City or GeoLite2-Country mmdb file
+	ASNDatabase string `yaml:"asn_database"` // Path to a GeoLite2-ASN mmdb file
+}
+
+// ReputationConfig defines settings for checking exit IPs against blocklists
+type ReputationConfig struct {
+	DNSBLZones []string `yaml:"dnsbl_zones"` // DNSBL zones queried for each exit IP, e.g. zen.spamhaus.org
+	Blocklist  string   `yaml:"blocklist"`   // File of blocked IPs and CIDR ranges, one per line
+	Action     string   `yaml:"action"`      // Listed proxies: tag or drop
+}
+
+// Enabled reports whether any blocklist is configured
+func (r ReputationConfig) Enabled() bool {
+	return len(r.DNSBLZones) > 0 || r.Blocklist != ""
+}
+
+// RiskConfig defines the heuristics flagging proxies that may be honeypots
+// or tamper with traffic
+type RiskConfig struct {
+	Enabled      bool   `yaml:"enabled"`        // Flag risky proxies and score their risk from 0 to 100
+	Ports        []int  `yaml:"ports"`          // Ports of well-known backdoors probed on proxy hosts, empty to skip the probe
+	MinOpenPorts int    `yaml:"min_open_ports"` // Open ports among ports that flag a proxy
+	HoneypotASNs string `yaml:"honeypot_asns"`  // File of AS numbers known to run honeypots, one per line
+	MaxRisk      int    `yaml:"max_risk"`       // Proxies scoring more are not working, 0 to keep them all
+}
+
+// StoreConfig defines settings for the proxy history database
+type StoreConfig struct {
+	Path         string  `yaml:"path"`          // Path to the SQLite history database, empty to disable
+	MinStability float64 `yaml:"min_stability"` // Percentage of checks a proxy must have passed across runs to be working
+	StableRuns   int     `yaml:"stable_runs"`   // Write proxies that passed this many runs in a row to stable.txt, 0 to disable
+}
+
+// RedisConfig defines settings for publishing working proxies to a Redis
+// pool shared with other instances and consumers
+type RedisConfig struct {
+	URL    string        `yaml:"url"`    // redis://[user:password@]host[:port][/db], or rediss:// over TLS; empty to disable
+	Prefix string        `yaml:"prefix"` // Prefix of the keys
+	TTL    time.Duration `yaml:"ttl"`    // Time a proxy stays in the pool unless checked again, defaults to output.ttl or 1h
+}
+
+// NotifyConfig defines settings for delivering run results once checking ends
+type NotifyConfig struct {
+	TelegramBotToken  string `yaml:"telegram_bot_token"`  // Bot API token, defaults to scraper.telegram_bot_token
+	TelegramChatID    string `yaml:"telegram_chat_id"`    // Chat receiving the run summary, e.g. -1001234567890 or @channel; empty to disable
+	TelegramSendFiles bool   `yaml:"telegram_send_files"` // Also upload the output files to the chat
+}
+
+// LogConfig defines settings for the log file
+type LogConfig struct {
+	Level      string `yaml:"level"`       // debug, info, warn or error
+	Format     string `yaml:"format"`      // text or json
+	File       string `yaml:"file"`        // Path of the log file
+	MaxSizeMB  int    `yaml:"max_size_mb"` // Size at which the log file is rotated, 0 disables rotation
+	MaxBackups int    `yaml:"max_backups"` // Number of rotated log files to keep
+}
+
+// LoadConfig loads the configuration from a YAML file. Overrides, such as
+// ApplyEnv or command line flags, are applied in order to the values read
+// from the file, before defaults are filled in and the result is validated.
+// Keys unknown to Config are rejected, so that typos are not ignored.
+func LoadConfig(path string, overrides ...func(*Config) error) (*Config, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
 	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
+	if err := decodeYAML(file, &config); err != nil {
 		return nil, err
 	}
 
-	// Set default values if not specified
+	for _, override := range overrides {
+		if err := override(&config); err != nil {
+			return nil, err
+		}
+	}
+	config.SetDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks the configuration once defaults are filled in. Errors
+// name the offending setting, e.g. "checker.timeout: must not be negative".
+func (config *Config) Validate() error {
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"scraper.timeout", config.Scraper.Timeout},
+		{"scraper.retry_delay", config.Scraper.RetryDelay},
+		{"scraper.jitter", config.Scraper.Jitter},
+		{"checker.timeout", config.Checker.Timeout},
+		{"checker.connect_timeout", config.Checker.ConnectTimeout},
+		{"checker.retry_delay", config.Checker.RetryDelay},
+		{"checker.check_deadline", config.Checker.CheckDeadline},
+		{"checker.prefilter_timeout", config.Checker.PrefilterTimeout},
+		{"checker.max_latency", config.Checker.MaxLatency},
+		{"checker.batch_pause", config.Checker.BatchPause},
+		{"checker.dns_cache_ttl", config.Checker.DNSCacheTTL},
+		{"checker.judge_check_interval", config.Checker.JudgeCheckInterval},
+		{"output.ttl", config.Output.TTL},
+		{"daemon.interval", config.Daemon.Interval},
+		{"redis.ttl", config.Redis.TTL},
+	} {
+		if d.value < 0 {
+			return fmt.Errorf("%s: must not be negative", d.name)
+		}
+	}
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"scraper.concurrent", config.Scraper.Concurrent},
+		{"checker.concurrent", config.Checker.Concurrent},
+		{"checker.concurrent_http", config.Checker.ConcurrentHTTP},
+		{"checker.concurrent_socks5", config.Checker.ConcurrentSOCKS5},
+		{"checker.concurrent_auto", config.Checker.ConcurrentAuto},
+		{"checker.prefilter_concurrent", config.Checker.PrefilterConcurrent},
+		{"server.retries", config.Server.Retries},
+		{"server.max_failures", config.Server.MaxFailures},
+		{"distributed.batch_size", config.Distributed.BatchSize},
+		{"distributed.batches_per_worker", config.Distributed.BatchesPerWorker},
+		{"distributed.max_failures", config.Distributed.MaxFailures},
+	} {
+		if n.value <= 0 {
+			return fmt.Errorf("%s: must be positive", n.name)
+		}
+	}
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"scraper.disable_after", config.Scraper.DisableAfter},
+		{"scraper.retries", config.Scraper.Retries},
+		{"scraper.cidr_max_candidates", config.Scraper.CIDRMaxCandidates},
+		{"scraper.dedup_capacity", config.Scraper.DedupCapacity},
+		{"scraper.host_requests_per_minute", config.Scraper.HostRequestsPerMinute},
+		{"checker.retries", config.Checker.Retries},
+		{"checker.max_open_sockets", config.Checker.MaxOpenSockets},
+		{"checker.max_checks_per_host", config.Checker.MaxChecksPerHost},
+		{"checker.batch_size", config.Checker.BatchSize},
+		{"risk.min_open_ports", config.Risk.MinOpenPorts},
+		{"risk.max_risk", config.Risk.MaxRisk},
+		{"store.stable_runs", config.Store.StableRuns},
+		{"output.pac_proxies", config.Output.PACProxies},
+		{"output.top_proxies", config.Output.TopProxies},
+		{"output.keep_history", config.Output.KeepHistory},
+		{"log.max_size_mb", config.Log.MaxSizeMB},
+		{"log.max_backups", config.Log.MaxBackups},
+	} {
+		if n.value < 0 {
+			return fmt.Errorf("%s: must not be negative", n.name)
+		}
+	}
+	if config.Checker.BandwidthBytes <= 0 {
+		return fmt.Errorf("checker.bandwidth_bytes: must be positive")
+	}
+	if config.Checker.MaxRequestsPerSecond < 0 {
+		return fmt.Errorf("checker.max_requests_per_second: must not be negative")
+	}
+	for _, w := range []struct {
+		name  string
+		value float64
+	}{
+		{"score.latency", config.Score.Latency},
+		{"score.anonymity", config.Score.Anonymity},
+		{"score.stability", config.Score.Stability},
+	} {
+		if w.value < 0 {
+			return fmt.Errorf("%s: must not be negative", w.name)
+		}
+	}
+	for code, factor := range config.Score.Countries {
+		if factor < 0 {
+			return fmt.Errorf("score.countries.%s: must not be negative", code)
+		}
+	}
+	switch config.Scraper.Dedup {
+	case DedupExact, DedupFingerprint, DedupBloom:
+	default:
+		return fmt.Errorf("scraper.dedup: unknown mode %q, expected exact, fingerprint or bloom", config.Scraper.Dedup)
+	}
+	if config.Checker.BatchPause > 0 && config.Checker.BatchSize == 0 {
+		return fmt.Errorf("checker.batch_pause: requires checker.batch_size")
+	}
+	if config.Scraper.DisableAfter > 0 && config.Scraper.HealthFile == "" {
+		return fmt.Errorf("scraper.disable_after: requires scraper.health_file")
+	}
+	if config.Store.MinStability < 0 || config.Store.MinStability > 100 {
+		return fmt.Errorf("store.min_stability: must be a percentage between 0 and 100")
+	}
+	if config.Store.MinStability > 0 && config.Store.Path == "" {
+		return fmt.Errorf("store.min_stability: requires store.path")
+	}
+	if config.Store.StableRuns > 0 && config.Store.Path == "" {
+		return fmt.Errorf("store.stable_runs: requires store.path")
+	}
+	if config.Scraper.UpstreamProxy != "" {
+		if err := validateProxyURL(config.Scraper.UpstreamProxy); err != nil {
+			return fmt.Errorf("scraper.upstream_proxy: %w", err)
+		}
+	}
+	if err := validateCheckSteps(config.Checker.Steps); err != nil {
+		return fmt.Errorf("checker.steps: %w", err)
+	}
+	if _, err := CheckerUpstream(config); err != nil {
+		return err
+	}
+	if _, err := resolveVantagePoints(config.Checker.VantagePoints); err != nil {
+		return fmt.Errorf("checker.vantage_points: %w", err)
+	}
+	switch config.Checker.VantageMode {
+	case "any", "all":
+	default:
+		return fmt.Errorf("checker.vantage_mode: unknown mode %q, expected any or all", config.Checker.VantageMode)
+	}
+	if upload := config.Output.Upload; upload.Endpoint != "" {
+		if err := validateHTTPURL(upload.Endpoint); err != nil {
+			return fmt.Errorf("output.upload.endpoint: %w", err)
+		}
+		if upload.Bucket == "" {
+			return fmt.Errorf("output.upload.bucket: required with output.upload.endpoint")
+		}
+		if (upload.AccessKey == "") != (upload.SecretKey == "") {
+			return fmt.Errorf("output.upload.access_key: must be set together with output.upload.secret_key")
+		}
+	}
+	if (config.GRPC.CertFile == "") != (config.GRPC.KeyFile == "") {
+		return fmt.Errorf("grpc.cert_file: must be set together with grpc.key_file")
+	}
+	for _, worker := range config.Distributed.Workers {
+		if _, _, err := net.SplitHostPort(worker); err != nil {
+			return fmt.Errorf("distributed.workers: invalid address %q, expected host:port", worker)
+		}
+	}
+	if config.Redis.URL != "" {
+		if _, err := newRedisClient(config.Redis.URL); err != nil {
+			return fmt.Errorf("redis.url: %w", err)
+		}
+	}
+	if config.Notify.TelegramChatID != "" && config.Notify.TelegramBotToken == "" {
+		return fmt.Errorf("notify.telegram_chat_id: requires notify.telegram_bot_token or scraper.telegram_bot_token")
+	}
+
+	if _, err := ParseAnonymityLevel(config.Checker.MinAnonymity); err != nil {
+		return fmt.Errorf("checker.min_anonymity: %w", err)
+	}
+	if _, err := ParseNetworkClass(config.Checker.NetworkClass); err != nil {
+		return fmt.Errorf("checker.network_class: %w", err)
+	}
+	if _, err := ParseLogLevel(config.Log.Level); err != nil {
+		return fmt.Errorf("log.level: %w", err)
+	}
+	if config.Log.Format != "text" && config.Log.Format != "json" {
+		return fmt.Errorf("log.format: unknown format %q, expected text or json", config.Log.Format)
+	}
+	switch config.Checker.IPv6 {
+	case "auto", "on", "off":
+	default:
+		return fmt.Errorf("checker.ipv6: unknown mode %q, expected auto, on or off", config.Checker.IPv6)
+	}
+	switch config.Checker.ExitIPDedup {
+	case "", "annotate", "collapse":
+	default:
+		return fmt.Errorf("checker.exit_ip_dedup: unknown mode %q, expected annotate or collapse", config.Checker.ExitIPDedup)
+	}
+	switch config.Reputation.Action {
+	case "tag", "drop":
+	default:
+		return fmt.Errorf("reputation.action: unknown action %q, expected tag or drop", config.Reputation.Action)
+	}
+	switch config.Output.WriteMode {
+	case "atomic", "append":
+	default:
+		return fmt.Errorf("output.write_mode: unknown mode %q, expected atomic or append", config.Output.WriteMode)
+	}
+	switch config.Output.Sort {
+	case "latency", "country", "none":
+	default:
+		return fmt.Errorf("output.sort: unknown order %q, expected latency, country or none", config.Output.Sort)
+	}
+	switch config.Checker.TorExits {
+	case "", "flag", "exclude":
+	default:
+		return fmt.Errorf("checker.tor_exits: unknown mode %q, expected flag or exclude", config.Checker.TorExits)
+	}
+	if err := validateHTTPURL(config.Checker.TorExitListURL); err != nil {
+		return fmt.Errorf("checker.tor_exit_list_url: %w", err)
+	}
+	switch config.Checker.TLSCheck {
+	case "", "flag", "exclude":
+	default:
+		return fmt.Errorf("checker.tls_check: unknown mode %q, expected flag or exclude", config.Checker.TLSCheck)
+	}
+	if u, err := url.Parse(config.Checker.TLSCheckURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("checker.tls_check_url: invalid URL %q, expected https://", config.Checker.TLSCheckURL)
+	}
+	for _, pin := range config.Checker.TLSPins {
+		if sum, err := base64.StdEncoding.DecodeString(pin); err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("checker.tls_pins: invalid pin %q, expected a base64 SHA-256 digest", pin)
+		}
+	}
+	for _, name := range config.Checker.CaptureHeaders {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("checker.capture_headers: empty header name")
+		}
+	}
+	if _, err := newResolver(config.Checker.Resolver, config.Checker.ConnectTimeout); err != nil {
+		return fmt.Errorf("checker.resolver: %w", err)
+	}
+	if _, _, err := net.SplitHostPort(config.Checker.UDPDNSServer); err != nil {
+		return fmt.Errorf("checker.udp_dns_server: %w", err)
+	}
+	if u, err := url.Parse(config.Checker.HTTP2CheckURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("checker.http2_check_url: invalid URL %q, expected https://", config.Checker.HTTP2CheckURL)
+	}
+	if err := validateHTTPURL(config.Checker.CloudflareCheckURL); err != nil {
+		return fmt.Errorf("checker.cloudflare_check_url: %w", err)
+	}
+	switch config.Checker.ContentCheck {
+	case "", "flag", "exclude":
+	default:
+		return fmt.Errorf("checker.content_check: unknown mode %q, expected flag or exclude", config.Checker.ContentCheck)
+	}
+	if u, err := url.Parse(config.Checker.ContentCheckURL); err != nil || u.Scheme != "http" || u.Host == "" {
+		return fmt.Errorf("checker.content_check_url: invalid URL %q, expected http://", config.Checker.ContentCheckURL)
+	}
+	if sum, err := hex.DecodeString(config.Checker.ContentSHA256); err != nil || (len(sum) != 0 && len(sum) != sha256.Size) {
+		return fmt.Errorf("checker.content_sha256: invalid digest %q, expected a hex SHA-256 digest", config.Checker.ContentSHA256)
+	}
+	for _, port := range config.Risk.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("risk.ports: invalid port %d", port)
+		}
+	}
+	if _, err := loadASNList(config.Risk.HoneypotASNs); err != nil {
+		return fmt.Errorf("risk.honeypot_asns: %w", err)
+	}
+	for _, zone := range config.Reputation.DNSBLZones {
+		if strings.Trim(zone, ".") == "" || strings.ContainsAny(zone, " /:") {
+			return fmt.Errorf("reputation.dnsbl_zones: invalid zone %q", zone)
+		}
+	}
+	for _, code := range append(append([]string{}, config.Checker.CountriesAllow...), config.Checker.CountriesDeny...) {
+		if len(code) != 2 {
+			return fmt.Errorf("checker.countries_allow/countries_deny: invalid country code %q, expected two letters such as DE", code)
+		}
+	}
+	for _, format := range config.Output.Format {
+		if _, ok := exporters[format]; !ok {
+			return fmt.Errorf("output.format: unknown format %q, expected clash, v2ray, surge or proxychains", format)
+		}
+	}
+	if _, err := parsePACTemplate(config.Output.PACTemplate); err != nil {
+		return fmt.Errorf("output.pac_template: %w", err)
+	}
+	for _, column := range config.Output.CSVColumns {
+		if _, ok := csvColumns[column]; !ok {
+			return fmt.Errorf("output.csv_columns: unknown column %q", column)
+		}
+	}
+
+	for _, checkURL := range config.Checker.CheckURLs {
+		if err := validateHTTPURL(checkURL); err != nil {
+			return fmt.Errorf("checker.check_urls: %w", err)
+		}
+	}
+	if err := validateHTTPURL(config.Checker.TestURL); err != nil {
+		return fmt.Errorf("checker.test_url: %w", err)
+	}
+	if config.Checker.BandwidthURL != "" {
+		if err := validateHTTPURL(config.Checker.BandwidthURL); err != nil {
+			return fmt.Errorf("checker.bandwidth_url: %w", err)
+		}
+	}
+	if err := validateHTTPURL(config.Checker.IPLookupURL); err != nil {
+		return fmt.Errorf("checker.ip_lookup_url: %w", err)
+	}
+	for _, judgeURL := range config.Checker.JudgeURLs {
+		if err := validateHTTPURL(judgeURL); err != nil {
+			return fmt.Errorf("checker.judge_urls: %w", err)
+		}
+	}
+	targetNames := make(map[string]bool, len(config.Checker.Targets))
+	for _, target := range config.Checker.Targets {
+		if err := validateHTTPURL(target.URL); err != nil {
+			return fmt.Errorf("checker.targets: %w", err)
+		}
+		if !targetNameRe.MatchString(target.Name) {
+			return fmt.Errorf("checker.targets: invalid name %q, expected letters, digits, '.', '_' and '-' not starting with '.'", target.Name)
+		}
+		if targetNames[target.Name] {
+			return fmt.Errorf("checker.targets: duplicate name %q", target.Name)
+		}
+		targetNames[target.Name] = true
+		for _, status := range target.ExpectStatus {
+			if status < 100 || status > 599 {
+				return fmt.Errorf("checker.targets: invalid expect_status %d of %q", status, target.Name)
+			}
+		}
+	}
+
+	engineNames := make(map[string]bool, len(config.Checker.SearchEngines))
+	for _, engine := range config.Checker.SearchEngines {
+		if err := validateHTTPURL(engine.URL); err != nil {
+			return fmt.Errorf("checker.search_engines: %w", err)
+		}
+		if !targetNameRe.MatchString(engine.Name) {
+			return fmt.Errorf("checker.search_engines: invalid name %q, expected letters, digits, '.', '_' and '-' not starting with '.'", engine.Name)
+		}
+		if engineNames[engine.Name] {
+			return fmt.Errorf("checker.search_engines: duplicate name %q", engine.Name)
+		}
+		engineNames[engine.Name] = true
+	}
+	if _, err := compileSearchEngines(config.Checker.SearchEngines); err != nil {
+		return fmt.Errorf("checker.search_engines: %w", err)
+	}
+
+	for _, listen := range []struct {
+		name, addr string
+	}{
+		{"server.http_listen", config.Server.HTTPListen},
+		{"server.socks5_listen", config.Server.SOCKS5Listen},
+		{"api.listen", config.API.Listen},
+		{"grpc.listen", config.GRPC.Listen},
+	} {
+		if listen.addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(listen.addr); err != nil {
+			return fmt.Errorf("%s: invalid address %q, expected host:port", listen.name, listen.addr)
+		}
+	}
+
+	// Profiles not selected are only checked for unknown keys and values
+	// of the wrong type
+	for _, name := range slices.Sorted(maps.Keys(config.Profiles)) {
+		if err := config.Profiles[name].apply(name, &Config{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeYAML decodes a YAML document into v, rejecting unknown keys. Every
+// unknown key or invalid value is reported on a single line.
+func decodeYAML(r io.Reader, v any) error {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return errors.New(strings.Join(typeErr.Errors, "; "))
+		}
+		return err
+	}
+	return nil
+}
+
+// ApplyProfile returns an override of LoadConfig applying the settings of
+// the profile name over the scraper and checker sections, or nothing if
+// name is empty. It is meant to come before ApplyEnv, so that environment
+// variables and flags still take precedence over the profile.
+func ApplyProfile(name string) func(*Config) error {
+	return func(config *Config) error {
+		if name == "" {
+			return nil
+		}
+		profile, ok := config.Profiles[name]
+		if !ok {
+			names := slices.Sorted(maps.Keys(config.Profiles))
+			if len(names) == 0 {
+				return fmt.Errorf("profile %q not found, config has no profiles", name)
+			}
+			return fmt.Errorf("profile %q not found, expected one of %s", name, strings.Join(names, ", "))
+		}
+		return profile.apply(name, config)
+	}
+}
+
+// apply decodes the settings of the profile over those of config
+func (p ProfileConfig) apply(name string, config *Config) error {
+	for _, section := range []struct {
+		name  string
+		node  *yaml.Node
+		value any
+	}{
+		{"scraper", &p.Scraper, &config.Scraper},
+		{"checker", &p.Checker, &config.Checker},
+	} {
+		if section.node.IsZero() {
+			continue
+		}
+		// Decoded again from text so that unknown keys are rejected, with
+		// line numbers of that text left out
+		data, err := yaml.Marshal(section.node)
+		if err == nil {
+			err = decodeYAML(bytes.NewReader(data), section.value)
+		}
+		if err != nil {
+			return fmt.Errorf("profiles.%s.%s: %s", name, section.name, profileLineRe.ReplaceAllString(err.Error(), ""))
+		}
+	}
+	return nil
+}
+
+// profileLineRe matches the line numbers of errors decoding a profile
+var profileLineRe = regexp.MustCompile(`(yaml: )?line \d+: `)
+
+// targetNameRe matches target names that are safe to use as directory names
+var targetNameRe = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// validateHTTPURL checks that rawURL is an absolute http or https URL
+func validateHTTPURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q, expected http:// or https://", rawURL)
+	}
+	return nil
+}
+
+// validateProxyURL checks that rawURL is a proxy URL net/http can route
+// requests through
+func validateProxyURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("invalid proxy URL %q, expected http://, https://, socks5:// or socks5h://", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q, missing host", rawURL)
+	}
+	return nil
+}
+
+// DefaultConfig returns a configuration with all default values applied
+func DefaultConfig() *Config {
+	config := &Config{}
+	config.SetDefaults()
+	return config
+}
+
+// SetDefaults fills in default values for fields that are not specified
+func (config *Config) SetDefaults() {
 	if config.Scraper.Timeout == 0 {
 		config.Scraper.Timeout = 10 * time.Second
 	}
@@ -61,6 +816,18 @@ func LoadConfig(path string) (*Config, error) {
 	if config.Scraper.Concurrent == 0 {
 		config.Scraper.Concurrent = 10
 	}
+	if config.Scraper.CIDRMaxCandidates == 0 {
+		config.Scraper.CIDRMaxCandidates = 65536
+	}
+	if config.Scraper.Dedup == "" {
+		config.Scraper.Dedup = DedupExact
+	}
+	if config.Scraper.DedupCapacity == 0 {
+		config.Scraper.DedupCapacity = 10_000_000
+	}
+	if config.Scraper.RetryDelay == 0 {
+		config.Scraper.RetryDelay = time.Second
+	}
 
 	// Checker defaults
 	if config.Checker.Timeout == 0 {
@@ -77,6 +844,9 @@ func LoadConfig(path string) (*Config, error) {
 			config.Checker.ConnectTimeout = 5 * time.Second
 		}
 	}
+	if config.Checker.MaxLatency == 0 && config.Checker.StrictCheck {
+		config.Checker.MaxLatency = 2 * time.Second
+	}
 	if config.Checker.Concurrent == 0 {
 		config.Checker.Concurrent = 100
 	}
@@ -86,6 +856,9 @@ func LoadConfig(path string) (*Config, error) {
 	if config.Checker.ConcurrentSOCKS5 == 0 {
 		config.Checker.ConcurrentSOCKS5 = config.Checker.Concurrent
 	}
+	if config.Checker.ConcurrentAuto == 0 {
+		config.Checker.ConcurrentAuto = config.Checker.Concurrent
+	}
 	if len(config.Checker.CheckURLs) == 0 {
 		config.Checker.CheckURLs = []string{"http://checkip.amazonaws.com"}
 	}
@@ -96,10 +869,179 @@ func LoadConfig(path string) (*Config, error) {
 		config.Checker.UserAgent = config.Scraper.UserAgent
 	}
 
+	if config.Scraper.SourcesDir == "" {
+		config.Scraper.SourcesDir = "sources"
+	}
+	if config.Checker.IPv6 == "" {
+		config.Checker.IPv6 = "auto"
+	}
+	if config.Checker.BandwidthBytes == 0 {
+		config.Checker.BandwidthBytes = 100 * 1024
+	}
+	if config.Checker.IPLookupURL == "" {
+		config.Checker.IPLookupURL = "http://ip-api.com/json"
+	}
+	if len(config.Checker.Steps) == 0 {
+		config.Checker.Steps = slices.Clone(DefaultCheckSteps)
+	}
+	if config.Checker.JudgeCheckInterval == 0 {
+		config.Checker.JudgeCheckInterval = time.Minute
+	}
+	if len(config.Checker.JudgeURLs) == 0 {
+		config.Checker.JudgeURLs = []string{"http://httpbin.org/get"}
+	}
+	if config.Checker.RetryDelay == 0 {
+		config.Checker.RetryDelay = 500 * time.Millisecond
+	}
+	if config.Output.Sort == "" {
+		config.Output.Sort = "latency"
+	}
+	if config.Output.WriteMode == "" {
+		config.Output.WriteMode = "atomic"
+	}
+	if config.Checker.TorExitListURL == "" {
+		config.Checker.TorExitListURL = "https://check.torproject.org/torbulkexitlist"
+	}
+	if config.Checker.TLSCheckURL == "" {
+		config.Checker.TLSCheckURL = "https://www.google.com/generate_204"
+	}
+	if config.Checker.ContentCheckURL == "" {
+		config.Checker.ContentCheckURL = "http://example.com/"
+	}
+	if config.Checker.PrefilterTimeout == 0 {
+		config.Checker.PrefilterTimeout = time.Second
+	}
+	if config.Checker.PrefilterConcurrent == 0 {
+		config.Checker.PrefilterConcurrent = 2000
+	}
+	if config.Checker.HTTP2CheckURL == "" {
+		config.Checker.HTTP2CheckURL = "https://www.google.com/generate_204"
+	}
+	if config.Checker.CloudflareCheckURL == "" {
+		config.Checker.CloudflareCheckURL = "https://www.cloudflare.com/"
+	}
+	if config.Checker.VantageMode == "" {
+		config.Checker.VantageMode = "any"
+	}
+	if config.Checker.DNSCacheTTL == 0 {
+		config.Checker.DNSCacheTTL = 5 * time.Minute
+	}
+	if config.Checker.UDPDNSServer == "" {
+		config.Checker.UDPDNSServer = "8.8.8.8:53"
+	}
+	if config.Checker.ContentHeaders == nil {
+		config.Checker.ContentHeaders = []string{"Content-Type"}
+	}
+	if config.Reputation.Action == "" {
+		config.Reputation.Action = "tag"
+	}
+	if config.Risk.MinOpenPorts == 0 {
+		config.Risk.MinOpenPorts = 3
+	}
+	if config.Output.Upload.Region == "" {
+		config.Output.Upload.Region = "us-east-1"
+	}
+	if config.Notify.TelegramBotToken == "" {
+		config.Notify.TelegramBotToken = config.Scraper.TelegramBotToken
+	}
+	for i := range config.Checker.Targets {
+		target := &config.Checker.Targets[i]
+		if target.Name == "" {
+			if u, err := url.Parse(target.URL); err == nil {
+				target.Name = strings.ReplaceAll(u.Host, ":", "_")
+			}
+		}
+		if len(target.ExpectStatus) == 0 {
+			target.ExpectStatus = []int{200}
+		}
+	}
+	if len(config.Checker.SearchEngines) == 0 {
+		config.Checker.SearchEngines = slices.Clone(DefaultSearchEngines)
+	}
+	for i := range config.Checker.SearchEngines {
+		engine := &config.Checker.SearchEngines[i]
+		if engine.Name == "" {
+			if u, err := url.Parse(engine.URL); err == nil {
+				engine.Name = strings.ReplaceAll(u.Host, ":", "_")
+			}
+		}
+	}
+	for i, code := range config.Checker.CountriesAllow {
+		config.Checker.CountriesAllow[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+	for i, code := range config.Checker.CountriesDeny {
+		config.Checker.CountriesDeny[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+
+	// Output defaults
+	if config.Output.Dir == "" {
+		config.Output.Dir = "out"
+	}
+	if len(config.Output.CSVColumns) == 0 {
+		config.Output.CSVColumns = slices.Clone(DefaultCSVColumns)
+	}
+
+	// Score defaults: all components weigh the same unless one is set
+	if config.Score.Latency == 0 && config.Score.Anonymity == 0 && config.Score.Stability == 0 {
+		config.Score.Latency, config.Score.Anonymity, config.Score.Stability = 1, 1, 1
+	}
+	if len(config.Score.Countries) > 0 {
+		countries := make(map[string]float64, len(config.Score.Countries))
+		for code, factor := range config.Score.Countries {
+			countries[strings.ToUpper(strings.TrimSpace(code))] = factor
+		}
+		config.Score.Countries = countries
+	}
+
+	// Distributed checking defaults
+	if config.Distributed.BatchSize == 0 {
+		config.Distributed.BatchSize = 500
+	}
+	if config.Distributed.BatchesPerWorker == 0 {
+		config.Distributed.BatchesPerWorker = 2
+	}
+	if config.Distributed.MaxFailures == 0 {
+		config.Distributed.MaxFailures = 3
+	}
+
+	// Redis defaults
+	if config.Redis.Prefix == "" {
+		config.Redis.Prefix = "proxies:"
+	}
+	if config.Redis.TTL == 0 {
+		config.Redis.TTL = config.Output.TTL
+	}
+	if config.Redis.TTL == 0 {
+		config.Redis.TTL = time.Hour
+	}
+
+	// Server defaults
+	if config.Server.Retries == 0 {
+		config.Server.Retries = 3
+	}
+	if config.Server.MaxFailures == 0 {
+		config.Server.MaxFailures = 3
+	}
+
+	// Log defaults
+	if config.Log.Level == "" {
+		config.Log.Level = "info"
+	}
+	if config.Log.Format == "" {
+		config.Log.Format = "text"
+	}
+	if config.Log.File == "" {
+		config.Log.File = "proxy_checker.log"
+	}
+	if config.Log.MaxSizeMB == 0 {
+		config.Log.MaxSizeMB = 10
+	}
+	if config.Log.MaxBackups == 0 {
+		config.Log.MaxBackups = 3
+	}
+
 	// DetailedOutput works only with StrictCheck
 	if !config.Checker.StrictCheck {
 		config.Checker.DetailedOutput = false
 	}
-
-	return &config, nil
-} 
\ No newline at end of file
+}
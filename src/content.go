@@ -0,0 +1,123 @@
+package src
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// contentReference is the test resource of the content check as served
+// without a proxy
+type contentReference struct {
+	sum     string      // Hex SHA-256 digest of the body, checker.content_sha256 if set
+	headers http.Header // checker.content_headers of the response
+}
+
+// contentReference returns the reference the responses of proxies are
+// compared with. It is fetched without a proxy, but through
+// checker.upstream_proxy if set, once on first use.
+func (c *ProxyChecker) contentReference(ctx context.Context) (*contentReference, error) {
+	c.contentOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.Checker.Timeout)
+		defer cancel()
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(c.upstream)}}
+		defer client.CloseIdleConnections()
+		sum, headers, err := c.fetchContent(ctx, client)
+		if err != nil {
+			if c.config.Checker.ContentSHA256 == "" {
+				c.contentErr = err
+				return
+			}
+			slog.Warn("Error fetching content check reference, comparing bodies only", "error", err)
+		}
+		if c.config.Checker.ContentSHA256 != "" {
+			sum = strings.ToLower(c.config.Checker.ContentSHA256)
+		}
+		c.content = &contentReference{sum: sum, headers: headers}
+	})
+	return c.content, c.contentErr
+}
+
+// fetchContent requests checker.content_check_url with client and returns
+// the hex SHA-256 digest of the body and the checker.content_headers of the
+// response
+func (c *ProxyChecker) fetchContent(ctx context.Context, client *http.Client) (string, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Checker.ContentCheckURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	// Compressed bodies are compared as sent, not as decoded by the client
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := c.do(client, req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, maxProbeBody)); err != nil {
+		return "", nil, err
+	}
+	headers := make(http.Header)
+	for _, name := range c.config.Checker.ContentHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			headers[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), headers, nil
+}
+
+// probeContent requests checker.content_check_url through a working proxy
+// and compares the response with the reference: a proxy that injects
+// content, rewrites the body or strips one of checker.content_headers is
+// not clean. Proxies that are not clean are marked as not working with
+// checker.content_check: exclude. A proxy failing the request is not
+// clean either.
+func (c *ProxyChecker) probeContent(ctx context.Context, client *http.Client, result *CheckResult) {
+	if c.config.Checker.ContentCheck == "" {
+		return
+	}
+	reference, err := c.contentReference(ctx)
+	if err != nil {
+		slog.Debug("Skipping content check without reference", "proxy", result.Proxy, "error", err)
+		return
+	}
+
+	sum, headers, err := c.fetchContent(ctx, client)
+	switch {
+	case err != nil:
+		slog.Debug("Content check failed", "proxy", result.Proxy, "error", err)
+	case sum != reference.sum:
+		slog.Debug("Proxy modifies content", "proxy", result.Proxy, "sha256", sum)
+		c.flagRisk(result, RiskTampered)
+	case reference.headers != nil && !sameHeaders(headers, reference.headers):
+		slog.Debug("Proxy modifies headers", "proxy", result.Proxy, "headers", headers)
+		c.flagRisk(result, RiskTampered)
+	default:
+		result.Clean = true
+	}
+	if !result.Clean && c.config.Checker.ContentCheck == "exclude" {
+		result.Working = false
+	}
+}
+
+// sameHeaders reports whether got has the same values as want for every
+// header of want
+func sameHeaders(got, want http.Header) bool {
+	for name, values := range want {
+		if strings.Join(got[name], ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
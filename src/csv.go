@@ -0,0 +1,97 @@
+package src
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// isCSV reports whether a response is a CSV document: served as text/csv,
+// or made of rows of two fields or more, all with the same number of fields
+func isCSV(contentType string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "csv") {
+		return true
+	}
+	// Rows with a different number of fields make ReadAll fail
+	records, err := csvReader(body).ReadAll()
+	return err == nil && len(records) > 0 && len(records[0]) >= 2
+}
+
+// csvReader returns a reader of the records of a CSV document. Fields are
+// separated by commas, semicolons or tabs, whichever the first line has most
+// of.
+func csvReader(body []byte) *csv.Reader {
+	first, _, _ := bytes.Cut(bytes.TrimSpace(body), []byte("\n"))
+	delimiter, most := ',', 0
+	for _, d := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(first, []byte(string(d))); n > most {
+			delimiter, most = d, n
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = delimiter
+	r.TrimLeadingSpace = true
+	r.LazyQuotes = true
+	return r
+}
+
+// extractCSVProxies extracts proxies from a CSV document. With a header
+// naming IP and port columns, e.g. ip,port,country, the proxies are read
+// from these columns, with the credentials of username and password
+// columns if any. Otherwise each row is searched like a table row.
+func extractCSVProxies(body []byte) ([]string, error) {
+	r := csvReader(body)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		if kind := fieldKind(name); kind != "" {
+			if _, ok := columns[kind]; !ok {
+				columns[kind] = i
+			}
+		}
+	}
+	ipColumn, hasIP := columns["ip"]
+	portColumn, hasPort := columns["port"]
+
+	var proxies []string
+	for _, record := range records {
+		if !hasIP || !hasPort {
+			if proxy, ok := proxyFromCells(record); ok {
+				proxies = append(proxies, proxy)
+			}
+			continue
+		}
+		proxy, ok := proxyFromIPPort(csvField(record, ipColumn), csvField(record, portColumn))
+		if !ok {
+			// The header, or a malformed row
+			continue
+		}
+		user, hasUser := columns["username"]
+		pass, hasPass := columns["password"]
+		if hasUser && hasPass && csvField(record, user) != "" && csvField(record, pass) != "" {
+			if proxy, ok = isValidProxy(csvField(record, user) + ":" + csvField(record, pass) + "@" + proxy); !ok {
+				continue
+			}
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+// csvField returns field i of a record, "" for a short record
+func csvField(record []string, i int) string {
+	if i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
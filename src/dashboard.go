@@ -0,0 +1,279 @@
+package src
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of the proxies and messages kept by the dashboard
+const (
+	dashboardRecent   = 100
+	dashboardMessages = 1000
+)
+
+// Dashboard is a full-screen view of the scraping and checking progress: a
+// progress bar per protocol, the proxies validated last with their country
+// and latency, and the messages printed on stdout, which it captures while
+// running and prints again once stopped. It implements Display. All methods
+// are safe to call on a nil *Dashboard.
+type Dashboard struct {
+	mu       sync.Mutex
+	out      *os.File // Terminal the dashboard is drawn on
+	started  time.Time
+	scrape   []ScrapeStatus // In the order protocols were scraped
+	progress *Progress      // Nil until checking starts
+	recent   []CheckResult  // Working proxies, newest last
+	messages []string
+
+	capture  *os.File      // Write end of the pipe replacing stdout
+	captured chan struct{} // Closed once the captured output is read
+	done     chan struct{}
+	drawn    chan struct{} // Closed once the draw loop exits
+}
+
+// NewDashboard returns a dashboard drawn on the terminal f, usually
+// os.Stdout. It fails if f is not a terminal supporting ANSI escape
+// sequences.
+func NewDashboard(f *os.File) (*Dashboard, error) {
+	if !NewTerminal(f).ANSI() {
+		return nil, errors.New("output is not an ANSI terminal")
+	}
+	return &Dashboard{out: f}, nil
+}
+
+// Start switches the terminal to the dashboard and captures stdout until
+// Stop is called
+func (d *Dashboard) Start() error {
+	if d == nil {
+		return nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	d.capture = w
+	d.captured = make(chan struct{})
+	d.done = make(chan struct{})
+	d.drawn = make(chan struct{})
+	d.started = time.Now()
+	os.Stdout = w
+
+	go func() {
+		defer close(d.captured)
+		defer r.Close()
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				d.addMessages(line)
+			}
+		}
+	}()
+
+	// Switch to the alternate screen and hide the cursor
+	d.out.WriteString("\033[?1049h\033[?25l")
+	go func() {
+		defer close(d.drawn)
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			d.draw()
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop restores the terminal and stdout, then prints the captured messages
+func (d *Dashboard) Stop() {
+	if d == nil || d.done == nil {
+		return
+	}
+
+	close(d.done)
+	<-d.drawn
+	os.Stdout = d.out
+	d.capture.Close()
+	<-d.captured
+	d.out.WriteString("\033[?25h\033[?1049l")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, message := range d.messages {
+		fmt.Fprintln(d.out, message)
+	}
+}
+
+// AddResult lists a proxy among the recently validated ones if it works
+func (d *Dashboard) AddResult(result CheckResult) {
+	if d == nil || !result.Working {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recent = append(d.recent, result)
+	if len(d.recent) > dashboardRecent {
+		d.recent = d.recent[len(d.recent)-dashboardRecent:]
+	}
+}
+
+// ScrapeProgress updates the scraping progress of a protocol
+func (d *Dashboard) ScrapeProgress(status ScrapeStatus, done bool) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	found := false
+	for i := range d.scrape {
+		if d.scrape[i].Protocol == status.Protocol {
+			d.scrape[i] = status
+			found = true
+		}
+	}
+	if !found {
+		d.scrape = append(d.scrape, status)
+	}
+	d.mu.Unlock()
+
+	if done {
+		d.addMessages(fmt.Sprintf("✓ Scraped %d %s proxies [%d/%d]", status.Found, status.Protocol, status.Completed, status.Total))
+	}
+}
+
+// CheckProgress updates the checking progress
+func (d *Dashboard) CheckProgress(progress Progress, done bool) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.progress = &progress
+	d.mu.Unlock()
+
+	if done {
+		d.addMessages(progress.Summary()...)
+	}
+}
+
+// addMessages appends lines to the messages, dropping the oldest ones
+// beyond dashboardMessages
+func (d *Dashboard) addMessages(lines ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages = append(d.messages, lines...)
+	if len(d.messages) > dashboardMessages {
+		d.messages = d.messages[len(d.messages)-dashboardMessages:]
+	}
+}
+
+// draw redraws the whole dashboard
+func (d *Dashboard) draw() {
+	width, height, ok := terminalSize(d.out)
+	if !ok {
+		width, height = 80, 24
+	}
+
+	d.mu.Lock()
+	lines := d.lines(height)
+	d.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(truncate(line, width) + "\033[K")
+	}
+	b.WriteString("\033[J")
+	d.out.WriteString(b.String())
+}
+
+// lines returns the lines of the dashboard for a terminal of the given
+// height. The caller must hold mu.
+func (d *Dashboard) lines(height int) []string {
+	elapsed := time.Since(d.started).Round(time.Second)
+	lines := []string{
+		fmt.Sprintf(" 🚀 Proxy Scraper and Checker - %s elapsed", elapsed),
+		"",
+		" Scraping",
+	}
+	if len(d.scrape) == 0 {
+		lines = append(lines, "   waiting")
+	}
+	for _, s := range d.scrape {
+		lines = append(lines, fmt.Sprintf("   %-7s %s %d/%d sources, %d failed, %d proxies",
+			s.Protocol, ProgressBar(percent(s.Completed, s.Total), 20), s.Completed, s.Total, s.Failed, s.Found))
+	}
+
+	lines = append(lines, "", " Checking")
+	if p := d.progress; p == nil {
+		lines = append(lines, "   waiting")
+	} else {
+		check := func(label string, checked, total, working int) string {
+			return fmt.Sprintf("   %-7s %s %d/%d, %d working", label, ProgressBar(percent(checked, total), 20), checked, total, working)
+		}
+		lines = append(lines,
+			check("HTTP", p.CheckedHTTP, p.TotalHTTP, p.WorkingHTTP),
+			check("SOCKS5", p.CheckedSOCKS5, p.TotalSOCKS5, p.WorkingSOCKS5))
+		if p.TotalAuto > 0 {
+			lines = append(lines, check("Auto", p.CheckedAuto, p.TotalAuto, p.WorkingAuto))
+		}
+	}
+
+	// Split the remaining rows between the recent proxies and the messages
+	messages := min(len(d.messages), 5)
+	rows := height - len(lines) - 4 - messages
+	if len(d.messages) > 0 {
+		rows -= 2
+	}
+	lines = append(lines, "", " Recently validated", fmt.Sprintf("   %-7s %-45s %-7s %s", "TYPE", "PROXY", "COUNTRY", "LATENCY"))
+	for i := len(d.recent) - 1; i >= 0 && i >= len(d.recent)-rows; i-- {
+		result := d.recent[i]
+		country := "-"
+		if result.Location != nil && result.Location.CountryCode != "" {
+			country = result.Location.CountryCode
+		}
+		lines = append(lines, fmt.Sprintf("   %-7s %-45s %-7s %s",
+			result.Type, result.Proxy, country, result.Speed.Round(time.Millisecond)))
+	}
+
+	if messages > 0 {
+		lines = append(lines, "", " Messages")
+		for _, message := range d.messages[len(d.messages)-messages:] {
+			lines = append(lines, "   "+message)
+		}
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// percent returns n as a percentage of total, 0 if total is 0
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// truncate shortens s to at most width characters
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
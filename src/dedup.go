@@ -0,0 +1,95 @@
+package src
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+// Deduplication modes of scraper.dedup
+const (
+	DedupExact       = "exact"       // Proxies compared as strings
+	DedupFingerprint = "fingerprint" // 8-byte hashes of proxies, a collision once in about 10^12 pairs
+	DedupBloom       = "bloom"       // Bloom filter sized by scraper.dedup_capacity, dropping about 0.1% of unique proxies
+)
+
+// bloomErrorRate is the false positive rate of Bloom filters at capacity
+const bloomErrorRate = 0.001
+
+// ProxySet records the proxies seen so far to drop duplicates from large
+// lists. Fingerprints and Bloom filters use a fraction of the memory of the
+// proxies themselves, at the cost of rarely taking a new proxy for a
+// duplicate. A ProxySet is not safe for concurrent use.
+type ProxySet struct {
+	seed   maphash.Seed
+	exact  map[string]struct{}
+	hashes map[uint64]struct{}
+	bloom  []uint64 // Bits of the Bloom filter
+	hashK  int      // Bits set per proxy in the Bloom filter
+}
+
+// NewProxySet returns an empty set deduplicating with mode, one of
+// DedupExact, DedupFingerprint or DedupBloom. capacity is the number of
+// proxies expected, which sizes the Bloom filter; past it, its false
+// positive rate grows.
+func NewProxySet(mode string, capacity int) *ProxySet {
+	s := &ProxySet{seed: maphash.MakeSeed()}
+	switch mode {
+	case DedupExact:
+		s.exact = make(map[string]struct{})
+	case DedupBloom:
+		n := float64(max(capacity, 1))
+		bits := math.Ceil(-n * math.Log(bloomErrorRate) / (math.Ln2 * math.Ln2))
+		s.bloom = make([]uint64, (int(bits)+63)/64)
+		s.hashK = max(1, int(math.Round(bits/n*math.Ln2)))
+	default:
+		s.hashes = make(map[uint64]struct{})
+	}
+	return s
+}
+
+// Add adds proxy to the set and reports whether it was not in it yet
+func (s *ProxySet) Add(proxy string) bool {
+	if s.exact != nil {
+		if _, ok := s.exact[proxy]; ok {
+			return false
+		}
+		s.exact[proxy] = struct{}{}
+		return true
+	}
+
+	h := maphash.String(s.seed, proxy)
+	if s.bloom == nil {
+		if _, ok := s.hashes[h]; ok {
+			return false
+		}
+		s.hashes[h] = struct{}{}
+		return true
+	}
+
+	// Bits chosen by double hashing of the two halves of h
+	m := uint64(len(s.bloom)) * 64
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	added := false
+	for i := range uint64(s.hashK) {
+		bit := (h1 + i*h2) % m
+		if s.bloom[bit/64]&(1<<(bit%64)) == 0 {
+			s.bloom[bit/64] |= 1 << (bit % 64)
+			added = true
+		}
+	}
+	return added
+}
+
+// DedupeProxies removes the proxies already in set from proxies, adding the
+// others to it, and returns them in order. The result reuses the backing
+// array of proxies, so that no second copy of a large list is held.
+func DedupeProxies(proxies []string, set *ProxySet) []string {
+	unique := proxies[:0]
+	for _, proxy := range proxies {
+		if set.Add(proxy) {
+			unique = append(unique, proxy)
+		}
+	}
+	clear(proxies[len(unique):])
+	return unique
+}
@@ -0,0 +1,133 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// dialProxy opens a TCP tunnel to addr through a proxy of the given type.
+// HTTP and HTTPS proxies are tunneled with CONNECT. The proxy handshake must
+// complete within timeout, and is aborted if ctx is cancelled.
+func dialProxy(ctx context.Context, dialer contextDialer, proxyType ProxyType, proxyAddr ProxyAddr, addr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr.HostPort())
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+
+	switch proxyType {
+	case ProxyTypeSOCKS5:
+		err = socks5Connect(conn, proxyAddr, addr)
+	case ProxyTypeSOCKS4:
+		err = socks4Connect(conn, proxyAddr, addr)
+	default:
+		err = httpConnect(ctx, conn, proxyAddr, addr)
+	}
+	if !stop() && err != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpConnect asks the HTTP proxy at the other end of conn to open a tunnel
+// to addr, capturing the headers of its answer for the check of ctx
+func httpConnect(ctx context.Context, conn net.Conn, proxyAddr ProxyAddr, addr string) error {
+	connect := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyAddr.HasAuth() {
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyAddr.Username + ":" + proxyAddr.Password))
+		connect.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := connect.Write(conn); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	capturedHeaders(ctx).add(resp.Header)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT refused: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return errors.New("unexpected data after CONNECT response")
+	}
+	return nil
+}
+
+// socks4Connect asks the SOCKS4 proxy at the other end of conn to open a
+// tunnel to addr. Hostnames are sent with the SOCKS4a extension and the
+// proxy username, if any, as user ID.
+func socks4Connect(conn net.Conn, proxyAddr ProxyAddr, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+
+	// VN, CD (CONNECT), DSTPORT, DSTIP, USERID, NUL
+	req := []byte{4, 1}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	ip := net.ParseIP(host)
+	if ip != nil && ip.To4() == nil {
+		return errors.New("SOCKS4 does not support IPv6 destinations")
+	}
+	if ip != nil {
+		req = append(req, ip.To4()...)
+	} else {
+		// SOCKS4a: invalid IP 0.0.0.x followed by the hostname
+		req = append(req, 0, 0, 0, 1)
+	}
+	req = append(req, proxyAddr.Username...)
+	req = append(req, 0)
+	if ip == nil {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// VN (0), CD, DSTPORT, DSTIP
+	var reply [8]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != 0 {
+		return fmt.Errorf("invalid SOCKS4 reply version %d", reply[0])
+	}
+	if reply[1] != 0x5a {
+		return fmt.Errorf("SOCKS4 request rejected with code %#x", reply[1])
+	}
+	return nil
+}
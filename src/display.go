@@ -0,0 +1,167 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScrapeStatus is a snapshot of the scraping progress of one protocol
+type ScrapeStatus struct {
+	Protocol  string `json:"protocol"`
+	Completed int    `json:"completed"` // Sources scraped so far
+	Failed    int    `json:"failed"`    // Sources that could not be fetched
+	Total     int    `json:"total"`
+	Found     int    `json:"found"` // Proxies found so far
+}
+
+// Display shows the progress of scraping and checking. Updates are sent
+// periodically, and once more with done set when the step is over.
+type Display interface {
+	ScrapeProgress(status ScrapeStatus, done bool)
+	CheckProgress(progress Progress, done bool)
+}
+
+// display receives the progress of ScrapeProxies and CheckProxies
+var display Display = &lineDisplay{term: NewTerminal(os.Stdout)}
+
+// SetDisplay replaces the display of scraping and checking progress, which
+// draws progress lines on stdout by default
+func SetDisplay(d Display) {
+	display = d
+}
+
+// lineDisplay draws progress lines on a terminal and prints a summary once
+// checking is done
+type lineDisplay struct {
+	term     *Terminal
+	scraping string // Protocol being scraped
+}
+
+// ScrapeProgress draws the number of proxies scraped for a protocol
+func (d *lineDisplay) ScrapeProgress(status ScrapeStatus, done bool) {
+	if status.Protocol != d.scraping {
+		d.scraping = status.Protocol
+		fmt.Printf("Starting %s proxy scraping...\n", status.Protocol)
+	}
+	line := fmt.Sprintf("✓ Scraped %d %s proxies [%d/%d]", status.Found, status.Protocol, status.Completed, status.Total)
+	if done {
+		d.term.Done(line)
+	} else {
+		d.term.Update(line)
+	}
+}
+
+// CheckProgress draws one progress line per list of proxies. Progress bars
+// are left out on terminals without ANSI support.
+func (d *lineDisplay) CheckProgress(p Progress, done bool) {
+	line := func(label string, checked, total, working int) string {
+		// An empty list has nothing left to check
+		percentage := 100.0
+		if total > 0 {
+			percentage = float64(checked) / float64(total) * 100
+		}
+		if !d.term.ANSI() {
+			return fmt.Sprintf("%s [%d/%d] - Working: %d %.0f%%", label, checked, total, working, percentage)
+		}
+		return fmt.Sprintf("%s [%d/%d] - Working: %d %s %.0f%%",
+			label, checked, total, working, ProgressBar(percentage, 30), percentage)
+	}
+
+	lines := []string{
+		line("HTTP", p.CheckedHTTP, p.TotalHTTP, p.WorkingHTTP),
+		line("SOCKS5", p.CheckedSOCKS5, p.TotalSOCKS5, p.WorkingSOCKS5),
+	}
+	if p.TotalAuto > 0 {
+		lines = append(lines, line("Auto", p.CheckedAuto, p.TotalAuto, p.WorkingAuto))
+	}
+	if !done {
+		d.term.Update(lines...)
+		return
+	}
+
+	d.term.Done(lines...)
+	for _, line := range p.Summary() {
+		fmt.Println(line)
+	}
+}
+
+// QuietDisplay shows no progress at all
+type QuietDisplay struct{}
+
+// ScrapeProgress does nothing
+func (QuietDisplay) ScrapeProgress(ScrapeStatus, bool) {}
+
+// CheckProgress does nothing
+func (QuietDisplay) CheckProgress(Progress, bool) {}
+
+// jsonEventInterval is the minimum time between two progress events of the
+// same step
+const jsonEventInterval = time.Second
+
+// progressEvent is a progress update written by JSONDisplay
+type progressEvent struct {
+	Event  string        `json:"event"` // "scrape" or "check"
+	Time   time.Time     `json:"time"`
+	Done   bool          `json:"done"`
+	Scrape *ScrapeStatus `json:"scrape,omitempty"`
+	Check  *Progress     `json:"check,omitempty"`
+}
+
+// JSONDisplay writes the progress as JSON events, one per line, for scripts
+// and other programs driving the tool. Events are written at most once per
+// second for each protocol scraped and for checking, and once more when
+// the step is done.
+type JSONDisplay struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	last map[string]time.Time // Time of the last event of each step
+}
+
+// NewJSONDisplay returns a display writing progress events to w
+func NewJSONDisplay(w io.Writer) *JSONDisplay {
+	return &JSONDisplay{enc: json.NewEncoder(w), last: make(map[string]time.Time)}
+}
+
+// ScrapeProgress writes a scrape event
+func (d *JSONDisplay) ScrapeProgress(status ScrapeStatus, done bool) {
+	d.write("scrape "+status.Protocol, progressEvent{Event: "scrape", Done: done, Scrape: &status})
+}
+
+// CheckProgress writes a check event
+func (d *JSONDisplay) CheckProgress(progress Progress, done bool) {
+	d.write("check", progressEvent{Event: "check", Done: done, Check: &progress})
+}
+
+// write writes an event unless one was written for the same step less than
+// jsonEventInterval ago
+func (d *JSONDisplay) write(step string, event progressEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	event.Time = time.Now()
+	if !event.Done && event.Time.Sub(d.last[step]) < jsonEventInterval {
+		return
+	}
+	d.last[step] = event.Time
+	d.enc.Encode(event)
+}
+
+// Summary returns the final number of working proxies, one line per list
+func (p Progress) Summary() []string {
+	lines := []string{
+		fmt.Sprintf("✓ Found %d working HTTP proxies", p.WorkingHTTP),
+		fmt.Sprintf("✓ Found %d working SOCKS5 proxies", p.WorkingSOCKS5),
+	}
+	if p.TotalAuto > 0 {
+		var detected []string
+		for _, proxyType := range DetectOrder {
+			detected = append(detected, fmt.Sprintf("%d %s", p.Detected[proxyType.String()], proxyType))
+		}
+		lines = append(lines, fmt.Sprintf("✓ Found %d working proxies of unknown protocol (%s)", p.WorkingAuto, strings.Join(detected, ", ")))
+	}
+	return lines
+}
@@ -0,0 +1,234 @@
+package src
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"ProxyScraperChecker/src/rpc"
+)
+
+// RemoteWorkers checks proxies on the instances of distributed.workers,
+// which serve the gRPC service, by sending them batches of proxies to check
+// with CheckBatch. Each worker checks its batches with its own checker
+// section, from its own network.
+type RemoteWorkers struct {
+	config  *Config
+	workers []*remoteWorker
+}
+
+// remoteWorker is a worker with its count of batches failed in a row
+type remoteWorker struct {
+	addr     string
+	conn     *grpc.ClientConn
+	client   rpc.CheckerClient
+	failures atomic.Int32
+}
+
+// remoteBatch is a batch of proxies of the same list
+type remoteBatch struct {
+	listType ProxyType
+	proxies  []string
+}
+
+// bearerToken sends distributed.token with every call
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool { return false }
+
+// NewRemoteWorkers returns the workers of distributed.workers, nil if none
+// is set. Connections are opened on first use.
+func NewRemoteWorkers(config *Config) (*RemoteWorkers, error) {
+	if len(config.Distributed.Workers) == 0 {
+		return nil, nil
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if config.Distributed.TLS {
+		opts[0] = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+	if config.Distributed.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(config.Distributed.Token)))
+	}
+
+	w := &RemoteWorkers{config: config}
+	for _, addr := range config.Distributed.Workers {
+		conn, err := grpc.NewClient(addr, opts...)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("worker %s: %w", addr, err)
+		}
+		w.workers = append(w.workers, &remoteWorker{addr: addr, conn: conn, client: rpc.NewCheckerClient(conn)})
+	}
+	return w, nil
+}
+
+// Len returns the number of workers
+func (w *RemoteWorkers) Len() int {
+	if w == nil {
+		return 0
+	}
+	return len(w.workers)
+}
+
+// Close closes the connections to the workers
+func (w *RemoteWorkers) Close() error {
+	if w == nil {
+		return nil
+	}
+	var errs []error
+	for _, worker := range w.workers {
+		errs = append(errs, worker.conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// check checks batches of distributed.batch_size proxies of each list on
+// the workers, distributed.batches_per_worker at once per worker, and calls
+// handle with each result as it arrives, from several goroutines. The
+// proxies of a batch left unchecked by a failing worker are sent to the
+// next free one; a worker failing distributed.max_failures batches in a row
+// is dropped. It returns once all proxies are checked or ctx is done, or
+// with an error once no worker is left.
+func (w *RemoteWorkers) check(ctx context.Context, lists map[ProxyType][]string, handle func(listType ProxyType, proxy string, result CheckResult)) error {
+	var batches []remoteBatch
+	size := w.config.Distributed.BatchSize
+	for _, listType := range []ProxyType{ProxyTypeHTTP, ProxyTypeSOCKS5, ProxyTypeAuto} {
+		proxies := lists[listType]
+		for start := 0; start < len(proxies); start += size {
+			batches = append(batches, remoteBatch{listType, proxies[start:min(start+size, len(proxies))]})
+		}
+	}
+	if len(batches) == 0 {
+		return nil
+	}
+
+	// Batches sent back by failing workers are queued again, so the queue
+	// never holds more than all of them. It is closed once all are checked.
+	queue := make(chan remoteBatch, len(batches))
+	for _, batch := range batches {
+		queue <- batch
+	}
+	var remaining atomic.Int64
+	remaining.Store(int64(len(batches)))
+	finish := func() {
+		if remaining.Add(-1) == 0 {
+			close(queue)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, worker := range w.workers {
+		for range w.config.Distributed.BatchesPerWorker {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					if worker.failures.Load() >= int32(w.config.Distributed.MaxFailures) {
+						return
+					}
+					var batch remoteBatch
+					select {
+					case b, ok := <-queue:
+						if !ok {
+							return
+						}
+						batch = b
+					case <-ctx.Done():
+						return
+					}
+
+					rest, err := worker.checkBatch(ctx, batch, handle)
+					if len(rest.proxies) == 0 {
+						worker.failures.Store(0)
+						finish()
+						continue
+					}
+					if ctx.Err() != nil {
+						return
+					}
+					queue <- rest
+					failures := worker.failures.Add(1)
+					slog.Warn("Remote worker failed", "worker", worker.addr, "unchecked", len(rest.proxies), "error", err)
+					if failures >= int32(w.config.Distributed.MaxFailures) {
+						slog.Error("Dropping remote worker", "worker", worker.addr, "failures", failures)
+						return
+					}
+
+					// Give the worker time to recover before its next batch
+					select {
+					case <-time.After(time.Duration(failures) * time.Second):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil || remaining.Load() == 0 {
+		return nil
+	}
+	unchecked := 0
+	for len(queue) > 0 {
+		unchecked += len((<-queue).proxies)
+	}
+	return fmt.Errorf("no remote worker left, %d proxies unchecked", unchecked)
+}
+
+// checkBatch checks a batch on the worker and calls handle with each
+// result. On error, it returns the proxies of the batch left unchecked.
+func (r *remoteWorker) checkBatch(ctx context.Context, batch remoteBatch, handle func(listType ProxyType, proxy string, result CheckResult)) (remoteBatch, error) {
+	rest := batch
+	stream, err := r.client.CheckBatch(ctx, &rpc.CheckBatchRequest{
+		Proxies:  batch.proxies,
+		Protocol: strings.ToLower(batch.listType.String()),
+	})
+	if err != nil {
+		return rest, err
+	}
+
+	checked := make([]bool, len(batch.proxies))
+	for {
+		msg, recvErr := stream.Recv()
+		if recvErr != nil {
+			if recvErr != io.EOF {
+				err = recvErr
+			}
+			break
+		}
+		i := int(msg.GetIndex())
+		if i < 0 || i >= len(batch.proxies) || checked[i] {
+			continue
+		}
+		checked[i] = true
+		handle(batch.listType, batch.proxies[i], checkResultFromRPC(msg))
+	}
+
+	rest.proxies = nil
+	for i, proxy := range batch.proxies {
+		if !checked[i] {
+			rest.proxies = append(rest.proxies, proxy)
+		}
+	}
+	if err == nil && len(rest.proxies) > 0 {
+		err = fmt.Errorf("no result for %d proxies", len(rest.proxies))
+	}
+	return rest, err
+}
@@ -0,0 +1,117 @@
+package src
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxSourceBody is the size limit of a source response, once decompressed
+const maxSourceBody = 32 << 20
+
+// gzipMagic starts gzip streams, including .gz files served as they are
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// readSourceBody reads the body of a source response, decompressing it
+// according to its Content-Encoding, or when it is a gzip file, and
+// converting it to UTF-8 according to its charset
+func readSourceBody(resp *http.Response) ([]byte, error) {
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if resp.Uncompressed {
+		encoding = ""
+	}
+	var r io.ReadCloser
+	switch {
+	case encoding == "gzip" || encoding == "x-gzip" || bytes.HasPrefix(body, gzipMagic):
+		if r, err = gzip.NewReader(bytes.NewReader(body)); err != nil {
+			return nil, fmt.Errorf("decompressing gzip: %w", err)
+		}
+	case encoding == "deflate":
+		// Servers send either zlib streams, as specified, or raw deflate
+		if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			r = flate.NewReader(bytes.NewReader(body))
+		}
+	case encoding == "" || encoding == "identity":
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	if r != nil {
+		defer r.Close()
+		if body, err = readLimited(r); err != nil {
+			return nil, fmt.Errorf("decompressing %s: %w", encoding, err)
+		}
+	}
+
+	return toUTF8(resp.Header.Get("Content-Type"), body), nil
+}
+
+// readLimited reads r to the end, failing if it holds more than
+// maxSourceBody bytes
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxSourceBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSourceBody {
+		return nil, fmt.Errorf("response larger than %d MB", maxSourceBody>>20)
+	}
+	return body, nil
+}
+
+// toUTF8 converts a source response to UTF-8. UTF-16 is detected from its
+// byte order mark or the charset of the Content-Type, and ISO-8859-1 from
+// the charset. Other charsets write IPs and ports in ASCII like UTF-8, so
+// only their byte sequences that are invalid in UTF-8 are replaced.
+func toUTF8(contentType string, body []byte) []byte {
+	var charset string
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		charset = strings.ToLower(params["charset"])
+	}
+
+	switch {
+	case bytes.HasPrefix(body, []byte{0xef, 0xbb, 0xbf}):
+		body = body[3:]
+	case bytes.HasPrefix(body, []byte{0xff, 0xfe}):
+		return decodeUTF16(body[2:], binary.LittleEndian)
+	case bytes.HasPrefix(body, []byte{0xfe, 0xff}):
+		return decodeUTF16(body[2:], binary.BigEndian)
+	case charset == "utf-16le":
+		return decodeUTF16(body, binary.LittleEndian)
+	case charset == "utf-16" || charset == "utf-16be":
+		return decodeUTF16(body, binary.BigEndian)
+	case charset == "iso-8859-1" || charset == "latin1":
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		return []byte(string(runes))
+	}
+
+	if utf8.Valid(body) {
+		return body
+	}
+	return bytes.ToValidUTF8(body, []byte("\uFFFD"))
+}
+
+// decodeUTF16 converts UTF-16 text with the given byte order to UTF-8
+func decodeUTF16(body []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		units[i] = order.Uint16(body[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
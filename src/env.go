@@ -0,0 +1,123 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix is the prefix of environment variables overriding the
+// configuration
+const envPrefix = "PSC_"
+
+// ApplyEnv sets configuration fields from PSC_<SECTION>_<KEY> environment
+// variables named after the YAML keys, e.g. PSC_CHECKER_TIMEOUT=5s for
+// checker.timeout. Lists are separated by commas. It is meant to be passed
+// to LoadConfig; unknown PSC_ variables and invalid values are errors.
+func ApplyEnv(config *Config) error {
+	fields := envFields(reflect.ValueOf(config).Elem())
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting", name)
+		}
+		if err := setEnvField(field, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// envFields maps the environment variable names of the settings of config
+// to their fields. Nested sections such as output.upload are named after
+// their path, e.g. PSC_OUTPUT_UPLOAD_BUCKET. Settings of unsupported types,
+// such as checker.targets, cannot be set from the environment.
+func envFields(config reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	addEnvFields(fields, strings.TrimSuffix(envPrefix, "_"), config)
+	return fields
+}
+
+// addEnvFields adds the settings of section to fields, with names starting
+// with prefix
+func addEnvFields(fields map[string]reflect.Value, prefix string, section reflect.Value) {
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Field(i)
+		name := prefix + "_" + strings.ToUpper(yamlName(section.Type().Field(i)))
+		switch {
+		case field.Kind() == reflect.Struct:
+			addEnvFields(fields, name, field)
+		case envSupported(field.Type()):
+			fields[name] = field
+		}
+	}
+}
+
+// yamlName returns the YAML key of a struct field
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+// envSupported reports whether a setting of type t can be parsed from an
+// environment variable
+func envSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// setEnvField parses value into field
+func setEnvField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package src
+
+import "sync"
+
+// exitTracker groups working proxies by exit IP for checker.exit_ip_dedup.
+// All methods are safe to call on a nil *exitTracker, which disables
+// deduplication.
+type exitTracker struct {
+	collapse bool // Keep only the fastest proxy of each exit IP
+	mu       sync.Mutex
+	first    map[string]string      // First working proxy seen per exit IP
+	fastest  map[string]CheckResult // Fastest working proxy per exit IP
+	replaced map[string]bool        // Proxies saved before a faster one with the same exit IP
+}
+
+// newExitTracker returns a tracker for the given checker.exit_ip_dedup
+// mode, nil if deduplication is off. Exit IPs are only known in strict
+// mode.
+func newExitTracker(config *Config) *exitTracker {
+	if config.Checker.ExitIPDedup == "" || !config.Checker.StrictCheck {
+		return nil
+	}
+	return &exitTracker{
+		collapse: config.Checker.ExitIPDedup == "collapse",
+		first:    make(map[string]string),
+		fastest:  make(map[string]CheckResult),
+		replaced: make(map[string]bool),
+	}
+}
+
+// track records a working result. It returns the first proxy seen with the
+// same exit IP, if any, and whether the result must be saved: when
+// collapsing, only a proxy faster than the others of its exit IP is saved,
+// and the one it replaces is removed by removeReplaced.
+func (e *exitTracker) track(result CheckResult) (shared string, save bool) {
+	if e == nil || result.ProxyIP == "" {
+		return "", true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	first, seen := e.first[result.ProxyIP]
+	if !seen {
+		e.first[result.ProxyIP] = result.Proxy
+		e.fastest[result.ProxyIP] = result
+		return "", true
+	}
+	if !e.collapse {
+		return first, true
+	}
+
+	fastest := e.fastest[result.ProxyIP]
+	if result.Speed >= fastest.Speed {
+		return first, false
+	}
+	e.fastest[result.ProxyIP] = result
+	e.replaced[fastest.Proxy] = true
+	return first, true
+}
+
+// removeReplaced removes the proxies replaced by a faster one with the same
+// exit IP from the text output files in dir, per-target and per-country
+// files included, and from the CSV file at csvPath if it has a proxy column
+func (e *exitTracker) removeReplaced(dir, csvPath string) error {
+	if e == nil || len(e.replaced) == 0 {
+		return nil
+	}
+
+	return rewriteOutputs(dir, csvPath, func(proxies []string) []int {
+		var kept []int
+		for i, proxy := range proxies {
+			if !e.replaced[proxy] {
+				kept = append(kept, i)
+			}
+		}
+		return kept
+	})
+}
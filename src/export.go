@@ -0,0 +1,253 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/netip"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exporter renders working proxies in the configuration format of a proxy
+// client. Proxy types the client does not support are skipped.
+type exporter struct {
+	file   string // Name of the file written to the output directory
+	render func(proxies []exportProxy) ([]byte, error)
+}
+
+// exporters maps the output.format names to their exporter
+var exporters = map[string]exporter{
+	"clash":       {"clash.yaml", renderClash},
+	"v2ray":       {"v2ray.json", renderV2Ray},
+	"surge":       {"surge.txt", renderSurge},
+	"proxychains": {"proxychains.conf", renderProxychains},
+}
+
+// exportProxy is a working proxy read back from the output files
+type exportProxy struct {
+	Type ProxyType
+	Addr ProxyAddr
+	Port int
+}
+
+// name returns the name of the proxy in client configurations, e.g.
+// "SOCKS5 1.2.3.4:1080"
+func (p exportProxy) name() string {
+	return p.Type.String() + " " + p.Addr.HostPort()
+}
+
+// writeExports renders the working proxies of the output files in dir, in
+// their final order, to a file per format of output.format
+func writeExports(dir string, formats []string) error {
+	if len(formats) == 0 {
+		return nil
+	}
+
+	var proxies []exportProxy
+	for _, proxyType := range DetectOrder {
+		lines, err := ReadOutputFile(OutputFile(dir, proxyType))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for _, line := range lines {
+			addr, err := ParseProxyAddr(line)
+			if err != nil {
+				slog.Debug("Skipping proxy not exportable", "proxy", line, "error", err)
+				continue
+			}
+			port, err := strconv.Atoi(addr.Port)
+			if err != nil {
+				continue
+			}
+			proxies = append(proxies, exportProxy{Type: proxyType, Addr: addr, Port: port})
+		}
+	}
+
+	for _, format := range formats {
+		e := exporters[format]
+		data, err := e.render(proxies)
+		if err != nil {
+			return fmt.Errorf("rendering %s: %w", format, err)
+		}
+		if err := writeFileAtomic(filepath.Join(dir, e.file), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clashProxy is a proxy of a Clash proxy provider
+type clashProxy struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	TLS      bool   `yaml:"tls,omitempty"`
+}
+
+// renderClash renders a Clash proxy provider file. Clash has no SOCKS4
+// support.
+func renderClash(proxies []exportProxy) ([]byte, error) {
+	provider := struct {
+		Proxies []clashProxy `yaml:"proxies"`
+	}{Proxies: []clashProxy{}}
+	for _, p := range proxies {
+		proxy := clashProxy{
+			Name:     p.name(),
+			Server:   p.Addr.Host,
+			Port:     p.Port,
+			Username: p.Addr.Username,
+			Password: p.Addr.Password,
+		}
+		switch p.Type {
+		case ProxyTypeHTTP:
+			proxy.Type = "http"
+		case ProxyTypeHTTPS:
+			proxy.Type, proxy.TLS = "http", true
+		case ProxyTypeSOCKS5:
+			proxy.Type = "socks5"
+		default:
+			continue
+		}
+		provider.Proxies = append(provider.Proxies, proxy)
+	}
+	return yaml.Marshal(provider)
+}
+
+// v2rayServer is a server of a V2Ray http or socks outbound
+type v2rayServer struct {
+	Address string      `json:"address"`
+	Port    int         `json:"port"`
+	Users   []v2rayUser `json:"users,omitempty"`
+}
+
+// v2rayUser holds the credentials of a V2Ray server
+type v2rayUser struct {
+	User string `json:"user"`
+	Pass string `json:"pass"`
+}
+
+// v2rayOutbound is a V2Ray outbound, tagged with the proxy name
+type v2rayOutbound struct {
+	Tag      string `json:"tag"`
+	Protocol string `json:"protocol"`
+	Settings struct {
+		Servers []v2rayServer `json:"servers"`
+	} `json:"settings"`
+	StreamSettings *v2rayStream `json:"streamSettings,omitempty"`
+}
+
+// v2rayStream holds the transport settings of a V2Ray outbound
+type v2rayStream struct {
+	Security string `json:"security"`
+}
+
+// renderV2Ray renders the outbounds section of a V2Ray configuration, one
+// outbound per proxy. V2Ray has no SOCKS4 support.
+func renderV2Ray(proxies []exportProxy) ([]byte, error) {
+	config := struct {
+		Outbounds []v2rayOutbound `json:"outbounds"`
+	}{Outbounds: []v2rayOutbound{}}
+	for _, p := range proxies {
+		outbound := v2rayOutbound{Tag: p.name()}
+		switch p.Type {
+		case ProxyTypeHTTP:
+			outbound.Protocol = "http"
+		case ProxyTypeHTTPS:
+			outbound.Protocol = "http"
+			outbound.StreamSettings = &v2rayStream{Security: "tls"}
+		case ProxyTypeSOCKS5:
+			outbound.Protocol = "socks"
+		default:
+			continue
+		}
+		server := v2rayServer{Address: p.Addr.Host, Port: p.Port}
+		if p.Addr.HasAuth() {
+			server.Users = []v2rayUser{{User: p.Addr.Username, Pass: p.Addr.Password}}
+		}
+		outbound.Settings.Servers = []v2rayServer{server}
+		config.Outbounds = append(config.Outbounds, outbound)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// renderSurge renders a Surge proxy list, one "name = type, server, port"
+// line per proxy followed by its credentials. Surge has no SOCKS4 support.
+func renderSurge(proxies []exportProxy) ([]byte, error) {
+	var b strings.Builder
+	for _, p := range proxies {
+		var proxyType string
+		switch p.Type {
+		case ProxyTypeHTTP:
+			proxyType = "http"
+		case ProxyTypeHTTPS:
+			proxyType = "https"
+		case ProxyTypeSOCKS5:
+			proxyType = "socks5"
+		default:
+			continue
+		}
+		fmt.Fprintf(&b, "%s = %s, %s, %d", p.name(), proxyType, p.Addr.Host, p.Port)
+		if p.Addr.HasAuth() {
+			fmt.Fprintf(&b, ", %s, %s", p.Addr.Username, p.Addr.Password)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// renderProxychains renders a proxychains.conf fragment: the chain mode
+// and a [ProxyList] section of "type host port [user pass]" lines.
+// proxychains only accepts IP addresses and has no HTTPS support.
+func renderProxychains(proxies []exportProxy) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(`# proxychains.conf fragment generated by ProxyScraperChecker
+#
+# random_chain sends each connection through chain_len proxies picked at
+# random from the list. To go through every proxy in order instead, which
+# only works if all of them do, comment it out and uncomment strict_chain.
+random_chain
+chain_len = 1
+#strict_chain
+
+proxy_dns
+tcp_read_time_out 15000
+tcp_connect_time_out 8000
+
+[ProxyList]
+`)
+	for _, p := range proxies {
+		if _, err := netip.ParseAddr(p.Addr.Host); err != nil {
+			continue
+		}
+		var proxyType string
+		switch p.Type {
+		case ProxyTypeHTTP:
+			proxyType = "http"
+		case ProxyTypeSOCKS4:
+			proxyType = "socks4"
+		case ProxyTypeSOCKS5:
+			proxyType = "socks5"
+		default:
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s %d", proxyType, p.Addr.Host, p.Port)
+		if p.Addr.HasAuth() {
+			fmt.Fprintf(&b, " %s %s", p.Addr.Username, p.Addr.Password)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
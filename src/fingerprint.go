@@ -0,0 +1,84 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+)
+
+// fingerprintWait is how long the fingerprint step waits for a service to
+// speak first, then for the answer to its probe
+const fingerprintWait = 500 * time.Millisecond
+
+// rejectPort reports whether checker.fingerprint is enabled and found that
+// the port of a proxy is clearly not a proxy, sparing it the full checks
+func (c *ProxyChecker) rejectPort(ctx context.Context, proxyStr string) bool {
+	if !c.config.Checker.Fingerprint {
+		return false
+	}
+	addr, err := ParseProxyAddr(proxyStr)
+	if err != nil {
+		return false
+	}
+	service := c.fingerprint(ctx, addr)
+	if service != "" {
+		slog.Debug("Port is not a proxy", "proxy", proxyStr, "service", service)
+	}
+	return service != ""
+}
+
+// fingerprint connects to the port of a proxy and returns the kind of
+// service found there if it is clearly not a proxy, or "" if it may be one:
+//
+//   - unreachable: the connection failed
+//   - ssh, smtp/ftp, pop3, imap or banner: the service spoke first, which
+//     proxies never do
+//   - tls: the service answered a plaintext request with a TLS alert
+func (c *ProxyChecker) fingerprint(ctx context.Context, addr ProxyAddr) string {
+	dialer := c.dialer(&net.Dialer{Timeout: c.config.Checker.ConnectTimeout})
+	conn, err := dialer.DialContext(ctx, "tcp", addr.HostPort())
+	if err != nil {
+		return "unreachable"
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(fingerprintWait))
+	n, err := conn.Read(buf)
+	if n > 0 {
+		return bannerService(buf[:n])
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		// Closed without a word: leave it to the checks
+		return ""
+	}
+
+	conn.SetDeadline(time.Now().Add(fingerprintWait))
+	if _, err := conn.Write([]byte("OPTIONS * HTTP/1.0\r\n\r\n")); err != nil {
+		return ""
+	}
+	// Alert record, TLS 1.x
+	if n, _ := conn.Read(buf); n >= 3 && buf[0] == 0x15 && buf[1] == 0x03 {
+		return "tls"
+	}
+	return ""
+}
+
+// bannerService names the service that sent banner on connection
+func bannerService(banner []byte) string {
+	switch {
+	case bytes.HasPrefix(banner, []byte("SSH-")):
+		return "ssh"
+	case bytes.HasPrefix(banner, []byte("220")):
+		return "smtp/ftp"
+	case bytes.HasPrefix(banner, []byte("+OK")):
+		return "pop3"
+	case bytes.HasPrefix(banner, []byte("* OK")):
+		return "imap"
+	}
+	return "banner"
+}
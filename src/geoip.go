@@ -0,0 +1,68 @@
+package src
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP resolves IP locations offline from a MaxMind GeoLite2 database
+type GeoIP struct {
+	reader *maxminddb.Reader
+}
+
+// geoIPRecord holds the fields read from GeoLite2-City and GeoLite2-Country
+// databases. Country databases simply leave the city fields empty.
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+}
+
+// OpenGeoIP opens a GeoLite2-City or GeoLite2-Country mmdb file
+func OpenGeoIP(path string) (*GeoIP, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+// Lookup returns the location of an IP address
+func (g *GeoIP) Lookup(ipStr string) (*ProxyLocation, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", ipStr)
+	}
+
+	var record geoIPRecord
+	if err := g.reader.Lookup(ip, &record); err != nil {
+		return nil, err
+	}
+	if record.Country.ISOCode == "" {
+		return nil, fmt.Errorf("no location found for %s", ipStr)
+	}
+
+	location := &ProxyLocation{
+		Country:     record.Country.Names["en"],
+		CountryCode: record.Country.ISOCode,
+		City:        record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+	}
+	return location, nil
+}
+
+// Close closes the underlying database
+func (g *GeoIP) Close() error {
+	return g.reader.Close()
+}
@@ -0,0 +1,128 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitHub endpoints, variables so they can be pointed at a mirror
+var (
+	githubSearchURL = "https://api.github.com/search/code"
+	githubRawURL    = "https://raw.githubusercontent.com"
+)
+
+// githubSearchResult is the code search response of the GitHub API
+type githubSearchResult struct {
+	Message string `json:"message"`
+	Items   []struct {
+		Path       string `json:"path"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	} `json:"items"`
+}
+
+// scrapeGitHub searches GitHub for files matching the source query, most
+// recently indexed first, and scrapes the latest version of each of them
+// like a plain URL source. Files that fail to load are skipped.
+func scrapeGitHub(ctx context.Context, client *http.Client, source Source, userAgent string) ([]string, error) {
+	urls, err := discoverGitHub(ctx, client, source)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Discovered GitHub proxy lists", "query", source.Query, "count", len(urls))
+
+	var interval time.Duration
+	if source.RateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / source.RateLimit)
+	}
+
+	// Discovered files are fetched without the API token and headers
+	file := Source{Type: SourceURL, Protocol: source.Protocol, Parser: source.Parser, Selector: source.Selector, Regex: source.Regex, Timeout: source.Timeout,
+		retries: source.retries, retryDelay: source.retryDelay, hosts: source.hosts}
+
+	var proxies []string
+	seen := make(map[string]bool)
+	for i, rawURL := range urls {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return proxies, ctx.Err()
+			}
+		}
+		if ctx.Err() != nil {
+			return proxies, ctx.Err()
+		}
+
+		found, err := scrapePage(ctx, client, file, rawURL, userAgent)
+		if err != nil {
+			slog.Debug("Error scraping discovered file", "url", rawURL, "error", err)
+			continue
+		}
+		for _, proxy := range found {
+			if !seen[proxy] {
+				seen[proxy] = true
+				proxies = append(proxies, proxy)
+			}
+		}
+	}
+	return proxies, nil
+}
+
+// discoverGitHub runs the code search of source and returns the raw URLs of
+// the matching files on their default branch
+func discoverGitHub(ctx context.Context, client *http.Client, source Source) ([]string, error) {
+	if source.Token == "" {
+		return nil, errors.New("github sources require an API token (scraper.github_token)")
+	}
+
+	query := url.Values{
+		"q":        {source.Query},
+		"sort":     {"indexed"},
+		"order":    {"desc"},
+		"per_page": {strconv.Itoa(source.MaxResults)},
+	}
+	api := source
+	api.Headers = map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"Authorization":        "Bearer " + source.Token,
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	for name, value := range source.Headers {
+		api.Headers[name] = value
+	}
+
+	body, _, err := fetchSource(ctx, client, api, githubSearchURL+"?"+query.Encode(), "ProxyScraperChecker")
+	if err != nil {
+		return nil, fmt.Errorf("searching GitHub: %w", err)
+	}
+
+	var result githubSearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parsing GitHub response: %w", err)
+	}
+	if result.Message != "" {
+		return nil, fmt.Errorf("GitHub API error: %s", result.Message)
+	}
+
+	urls := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		// HEAD resolves to the default branch, so the latest version of the
+		// list is fetched rather than the indexed one
+		path := strings.Split(item.Path, "/")
+		for i, segment := range path {
+			path[i] = url.PathEscape(segment)
+		}
+		urls = append(urls, fmt.Sprintf("%s/%s/HEAD/%s", githubRawURL, item.Repository.FullName, strings.Join(path, "/")))
+	}
+	return urls, nil
+}
@@ -0,0 +1,330 @@
+package src
+
+//go:generate protoc --proto_path=rpc --go_out=rpc --go_opt=paths=source_relative --go-grpc_out=rpc --go-grpc_opt=paths=source_relative checker.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"ProxyScraperChecker/src/rpc"
+)
+
+// GRPCServer exposes proxy checks and the proxy pool over gRPC, see
+// rpc/checker.proto
+type GRPCServer struct {
+	rpc.UnimplementedCheckerServer
+	config  *Config
+	pool    *Pool
+	checker atomic.Pointer[ProxyChecker]
+}
+
+// NewGRPCServer creates a gRPC server for the pool. Proxies submitted by
+// clients are checked with checker, or with a checker of its own if nil.
+// Their results are only returned to the client, not added to the pool.
+func NewGRPCServer(config *Config, pool *Pool, checker *ProxyChecker) *GRPCServer {
+	if checker == nil {
+		checker = NewProxyChecker(config)
+	}
+	s := &GRPCServer{config: config, pool: pool}
+	s.checker.Store(checker)
+	return s
+}
+
+// SetChecker replaces the checker of the proxies submitted by clients, for
+// the checks started from now on
+func (s *GRPCServer) SetChecker(checker *ProxyChecker) {
+	s.checker.Store(checker)
+}
+
+// ListenAndServe serves gRPC on config.GRPC.Listen until ctx is done, over
+// TLS with grpc.cert_file, and only to clients sending grpc.token if set
+func (s *GRPCServer) ListenAndServe(ctx context.Context) error {
+	var opts []grpc.ServerOption
+	if s.config.GRPC.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.config.GRPC.CertFile, s.config.GRPC.KeyFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if s.config.GRPC.Token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := s.authorize(ctx); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := s.authorize(stream.Context()); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}))
+	}
+
+	listener, err := net.Listen("tcp", s.config.GRPC.Listen)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(opts...)
+	rpc.RegisterCheckerServer(srv, s)
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+	return srv.Serve(listener)
+}
+
+// authorize checks that the client of a call sent grpc.token
+func (s *GRPCServer) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	want := []byte("Bearer " + s.config.GRPC.Token)
+	for _, got := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(got), want) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// CheckProxy checks a single proxy
+func (s *GRPCServer) CheckProxy(ctx context.Context, req *rpc.CheckProxyRequest) (*rpc.CheckResult, error) {
+	proxy, proxyType, err := requestProxy(req.GetProxy(), req.GetProtocol())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return newRPCResult(s.checker.Load().Check(ctx, proxy, proxyType)), nil
+}
+
+// CheckBatch checks a list of proxies, checker.concurrent at once, and
+// streams their results as the checks end
+func (s *GRPCServer) CheckBatch(req *rpc.CheckBatchRequest, stream grpc.ServerStreamingServer[rpc.CheckResult]) error {
+	type job struct {
+		index     int
+		proxy     string
+		proxyType ProxyType
+	}
+	jobs := make([]job, len(req.GetProxies()))
+	for i, raw := range req.GetProxies() {
+		proxy, proxyType, err := requestProxy(raw, req.GetProtocol())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "proxies[%d]: %v", i, err)
+		}
+		jobs[i] = job{i, proxy, proxyType}
+	}
+
+	// Checks are abandoned if the client goes away or a result cannot be sent
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	pending := make(chan job)
+	go func() {
+		defer close(pending)
+		for _, j := range jobs {
+			select {
+			case pending <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	checker := s.checker.Load()
+	results := make(chan *rpc.CheckResult)
+	var wg sync.WaitGroup
+	for range min(s.config.Checker.Concurrent, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range pending {
+				result := newRPCResult(checker.Check(ctx, j.proxy, j.proxyType))
+				result.Index = int32(j.index)
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var sendErr error
+	for result := range results {
+		if sendErr != nil {
+			continue
+		}
+		if sendErr = stream.Send(result); sendErr != nil {
+			cancel()
+		}
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	if err := stream.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// GetPool lists the working proxies of the pool, fastest first
+func (s *GRPCServer) GetPool(ctx context.Context, req *rpc.GetPoolRequest) (*rpc.GetPoolResponse, error) {
+	minLevel, err := ParseAnonymityLevel(req.GetAnonymity())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	network, err := ParseNetworkClass(req.GetNetwork())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.GetMaxLatencyMs() < 0 || req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_latency_ms and limit must not be negative")
+	}
+
+	results := poolQuery{
+		proxyType:  req.GetType(),
+		country:    req.GetCountry(),
+		maxLatency: time.Duration(req.GetMaxLatencyMs()) * time.Millisecond,
+		minLevel:   minLevel,
+		network:    network,
+		target:     req.GetTarget(),
+		limit:      int(req.GetLimit()),
+	}.selectFrom(s.pool)
+
+	resp := &rpc.GetPoolResponse{Proxies: make([]*rpc.CheckResult, len(results))}
+	for i, result := range results {
+		resp.Proxies[i] = newRPCResult(result)
+	}
+	return resp, nil
+}
+
+// requestProxy validates a proxy submitted over gRPC and returns it
+// normalized, with the type it is checked as: protocol if set, else the
+// scheme of the proxy, else HTTP
+func requestProxy(raw, protocol string) (string, ProxyType, error) {
+	proxy, ok := isValidProxy(raw)
+	if !ok {
+		return "", 0, fmt.Errorf("invalid proxy %q", raw)
+	}
+	if protocol == "" {
+		scheme, _, found := strings.Cut(strings.TrimSpace(raw), "://")
+		if !found {
+			return proxy, ProxyTypeHTTP, nil
+		}
+		protocol = scheme
+	}
+	proxyType, err := ParseProxyType(protocol)
+	if err != nil {
+		return "", 0, err
+	}
+	return proxy, proxyType, nil
+}
+
+// newRPCResult converts a check result to its gRPC representation, which
+// has the fields of its JSON representation
+func newRPCResult(result CheckResult) *rpc.CheckResult {
+	record := NewProxyRecord(result)
+	msg := &rpc.CheckResult{
+		Proxy:          record.Proxy,
+		Type:           record.Type,
+		Working:        result.Working,
+		Ip:             record.IP,
+		Country:        record.Country,
+		CountryCode:    record.CountryCode,
+		City:           record.City,
+		Asn:            uint32(record.ASN),
+		AsOrg:          record.ASOrg,
+		Isp:            record.ISP,
+		LatencyMs:      record.LatencyMs,
+		ConnectTimeMs:  record.ConnectMs,
+		TtfbMs:         record.TTFBMs,
+		TotalTimeMs:    record.TotalMs,
+		Anonymous:      record.Anonymous,
+		Anonymity:      record.Anonymity,
+		Network:        record.Network,
+		Stability:      record.Stability,
+		Streak:         int32(record.Streak),
+		ThroughputKbps: record.Throughput,
+		Targets:        record.Targets,
+		SharedExit:     record.SharedExit,
+		Blocklists:     record.Blocklists,
+		TorExit:        record.TorExit,
+		Tls:            record.TLS,
+		Mitm:           record.MITM,
+		Clean:          record.Clean,
+		UdpSupport:     record.UDP,
+		Dns:            record.DNS,
+		KeepAlive:      record.KeepAlive,
+		Http2:          record.HTTP2,
+	}
+	if !record.CheckedAt.IsZero() {
+		msg.CheckedAt = timestamppb.New(record.CheckedAt)
+	}
+	if !record.ExpiresAt.IsZero() {
+		msg.ExpiresAt = timestamppb.New(record.ExpiresAt)
+	}
+	return msg
+}
+
+// checkResultFromRPC converts a check result from its gRPC representation.
+// Fields of unknown values, such as the type of a newer server, are left
+// zero.
+func checkResultFromRPC(msg *rpc.CheckResult) CheckResult {
+	result := CheckResult{
+		Proxy:       msg.GetProxy(),
+		Working:     msg.GetWorking(),
+		ProxyIP:     msg.GetIp(),
+		Speed:       time.Duration(msg.GetLatencyMs()) * time.Millisecond,
+		Anonymous:   msg.GetAnonymous(),
+		ConnectTime: time.Duration(msg.GetConnectTimeMs()) * time.Millisecond,
+		TTFB:        time.Duration(msg.GetTtfbMs()) * time.Millisecond,
+		TotalTime:   time.Duration(msg.GetTotalTimeMs()) * time.Millisecond,
+		Stability:   msg.GetStability(),
+		Streak:      int(msg.GetStreak()),
+		Throughput:  msg.GetThroughputKbps(),
+		Targets:     msg.GetTargets(),
+		SharedExit:  msg.GetSharedExit(),
+		Blocklists:  msg.GetBlocklists(),
+		TorExit:     msg.GetTorExit(),
+		TLS:         msg.GetTls(),
+		MITM:        msg.GetMitm(),
+		Clean:       msg.GetClean(),
+		UDP:         msg.GetUdpSupport(),
+		DNS:         msg.GetDns(),
+		KeepAlive:   msg.GetKeepAlive(),
+		HTTP2:       msg.GetHttp2(),
+	}
+	result.Type, _ = ParseProxyType(msg.GetType())
+	result.Anonymity, _ = ParseAnonymityLevel(msg.GetAnonymity())
+	result.Network, _ = ParseNetworkClass(msg.GetNetwork())
+	if msg.GetCountry() != "" || msg.GetCountryCode() != "" || msg.GetAsn() != 0 {
+		result.Location = &ProxyLocation{
+			Country:     msg.GetCountry(),
+			CountryCode: msg.GetCountryCode(),
+			City:        msg.GetCity(),
+			ASN:         uint(msg.GetAsn()),
+			ASOrg:       msg.GetAsOrg(),
+			ISP:         msg.GetIsp(),
+		}
+	}
+	if msg.GetCheckedAt() != nil {
+		result.CheckedAt = msg.GetCheckedAt().AsTime()
+	}
+	if msg.GetExpiresAt() != nil {
+		result.ExpiresAt = msg.GetExpiresAt().AsTime()
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package src
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// maxCapturedHeader limits the length of a captured header value
+const maxCapturedHeader = 256
+
+// headerCapture records the headers of checker.capture_headers seen through
+// a proxy during a check, keeping the first value seen of each. All methods
+// are safe to call on a nil *headerCapture, which records nothing.
+type headerCapture struct {
+	names   []string // Canonical names of the captured headers
+	mu      sync.Mutex
+	headers map[string]string
+}
+
+// headerCaptureKey is the context key of the header capture of a check
+type headerCaptureKey struct{}
+
+// newHeaderCapture returns a capture of the headers of
+// checker.capture_headers, or nil if none is listed
+func (c *ProxyChecker) newHeaderCapture() *headerCapture {
+	if len(c.config.Checker.CaptureHeaders) == 0 {
+		return nil
+	}
+	names := make([]string, len(c.config.Checker.CaptureHeaders))
+	for i, name := range c.config.Checker.CaptureHeaders {
+		names[i] = http.CanonicalHeaderKey(name)
+	}
+	return &headerCapture{names: names, headers: make(map[string]string)}
+}
+
+// capturedHeaders returns the header capture of the check of ctx, nil if
+// headers are not captured
+func capturedHeaders(ctx context.Context) *headerCapture {
+	capture, _ := ctx.Value(headerCaptureKey{}).(*headerCapture)
+	return capture
+}
+
+// add records the captured headers of header not seen yet
+func (h *headerCapture) add(header http.Header) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, name := range h.names {
+		if _, ok := h.headers[name]; ok {
+			continue
+		}
+		if value := header.Get(name); value != "" {
+			if len(value) > maxCapturedHeader {
+				value = value[:maxCapturedHeader]
+			}
+			h.headers[name] = value
+		}
+	}
+}
+
+// captured returns the recorded headers by canonical name, nil if none was
+// seen
+func (h *headerCapture) captured() map[string]string {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.headers) == 0 {
+		return nil
+	}
+	return h.headers
+}
+
+// formatHeaders formats captured headers as "Name: value" pairs sorted by
+// name and separated by semicolons
+func formatHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + ": " + headers[name]
+	}
+	return strings.Join(pairs, "; ")
+}
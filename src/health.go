@@ -0,0 +1,262 @@
+package src
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceStats holds the health statistics of a source across runs
+type SourceStats struct {
+	URL             string    `json:"url"`
+	Protocol        string    `json:"protocol"`
+	Runs            int       `json:"runs"`
+	FetchFailures   int       `json:"fetch_failures"`
+	TotalProxies    int       `json:"total_proxies"`
+	TotalWorking    int       `json:"total_working"`
+	ZeroWorkingRuns int       `json:"zero_working_runs"` // Consecutive runs without a working proxy
+	LastRun         time.Time `json:"last_run"`
+	LastFetchOK     bool      `json:"last_fetch_ok"`
+	LastProxies     int       `json:"last_proxies"`
+	LastWorking     int       `json:"last_working"`
+	Disabled        bool      `json:"disabled"`
+}
+
+// WorkingRate returns the percentage of scraped proxies that worked, across
+// all runs
+func (s *SourceStats) WorkingRate() float64 {
+	if s.TotalProxies == 0 {
+		return 0
+	}
+	return float64(s.TotalWorking) / float64(s.TotalProxies) * 100
+}
+
+// sourceRun holds the statistics of a source for the current run
+type sourceRun struct {
+	fetchOK bool
+	proxies int
+	working int
+}
+
+// SourceHealth tracks how many proxies, and how many working ones, each
+// source yields. Statistics are persisted to a JSON file between runs so that
+// sources that stopped yielding working proxies can be skipped. All methods
+// are safe to call on a nil *SourceHealth, which disables tracking.
+type SourceHealth struct {
+	path    string
+	mu      sync.Mutex
+	sources map[string]*SourceStats
+	run     map[string]*sourceRun
+	owners  map[string][]string // Proxy to the keys of the sources listing it
+}
+
+// sourceKey identifies a source across runs
+func sourceKey(source Source) string {
+	return source.Protocol + " " + source.URL
+}
+
+// LoadSourceHealth reads the statistics saved at path. A missing file starts
+// with empty statistics.
+func LoadSourceHealth(path string) (*SourceHealth, error) {
+	h := &SourceHealth{
+		path:    path,
+		sources: make(map[string]*SourceStats),
+		run:     make(map[string]*sourceRun),
+		owners:  make(map[string][]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []*SourceStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+	for _, s := range stats {
+		h.sources[sourceKey(Source{Protocol: s.Protocol, URL: s.URL})] = s
+	}
+	return h, nil
+}
+
+// Filter returns the sources to scrape, leaving out those that yielded no
+// working proxy in the last disableAfter runs. A disableAfter of zero keeps
+// every source.
+func (h *SourceHealth) Filter(sources []Source, disableAfter int) (enabled, disabled []Source) {
+	if h == nil || disableAfter <= 0 {
+		return sources, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, source := range sources {
+		if s, ok := h.sources[sourceKey(source)]; ok && s.ZeroWorkingRuns >= disableAfter {
+			s.Disabled = true
+			disabled = append(disabled, source)
+			continue
+		}
+		enabled = append(enabled, source)
+	}
+	return enabled, disabled
+}
+
+// RecordScrape records the outcome of scraping a source
+func (h *SourceHealth) RecordScrape(source Source, proxies []string, err error) {
+	if h == nil {
+		return
+	}
+
+	key := sourceKey(source)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.sources[key]; !ok {
+		h.sources[key] = &SourceStats{URL: source.URL, Protocol: source.Protocol}
+	}
+	h.sources[key].Disabled = false
+	h.run[key] = &sourceRun{fetchOK: err == nil, proxies: len(proxies)}
+	for _, proxy := range proxies {
+		owner := source.Protocol + " " + proxy
+		h.owners[owner] = append(h.owners[owner], key)
+	}
+}
+
+// RecordResult credits a working proxy to the sources that listed it with
+// its protocol or as auto
+func (h *SourceHealth) RecordResult(result CheckResult) {
+	if h == nil || !result.Working {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, protocol := range []string{strings.ToLower(result.Type.String()), "auto"} {
+		for _, key := range h.owners[protocol+" "+result.Proxy] {
+			h.run[key].working++
+		}
+	}
+}
+
+// Finish adds the statistics of the current run to the totals. It must be
+// called once, after every result of a complete run was recorded.
+func (h *SourceHealth) Finish(now time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, run := range h.run {
+		s := h.sources[key]
+		s.Runs++
+		if !run.fetchOK {
+			s.FetchFailures++
+		}
+		s.TotalProxies += run.proxies
+		s.TotalWorking += run.working
+		if run.working == 0 {
+			s.ZeroWorkingRuns++
+		} else {
+			s.ZeroWorkingRuns = 0
+		}
+		s.LastRun = now
+		s.LastFetchOK = run.fetchOK
+		s.LastProxies = run.proxies
+		s.LastWorking = run.working
+	}
+	h.run = make(map[string]*sourceRun)
+	h.owners = make(map[string][]string)
+}
+
+// Stats returns the statistics of every known source, sorted by protocol
+// and then by number of working proxies in the last run, best first
+func (h *SourceHealth) Stats() []SourceStats {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	stats := make([]SourceStats, 0, len(h.sources))
+	for _, s := range h.sources {
+		stats = append(stats, *s)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Protocol != stats[j].Protocol {
+			return stats[i].Protocol < stats[j].Protocol
+		}
+		if stats[i].LastWorking != stats[j].LastWorking {
+			return stats[i].LastWorking > stats[j].LastWorking
+		}
+		return stats[i].URL < stats[j].URL
+	})
+	return stats
+}
+
+// Save writes the statistics to the file they were loaded from
+func (h *SourceHealth) Save() error {
+	if h == nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(h.Stats(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, data, 0644)
+}
+
+// WriteReport writes the statistics as a CSV report to path
+func (h *SourceHealth) WriteReport(path string) error {
+	if h == nil {
+		return nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"protocol", "url", "status", "proxies", "working", "working_rate",
+		"runs", "fetch_failures", "zero_working_runs", "total_proxies", "total_working"})
+	for _, s := range h.Stats() {
+		status := "ok"
+		switch {
+		case s.Disabled:
+			status = "disabled"
+		case !s.LastFetchOK:
+			status = "failed"
+		}
+		w.Write([]string{
+			s.Protocol,
+			s.URL,
+			status,
+			strconv.Itoa(s.LastProxies),
+			strconv.Itoa(s.LastWorking),
+			strconv.FormatFloat(s.WorkingRate(), 'f', 2, 64),
+			strconv.Itoa(s.Runs),
+			strconv.Itoa(s.FetchFailures),
+			strconv.Itoa(s.ZeroWorkingRuns),
+			strconv.Itoa(s.TotalProxies),
+			strconv.Itoa(s.TotalWorking),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
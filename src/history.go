@@ -0,0 +1,91 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// historyDir is the directory of the output directory the result sets of
+// previous runs are kept in with output.keep_history
+const historyDir = "history"
+
+// RotateHistory copies the result files of the previous run to
+// <output dir>/history/<time>/, named after the UTC time that run ended,
+// then removes the oldest copies beyond output.keep_history. It does
+// nothing if output.keep_history is 0 or there is no previous result.
+func RotateHistory(config *Config) error {
+	keep := config.Output.KeepHistory
+	if keep <= 0 {
+		return nil
+	}
+	dir := config.Output.Dir
+	paths, err := resultFiles(dir)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	ended, err := previousRunEnd(dir, paths)
+	if err != nil {
+		return err
+	}
+	snapshot := filepath.Join(dir, historyDir, ended.UTC().Format(runTimeFormat))
+	if _, err := os.Stat(snapshot); errors.Is(err, fs.ErrNotExist) {
+		for _, path := range paths {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if err := copyFile(path, filepath.Join(snapshot, rel)); err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, historyDir))
+	if err != nil {
+		return err
+	}
+	var snapshots []string
+	for _, entry := range entries {
+		if _, err := time.Parse(runTimeFormat, entry.Name()); entry.IsDir() && err == nil {
+			snapshots = append(snapshots, entry.Name())
+		}
+	}
+	slices.Sort(snapshots)
+	for _, name := range snapshots[:max(len(snapshots)-keep, 0)] {
+		if err := os.RemoveAll(filepath.Join(dir, historyDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// previousRunEnd returns the time the run that wrote the result files in
+// dir ended: from meta.json, or the time its files were last modified
+func previousRunEnd(dir string, paths []string) (time.Time, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, "meta.json")); err == nil {
+		var meta RunMeta
+		if err := json.Unmarshal(data, &meta); err == nil && !meta.Ended.IsZero() {
+			return meta.Ended, nil
+		}
+	}
+
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,72 @@
+package src
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// hostChecks caps the number of checks of proxies of the same host running
+// at once, as lists often repeat a host on many ports. All methods are safe
+// to call on a nil *hostChecks, which does not limit.
+type hostChecks struct {
+	max   int
+	mu    sync.Mutex
+	hosts map[string]*hostCheckSlots // Hosts with checks running or waiting
+}
+
+// hostCheckSlots are the checks of a host running at once
+type hostCheckSlots struct {
+	slots chan struct{}
+	users int // Checks of the host running or waiting
+}
+
+// newHostChecks returns a limiter allowing max checks of a host at once, or
+// nil if max is 0
+func newHostChecks(max int) *hostChecks {
+	if max <= 0 {
+		return nil
+	}
+	return &hostChecks{max: max, hosts: make(map[string]*hostCheckSlots)}
+}
+
+// acquire waits until a check of the host of proxyStr can start, or for ctx
+// to be done. The returned function ends the check.
+func (l *hostChecks) acquire(ctx context.Context, proxyStr string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	host := proxyStr
+	if addr, err := ParseProxyAddr(proxyStr); err == nil {
+		host = strings.ToLower(addr.Host)
+	}
+
+	l.mu.Lock()
+	h := l.hosts[host]
+	if h == nil {
+		h = &hostCheckSlots{slots: make(chan struct{}, l.max)}
+		l.hosts[host] = h
+	}
+	h.users++
+	l.mu.Unlock()
+
+	select {
+	case h.slots <- struct{}{}:
+		return sync.OnceFunc(func() {
+			<-h.slots
+			l.leave(host, h)
+		}), nil
+	case <-ctx.Done():
+		l.leave(host, h)
+		return nil, ctx.Err()
+	}
+}
+
+// leave forgets a host once no check of it runs or waits
+func (l *hostChecks) leave(host string, h *hostCheckSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h.users--; h.users == 0 {
+		delete(l.hosts, host)
+	}
+}
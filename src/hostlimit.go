@@ -0,0 +1,64 @@
+package src
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimiter spaces out the scraping requests sent to each host, whichever
+// source they are for, and delays each request by a random jitter so that
+// they do not arrive at a fixed rhythm. All methods are safe to call on a
+// nil *HostLimiter, which does not limit.
+type HostLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	jitter    time.Duration
+	hosts     map[string]*RateLimiter
+}
+
+// NewHostLimiter returns a limiter allowing perMinute requests per minute to
+// each host, evenly spaced, and delaying each request by up to jitter. It
+// returns nil if perMinute and jitter are both 0.
+func NewHostLimiter(perMinute int, jitter time.Duration) *HostLimiter {
+	if perMinute <= 0 && jitter <= 0 {
+		return nil
+	}
+	return &HostLimiter{perMinute: perMinute, jitter: jitter, hosts: make(map[string]*RateLimiter)}
+}
+
+// Wait blocks until a request to rawURL may be sent or ctx is done
+func (l *HostLimiter) Wait(ctx context.Context, rawURL string) error {
+	if l == nil {
+		return nil
+	}
+
+	if u, err := url.Parse(rawURL); err == nil && l.perMinute > 0 {
+		host := strings.ToLower(u.Hostname())
+		l.mu.Lock()
+		limiter, ok := l.hosts[host]
+		if !ok {
+			limiter = NewRateLimiter(float64(l.perMinute) / 60)
+			l.hosts[host] = limiter
+		}
+		l.mu.Unlock()
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	if l.jitter <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(rand.N(l.jitter))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,282 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// isHTML reports whether a response looks like an HTML document
+func isHTML(contentType string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "text/html") {
+		return true
+	}
+	head := bytes.ToLower(bytes.TrimSpace(body[:min(len(body), 512)]))
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+// extractHTMLProxies extracts proxies from an HTML page. With a selector,
+// only elements matching it are considered, each one being a row holding a
+// single proxy. Without a selector every table row is considered, and the
+// visible text of the page is scanned line by line like a text source.
+//
+// In a row, a proxy is either written as IP:PORT in one cell, or split into
+// an IP cell followed by a port cell, as in most free proxy list tables.
+func extractHTMLProxies(body []byte, selector string) ([]string, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	var rows []*html.Node
+	if selector != "" {
+		sel, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		rows = sel.matchAll(doc)
+	} else {
+		rows = (selectorChain{{tag: "tr"}}).matchAll(doc)
+	}
+
+	var proxies []string
+	seen := make(map[string]bool)
+	add := func(proxy string) {
+		if !seen[proxy] {
+			seen[proxy] = true
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	for _, row := range rows {
+		if proxy, ok := proxyFromRow(row); ok {
+			add(proxy)
+		}
+	}
+
+	if selector == "" {
+		// Tables were handled row by row above
+		for _, line := range strings.Split(nodeText(doc, atom.Table), "\n") {
+			line = strings.TrimSpace(line)
+			if normalized, ok := isValidProxy(line); ok && strings.Contains(line, ":") {
+				add(normalized)
+			}
+		}
+	}
+	return proxies, nil
+}
+
+// proxyFromRow returns the proxy held by a table row or selected element
+func proxyFromRow(row *html.Node) (string, bool) {
+	var cells []string
+	for child := row.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.DataAtom == atom.Td || child.DataAtom == atom.Th) {
+			cells = append(cells, strings.TrimSpace(nodeText(child)))
+		}
+	}
+	if len(cells) == 0 {
+		cells = strings.Fields(nodeText(row))
+	}
+	return proxyFromCells(cells)
+}
+
+// proxyFromCells returns the proxy held by the cells of a table or CSV row:
+// the first IP:PORT cell, or the first IP cell followed by a port cell
+func proxyFromCells(cells []string) (string, bool) {
+	for i, cell := range cells {
+		if normalized, ok := isValidProxy(cell); ok && strings.Contains(cell, ":") {
+			return normalized, true
+		}
+		ip := net.ParseIP(cell)
+		if ip == nil || i+1 >= len(cells) {
+			continue
+		}
+		if port, err := strconv.Atoi(cells[i+1]); err == nil && port > 0 && port <= 65535 {
+			return net.JoinHostPort(ip.String(), strconv.Itoa(port)), true
+		}
+	}
+	return "", false
+}
+
+// nodeText returns the visible text of n, with a line break after each
+// block-level element and a space between table cells. Elements listed in
+// skip are left out along with their content.
+func nodeText(n *html.Node, skip ...atom.Atom) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			case atom.Br:
+				b.WriteByte('\n')
+				return
+			}
+			if slices.Contains(skip, n.DataAtom) {
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Td, atom.Th:
+				b.WriteByte(' ')
+			case atom.Tr, atom.P, atom.Div, atom.Li, atom.Pre, atom.Table, atom.Tbody, atom.Ul, atom.Ol:
+				b.WriteByte('\n')
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// simpleSelector is a compound CSS selector such as tr.proxy or
+// table#list[data-type=http]
+type simpleSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   [][2]string // Name and value, an empty value only requires presence
+}
+
+// selectorChain is a list of simple selectors joined by descendant
+// combinators, e.g. "table#proxies tbody tr"
+type selectorChain []simpleSelector
+
+// parseSelector parses the supported subset of CSS selectors: type, #id,
+// .class, [attr] and [attr=value] selectors, combined with descendant
+// combinators
+func parseSelector(selector string) (selectorChain, error) {
+	var chain selectorChain
+	for _, part := range strings.Fields(selector) {
+		var sel simpleSelector
+		rest := part
+		for rest != "" {
+			switch rest[0] {
+			case '#', '.':
+				end := strings.IndexAny(rest[1:], "#.[")
+				if end < 0 {
+					end = len(rest) - 1
+				}
+				name := rest[1 : end+1]
+				if name == "" {
+					return nil, fmt.Errorf("invalid selector %q", selector)
+				}
+				if rest[0] == '#' {
+					sel.id = name
+				} else {
+					sel.classes = append(sel.classes, name)
+				}
+				rest = rest[end+1:]
+			case '[':
+				end := strings.IndexByte(rest, ']')
+				if end < 0 {
+					return nil, fmt.Errorf("invalid selector %q: missing ]", selector)
+				}
+				name, value, _ := strings.Cut(rest[1:end], "=")
+				sel.attrs = append(sel.attrs, [2]string{strings.ToLower(name), strings.Trim(value, `"'`)})
+				rest = rest[end+1:]
+			default:
+				end := strings.IndexAny(rest, "#.[")
+				if end < 0 {
+					end = len(rest)
+				}
+				sel.tag = strings.ToLower(rest[:end])
+				rest = rest[end:]
+			}
+		}
+		chain = append(chain, sel)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return chain, nil
+}
+
+// matches reports whether the element n matches the simple selector
+func (s simpleSelector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if s.tag != "" && s.tag != "*" && n.Data != s.tag {
+		return false
+	}
+	if s.id != "" && attr(n, "id") != s.id {
+		return false
+	}
+	classes := strings.Fields(attr(n, "class"))
+	for _, class := range s.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+	for _, a := range s.attrs {
+		value, ok := attrLookup(n, a[0])
+		if !ok || a[1] != "" && value != a[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the element n matches the whole chain: n matches
+// the last selector and its ancestors match the previous ones in order
+func (c selectorChain) matches(n *html.Node) bool {
+	if !c[len(c)-1].matches(n) {
+		return false
+	}
+	i := len(c) - 2
+	for p := n.Parent; p != nil && i >= 0; p = p.Parent {
+		if c[i].matches(p) {
+			i--
+		}
+	}
+	return i < 0
+}
+
+// matchAll returns every element below root matching the chain, in
+// document order
+func (c selectorChain) matchAll(root *html.Node) []*html.Node {
+	var nodes []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if c.matches(n) {
+			nodes = append(nodes, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	return nodes
+}
+
+// attr returns the value of the attribute name of n, or ""
+func attr(n *html.Node, name string) string {
+	value, _ := attrLookup(n, name)
+	return value
+}
+
+// attrLookup returns the value of the attribute name of n and whether it
+// is present
+func attrLookup(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
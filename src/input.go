@@ -0,0 +1,62 @@
+package src
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// InputProxies holds the proxies of user-provided lists, by the list they
+// are checked in
+type InputProxies struct {
+	HTTP    []string
+	SOCKS5  []string
+	Auto    []string // Checked with protocol detection
+	Invalid int      // Lines that are not proxies
+}
+
+// Read adds the proxies listed in r, one per line, in any format the
+// scraper understands. Blank lines and lines starting with # are skipped.
+// A scheme such as socks5:// sets the protocol of a proxy, which otherwise
+// defaults to protocol: http, socks5 or auto. HTTPS and SOCKS4 proxies are
+// checked with protocol detection.
+func (in *InputProxies) Read(r io.Reader, protocol string) error {
+	if protocol != "http" && protocol != "socks5" && protocol != "auto" {
+		return fmt.Errorf("unknown protocol %q, expected http, socks5 or auto", protocol)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxy, ok := isValidProxy(line)
+		if !ok {
+			in.Invalid++
+			continue
+		}
+
+		lineProtocol := protocol
+		if scheme, _, found := strings.Cut(line, "://"); found {
+			switch scheme {
+			case "http":
+				lineProtocol = "http"
+			case "socks5":
+				lineProtocol = "socks5"
+			default:
+				lineProtocol = "auto"
+			}
+		}
+		switch lineProtocol {
+		case "http":
+			in.HTTP = append(in.HTTP, proxy)
+		case "socks5":
+			in.SOCKS5 = append(in.SOCKS5, proxy)
+		default:
+			in.Auto = append(in.Auto, proxy)
+		}
+	}
+	return scanner.Err()
+}
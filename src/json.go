@@ -0,0 +1,163 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kinds of fields of JSON proxy objects and CSV columns, by normalized name
+// (see fieldKind)
+var proxyFields = map[string]string{
+	"ip":         "ip",
+	"ipaddress":  "ip",
+	"ipaddr":     "ip",
+	"host":       "ip",
+	"hostname":   "ip",
+	"address":    "ip",
+	"addr":       "ip",
+	"server":     "ip",
+	"port":       "port",
+	"proxyport":  "port",
+	"portnum":    "port",
+	"portnumber": "port",
+	"proxy":      "proxy",
+	"ipport":     "proxy",
+	"hostport":   "proxy",
+	"username":   "username",
+	"user":       "username",
+	"login":      "username",
+	"password":   "password",
+	"pass":       "password",
+}
+
+// fieldKind returns the kind of a JSON field or CSV column from its name,
+// ignoring case, spaces, dashes and underscores: ip, port, proxy (IP:PORT),
+// username, password, or "" for other fields
+func fieldKind(name string) string {
+	name = strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	return proxyFields[name]
+}
+
+// isJSON reports whether a response is a single JSON document. Text lists
+// with one JSON object per line are not.
+func isJSON(contentType string, body []byte) bool {
+	body = bytes.TrimSpace(body)
+	if !strings.Contains(strings.ToLower(contentType), "json") &&
+		!bytes.HasPrefix(body, []byte("{")) && !bytes.HasPrefix(body, []byte("[")) {
+		return false
+	}
+	return json.Valid(body)
+}
+
+// extractJSONProxies extracts proxies from a JSON document. Proxies are
+// found at any depth, as:
+//
+//   - objects with IP and port fields, e.g. {"ip": "1.2.3.4", "port": 8080},
+//     or with an IP:PORT field such as "proxy"
+//   - objects keyed by IP, e.g. {"1.2.3.4": 8080} or {"1.2.3.4": {"port": 8080}}
+//   - IP:PORT strings and keys
+func extractJSONProxies(body []byte) ([]string, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+
+	var proxies []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			if proxy, ok := proxyFromObject(v); ok {
+				proxies = append(proxies, proxy)
+				return
+			}
+			// Keys are sorted so that proxies keep the same order across runs
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				if net.ParseIP(key) != nil {
+					if proxy, ok := proxyFromIPPort(key, jsonPort(v[key])); ok {
+						proxies = append(proxies, proxy)
+						continue
+					}
+				}
+				if proxy, ok := isValidProxy(key); ok && strings.Contains(key, ":") {
+					proxies = append(proxies, proxy)
+					continue
+				}
+				walk(v[key])
+			}
+		case string:
+			if proxy, ok := isValidProxy(v); ok && strings.Contains(v, ":") {
+				proxies = append(proxies, proxy)
+			}
+		}
+	}
+	walk(doc)
+	return proxies, nil
+}
+
+// proxyFromObject returns the proxy described by the fields of a JSON
+// object, with its credentials if it has username and password fields
+func proxyFromObject(obj map[string]any) (string, bool) {
+	// The first of several fields of a kind, by name, is used
+	fields := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(obj)) {
+		if kind := fieldKind(name); kind != "" && fields[kind] == "" {
+			fields[kind] = jsonString(obj[name])
+		}
+	}
+
+	var proxy string
+	var ok bool
+	switch {
+	case fields["ip"] != "" && fields["port"] != "":
+		proxy, ok = proxyFromIPPort(fields["ip"], fields["port"])
+	case strings.Contains(fields["proxy"], ":"):
+		proxy, ok = isValidProxy(fields["proxy"])
+	case strings.Contains(fields["ip"], ":"):
+		// The IP field may hold IP:PORT
+		proxy, ok = isValidProxy(fields["ip"])
+	}
+	if !ok {
+		return "", false
+	}
+	if fields["username"] != "" && fields["password"] != "" && !strings.Contains(proxy, "@") {
+		return isValidProxy(fields["username"] + ":" + fields["password"] + "@" + proxy)
+	}
+	return proxy, true
+}
+
+// jsonPort returns the port held by a JSON value: a number, a string, or an
+// object with a port field
+func jsonPort(v any) string {
+	if obj, ok := v.(map[string]any); ok {
+		for name, value := range obj {
+			if fieldKind(name) == "port" {
+				return jsonString(value)
+			}
+		}
+		return ""
+	}
+	return jsonString(v)
+}
+
+// jsonString returns a JSON string or number as a string, "" for other
+// values
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
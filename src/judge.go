@@ -0,0 +1,431 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxJudgeBody limits how much of an IP lookup or judge response is read
+const maxJudgeBody = 64 * 1024
+
+// Strict checks query two kinds of endpoints through the proxy. Both can be
+// self-hosted as long as they follow the response contract below.
+//
+// IP lookup (checker.ip_lookup_url) answers with either a bare IP address in
+// plain text, or a JSON object with the exit IP in "query", "ip" or "origin"
+// and optional location fields "country", "countryCode" or "country_code",
+// "regionName" or "region", "city", the autonomous system in "as", "asn" or
+// "org", e.g. "AS15169 Google LLC" or 15169, its organization in "asname" if
+// not part of the AS, and the ISP in "isp". If a "status" field is present
+// it must be "success".
+//
+// Judges (checker.judge_urls) answer with a JSON object holding the client
+// IP they saw in "origin" or "ip", and the request headers they received in
+// "headers", mapping header names to a string or a list of strings.
+
+// lookupResponse is the JSON answer of an IP lookup service
+type lookupResponse struct {
+	Status       string          `json:"status"`
+	Query        string          `json:"query"`
+	IP           string          `json:"ip"`
+	Origin       string          `json:"origin"`
+	Country      string          `json:"country"`
+	CountryCode  string          `json:"countryCode"`
+	CountryCode2 string          `json:"country_code"`
+	RegionName   string          `json:"regionName"`
+	Region       string          `json:"region"`
+	City         string          `json:"city"`
+	AS           string          `json:"as"`
+	ASN          json.RawMessage `json:"asn"` // String or number
+	ASName       string          `json:"asname"`
+	Org          string          `json:"org"`
+	ISP          string          `json:"isp"`
+}
+
+// judgeResponse is the JSON answer of a judge
+type judgeResponse struct {
+	Origin  string                     `json:"origin"`
+	IP      string                     `json:"ip"`
+	Headers map[string]json.RawMessage `json:"headers"`
+}
+
+// lookupIP returns the exit IP and location of the proxy using the
+// configured IP lookup service
+func (c *ProxyChecker) lookupIP(ctx context.Context, client *http.Client) (string, *ProxyLocation, bool) {
+	body, err := c.fetchThrough(ctx, client, c.config.Checker.IPLookupURL)
+	if err != nil {
+		slog.Debug("Error querying IP lookup service", "url", c.config.Checker.IPLookupURL, "error", err)
+		return "", nil, false
+	}
+
+	ip, location, err := parseIPLookup(body)
+	if err != nil {
+		slog.Debug("Error parsing IP lookup response", "url", c.config.Checker.IPLookupURL, "error", err)
+		return "", nil, false
+	}
+	return ip, location, true
+}
+
+// queryJudge requests a judge through the proxy and returns the client IP
+// and request headers it echoed back. Judges are used in rotation to spread
+// the load, and the next one is tried if a judge fails. Plain HTTP judges
+// should be used: over HTTPS the proxy only tunnels the connection and
+// cannot add headers, so every proxy would look elite.
+func (c *ProxyChecker) queryJudge(ctx context.Context, client *http.Client) (string, map[string]string, bool) {
+	judges := c.judges.available(c.config.Checker.JudgeURLs)
+	start := int(c.nextJudge.Add(1) - 1)
+	for i := range judges {
+		if ctx.Err() != nil {
+			return "", nil, false
+		}
+		judgeURL := judges[(start+i)%len(judges)]
+
+		body, err := c.fetchThrough(ctx, client, judgeURL)
+		if err != nil {
+			slog.Debug("Error querying judge", "url", judgeURL, "error", err)
+			continue
+		}
+		origin, headers, err := parseJudge(body)
+		if err != nil {
+			slog.Debug("Error parsing judge response", "url", judgeURL, "error", err)
+			continue
+		}
+		return origin, headers, true
+	}
+	return "", nil, false
+}
+
+// judgePool tracks which judges of checker.judge_urls answer, so that checks
+// skip those that are down instead of failing proxies for them. All methods
+// are safe to call on a nil *judgePool, which reports every judge as up.
+type judgePool struct {
+	urls []string
+	up   []atomic.Bool
+	down atomic.Bool // No judge answered the last health check
+}
+
+// newJudgePool returns the pool of checker.judge_urls, all up until checked,
+// or nil unless the anonymity step runs in strict mode
+func newJudgePool(config *Config) *judgePool {
+	steps := config.Checker.Steps
+	if len(steps) == 0 {
+		steps = DefaultCheckSteps
+	}
+	if !config.Checker.StrictCheck || !slices.ContainsFunc(steps, func(step string) bool { return strings.EqualFold(step, StepAnonymity) }) {
+		return nil
+	}
+	p := &judgePool{urls: config.Checker.JudgeURLs, up: make([]atomic.Bool, len(config.Checker.JudgeURLs))}
+	for i := range p.up {
+		p.up[i].Store(true)
+	}
+	return p
+}
+
+// available returns the judges that answered the last health check, or
+// judges if none did
+func (p *judgePool) available(judges []string) []string {
+	if p == nil || p.down.Load() {
+		return judges
+	}
+	up := make([]string, 0, len(p.urls))
+	for i, judgeURL := range p.urls {
+		if p.up[i].Load() {
+			up = append(up, judgeURL)
+		}
+	}
+	if len(up) == 0 {
+		return judges
+	}
+	return up
+}
+
+// allDown reports whether no judge answered the last health check
+func (p *judgePool) allDown() bool {
+	return p != nil && p.down.Load()
+}
+
+// watchJudges checks the judges now, then every
+// checker.judge_check_interval until ctx is done
+func (c *ProxyChecker) watchJudges(ctx context.Context) {
+	if c.judges == nil {
+		return
+	}
+	c.checkJudges(ctx)
+	go func() {
+		ticker := time.NewTicker(c.config.Checker.JudgeCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.checkJudges(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// checkJudges requests every judge directly and records which ones answer
+func (c *ProxyChecker) checkJudges(ctx context.Context) {
+	client := c.directClient()
+	defer client.CloseIdleConnections()
+
+	var wg sync.WaitGroup
+	for i, judgeURL := range c.judges.urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := c.fetchThrough(ctx, client, judgeURL)
+			if err == nil {
+				_, _, err = parseJudge(body)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			up := err == nil
+			if c.judges.up[i].Swap(up) != up {
+				if up {
+					slog.Info("Judge answers again", "url", judgeURL)
+				} else {
+					slog.Warn("Judge is down, skipping it", "url", judgeURL, "error", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	down := true
+	for i := range c.judges.up {
+		down = down && !c.judges.up[i].Load()
+	}
+	if c.judges.down.Swap(down) != down {
+		if down {
+			slog.Warn("No judge answers, anonymity is not classified until one does")
+		} else {
+			slog.Info("Judges answer again, classifying anonymity")
+		}
+	}
+}
+
+// directClient returns a client sending requests without a proxy under
+// test, from the vantage point of their context and through
+// checker.upstream_proxy if set
+func (c *ProxyChecker) directClient() *http.Client {
+	transport := &http.Transport{
+		DialContext: c.dialer(&net.Dialer{Timeout: c.config.Checker.ConnectTimeout}).DialContext,
+	}
+	return &http.Client{Transport: transport, Timeout: c.config.Checker.Timeout}
+}
+
+// publicIPs returns the public IPs of this machine, which proxies must not
+// reveal to judges. They are looked up once, on first use, with
+// checker.ip_lookup_url requested directly from every vantage point.
+func (c *ProxyChecker) publicIPs(ctx context.Context) []net.IP {
+	c.realIPOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.Checker.Timeout)
+		defer cancel()
+		client := c.directClient()
+		defer client.CloseIdleConnections()
+
+		contexts := []context.Context{ctx}
+		if len(c.vantages) > 0 {
+			contexts = contexts[:0]
+			for _, v := range c.vantages {
+				contexts = append(contexts, context.WithValue(ctx, vantageKey{}, v))
+			}
+		}
+		for _, ctx := range contexts {
+			body, err := c.fetchThrough(ctx, client, c.config.Checker.IPLookupURL)
+			var ip string
+			if err == nil {
+				ip, _, err = parseIPLookup(body)
+			}
+			if err != nil {
+				slog.Warn("Error looking up the public IP of this machine, any IP seen by judges besides the exit IP is taken as a leak", "url", c.config.Checker.IPLookupURL, "error", err)
+				continue
+			}
+			if parsed := net.ParseIP(ip); !slices.ContainsFunc(c.realIPs, parsed.Equal) {
+				c.realIPs = append(c.realIPs, parsed)
+				slog.Debug("Detected public IP", "ip", ip)
+			}
+		}
+	})
+	return c.realIPs
+}
+
+// fetchThrough sends a GET request to url using the proxy client and returns
+// the response body
+func (c *ProxyChecker) fetchThrough(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	resp, err := c.do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	capturedHeaders(ctx).add(resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJudgeBody))
+}
+
+// parseIPLookup extracts the exit IP and location from an IP lookup response
+func parseIPLookup(body []byte) (string, *ProxyLocation, error) {
+	text := strings.TrimSpace(string(body))
+	if ip := net.ParseIP(text); ip != nil {
+		return ip.String(), nil, nil
+	}
+
+	var data lookupResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if data.Status != "" && data.Status != "success" {
+		return "", nil, fmt.Errorf("lookup failed with status %q", data.Status)
+	}
+
+	ip := exitIPFromOrigin(firstNonEmpty(data.Query, data.IP, data.Origin))
+	if ip == "" {
+		return "", nil, fmt.Errorf("no exit IP in response")
+	}
+
+	location := &ProxyLocation{
+		Country:     data.Country,
+		CountryCode: strings.ToUpper(firstNonEmpty(data.CountryCode, data.CountryCode2)),
+		City:        data.City,
+		Region:      firstNonEmpty(data.RegionName, data.Region),
+		ISP:         data.ISP,
+	}
+	for _, as := range []string{data.AS, strings.Trim(string(data.ASN), `"`), data.Org} {
+		if asn, org := parseAS(as); asn != 0 {
+			location.ASN, location.ASOrg = asn, org
+			break
+		}
+	}
+	if location.ASN != 0 && location.ASOrg == "" {
+		location.ASOrg = firstNonEmpty(data.ASName, data.Org)
+	}
+	if *location == (ProxyLocation{}) {
+		location = nil
+	}
+	return ip, location, nil
+}
+
+// parseJudge extracts the client IP and request headers from a judge
+// response. Headers sent several times are joined with commas.
+func parseJudge(body []byte) (string, map[string]string, error) {
+	var data judgeResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	origin := firstNonEmpty(data.Origin, data.IP)
+	if origin == "" {
+		return "", nil, fmt.Errorf("no client IP in response")
+	}
+
+	headers := make(map[string]string, len(data.Headers))
+	for name, raw := range data.Headers {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			headers[name] = value
+			continue
+		}
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return "", nil, fmt.Errorf("invalid value for header %s", name)
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return origin, headers, nil
+}
+
+// firstNonEmpty returns the first non-empty string of values
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// JudgeServer is a minimal judge answering every request with the client IP
+// and the request headers it received, following the judge contract
+type JudgeServer struct {
+	listen   string
+	certFile string
+	keyFile  string
+}
+
+// judgeReply is the JSON answer of JudgeServer
+type judgeReply struct {
+	Origin  string            `json:"origin"`
+	Headers map[string]string `json:"headers"`
+}
+
+// NewJudgeServer creates a judge listening on listen. TLS is enabled when
+// both certFile and keyFile are set.
+func NewJudgeServer(listen, certFile, keyFile string) *JudgeServer {
+	return &JudgeServer{listen: listen, certFile: certFile, keyFile: keyFile}
+}
+
+// ListenAndServe serves judge requests until ctx is cancelled
+func (s *JudgeServer) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.listen,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	var err error
+	if s.certFile != "" && s.keyFile != "" {
+		err = srv.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP implements http.Handler
+func (s *JudgeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	headers := make(map[string]string, len(r.Header)+1)
+	for name, values := range r.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	headers["Host"] = r.Host
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, judgeReply{Origin: host, Headers: headers})
+}
@@ -0,0 +1,124 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ParseLogLevel parses a log level name: debug, info, warn or error
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// SetupLogger installs the default slog logger according to the log
+// configuration. The returned closer closes the log file.
+func SetupLogger(config LogConfig) (io.Closer, error) {
+	level, err := ParseLogLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := openRotatingFile(config.File, int64(config.MaxSizeMB)*1024*1024, config.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if strings.EqualFold(config.Format, "json") {
+		handler = slog.NewJSONHandler(file, options)
+	} else {
+		handler = slog.NewTextHandler(file, options)
+	}
+	slog.SetDefault(slog.New(handler))
+	return file, nil
+}
+
+// rotatingFile is a log file that is rotated once it exceeds maxSize bytes,
+// keeping at most maxBackups old files named path.1 (newest) to path.N
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens path for appending. A maxSize of 0 disables rotation.
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current log file and records its size
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write implements io.Writer
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups by one, moves the current file to path.1 and
+// starts a new one
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if f.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+// Close closes the log file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
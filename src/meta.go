@@ -0,0 +1,97 @@
+package src
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in the configuration snapshot of meta.json
+const redacted = "<redacted>"
+
+// RunMeta is the content of <output dir>/meta.json, describing the run that
+// produced the output files
+type RunMeta struct {
+	Started     time.Time      `json:"started"` // Start of the first part of a resumed run
+	Ended       time.Time      `json:"ended"`
+	Resumed     bool           `json:"resumed"`     // Continued an interrupted run
+	Interrupted bool           `json:"interrupted"` // Stopped before all proxies were checked
+	Totals      Progress       `json:"totals"`      // Proxies checked and working, of this part of the run only when resumed
+	Config      map[string]any `json:"config"`      // Effective configuration, secrets redacted
+}
+
+// writeMeta writes <output dir>/meta.json for a run started at started
+func (c *ProxyChecker) writeMeta(started time.Time, resumed, interrupted bool) error {
+	snapshot, err := configSnapshot(c.config)
+	if err != nil {
+		return err
+	}
+	meta := RunMeta{
+		Started:     started,
+		Ended:       time.Now(),
+		Resumed:     resumed,
+		Interrupted: interrupted,
+		Totals:      c.Progress(),
+		Config:      snapshot,
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.config.Output.Dir, "meta.json"), append(data, '\n'))
+}
+
+// configSnapshot returns the configuration with the keys of config.yaml,
+// API tokens, gRPC tokens, credentials and bot tokens redacted
+func configSnapshot(config *Config) (map[string]any, error) {
+	snapshot := *config
+	// The selected profile is already applied to the other sections
+	snapshot.Profiles = nil
+	for _, secret := range []*string{
+		&snapshot.Scraper.TelegramBotToken,
+		&snapshot.Scraper.GitHubToken,
+		&snapshot.Output.Upload.AccessKey,
+		&snapshot.Output.Upload.SecretKey,
+		&snapshot.Notify.TelegramBotToken,
+		&snapshot.GRPC.Token,
+		&snapshot.Distributed.Token,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	// URLs keep all but their password
+	for _, rawURL := range []*string{
+		&snapshot.Redis.URL,
+		&snapshot.Scraper.UpstreamProxy,
+		&snapshot.Checker.UpstreamProxy,
+	} {
+		*rawURL = redactURL(*rawURL)
+	}
+
+	data, err := yaml.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// redactURL returns rawURL with its password redacted, or redacted as a
+// whole if it cannot be parsed
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,159 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Limits of the Telegram Bot API
+const (
+	telegramMaxMessage = 4096     // Characters of a message
+	telegramMaxFile    = 50 << 20 // Bytes of a file uploaded by a bot
+)
+
+// TelegramNotifier delivers the outcome of a run to a Telegram chat through
+// the Bot API: a summary message, followed by the output files if
+// notify.telegram_send_files is set. All methods are safe to call on a nil
+// *TelegramNotifier, which sends nothing.
+type TelegramNotifier struct {
+	token     string
+	chatID    string
+	sendFiles bool
+	outputDir string
+	client    *http.Client
+}
+
+// NewTelegramNotifier returns the notifier configured by the notify
+// section, nil if no chat is set
+func NewTelegramNotifier(config *Config) *TelegramNotifier {
+	if config.Notify.TelegramChatID == "" {
+		return nil
+	}
+	return &TelegramNotifier{
+		token:     config.Notify.TelegramBotToken,
+		chatID:    config.Notify.TelegramChatID,
+		sendFiles: config.Notify.TelegramSendFiles,
+		outputDir: config.Output.Dir,
+		client:    &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// telegramResponse is the envelope of every Bot API response
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// Notify sends the summary of a run, then uploads the non-empty output
+// files when enabled. Files over the Bot API size limit are skipped.
+func (n *TelegramNotifier) Notify(ctx context.Context, summary string) error {
+	if n == nil {
+		return nil
+	}
+
+	if len([]rune(summary)) > telegramMaxMessage {
+		summary = string([]rune(summary)[:telegramMaxMessage])
+	}
+	body, err := json.Marshal(map[string]string{"chat_id": n.chatID, "text": summary})
+	if err != nil {
+		return err
+	}
+	if err := n.call(ctx, "sendMessage", "application/json", bytes.NewReader(body)); err != nil {
+		return err
+	}
+	if !n.sendFiles {
+		return nil
+	}
+
+	for _, path := range n.files() {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 {
+			continue
+		}
+		if info.Size() > telegramMaxFile {
+			slog.Warn("Output file too large for Telegram, not sent", "path", path, "size", info.Size())
+			continue
+		}
+		if err := n.sendDocument(ctx, path); err != nil {
+			return fmt.Errorf("sending %s: %w", filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+// files returns the output files delivered to the chat: working proxies of
+// each type, the CSV file and the stable proxies
+func (n *TelegramNotifier) files() []string {
+	var paths []string
+	for _, proxyType := range DetectOrder {
+		paths = append(paths, OutputFile(n.outputDir, proxyType))
+	}
+	return append(paths,
+		filepath.Join(n.outputDir, "proxies.csv"),
+		filepath.Join(n.outputDir, "stable.txt"))
+}
+
+// sendDocument uploads a file to the chat
+func (n *TelegramNotifier) sendDocument(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", n.chatID)
+	part, err := w.CreateFormFile("document", filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return n.call(ctx, "sendDocument", w.FormDataContentType(), &body)
+}
+
+// call sends a request to a Bot API method and checks its response
+func (n *TelegramNotifier) call(ctx context.Context, method, contentType string, body io.Reader) error {
+	apiURL := fmt.Sprintf("%s/bot%s/%s", telegramAPIURL, n.token, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The token is part of the URL, keep it out of error messages
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("calling Bot API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJudgeBody)).Decode(&result); err != nil {
+		return fmt.Errorf("parsing Bot API response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("Bot API error: %s", result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package src
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// resultOrder records the results saved to the output files, so that the
+// files can be sorted by output.sort once checking ends. All methods are
+// safe to call on a nil *resultOrder, which keeps the completion order.
+type resultOrder struct {
+	byCountry bool
+	mu        sync.Mutex
+	results   map[string]CheckResult // Saved results by proxy
+}
+
+// newResultOrder returns the order configured by output.sort, nil for none
+func newResultOrder(config *Config) *resultOrder {
+	if config.Output.Sort == "none" {
+		return nil
+	}
+	return &resultOrder{
+		byCountry: config.Output.Sort == "country",
+		results:   make(map[string]CheckResult),
+	}
+}
+
+// add records a result saved to the output files
+func (o *resultOrder) add(result CheckResult) {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results[result.Proxy] = result
+}
+
+// compare orders results by latency, fastest first, or with output.sort:
+// country by country code first. Proxies of unknown country come last.
+func (o *resultOrder) compare(a, b CheckResult) int {
+	if o.byCountry {
+		ca, cb := countryCode(a), countryCode(b)
+		switch {
+		case ca == cb:
+		case ca == "":
+			return 1
+		case cb == "":
+			return -1
+		default:
+			return strings.Compare(ca, cb)
+		}
+	}
+	return cmp.Compare(a.Speed, b.Speed)
+}
+
+// countryCode returns the country code of a result, empty if unknown
+func countryCode(result CheckResult) string {
+	if result.Location == nil {
+		return ""
+	}
+	return result.Location.CountryCode
+}
+
+// sortOutputs sorts the text output files in dir, per-target and
+// per-country files included, and the CSV file at csvPath. Lines of proxies
+// saved by an interrupted run, whose results are unknown, come last in
+// their original order.
+func (o *resultOrder) sortOutputs(dir, csvPath string) error {
+	if o == nil || len(o.results) == 0 {
+		return nil
+	}
+
+	return rewriteOutputs(dir, csvPath, func(proxies []string) []int {
+		indices := make([]int, len(proxies))
+		for i := range indices {
+			indices[i] = i
+		}
+		slices.SortStableFunc(indices, func(i, j int) int {
+			a, okA := o.results[proxies[i]]
+			b, okB := o.results[proxies[j]]
+			switch {
+			case okA && okB:
+				return o.compare(a, b)
+			case okA:
+				return -1
+			case okB:
+				return 1
+			default:
+				return 0
+			}
+		})
+		return indices
+	})
+}
@@ -0,0 +1,502 @@
+package src
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCSVColumns is the column list used when output.csv_columns is empty
+var DefaultCSVColumns = []string{"proxy", "type", "country", "latency", "anonymity"}
+
+// csvColumns maps supported column names to their value extractors
+var csvColumns = map[string]func(CheckResult) string{
+	"proxy": func(r CheckResult) string { return r.Proxy },
+	"type":  func(r CheckResult) string { return r.Type.String() },
+	"ip":    func(r CheckResult) string { return r.ProxyIP },
+	"country": func(r CheckResult) string {
+		if r.Location == nil {
+			return ""
+		}
+		return r.Location.Country
+	},
+	"country_code": func(r CheckResult) string {
+		if r.Location == nil {
+			return ""
+		}
+		return r.Location.CountryCode
+	},
+	"city": func(r CheckResult) string {
+		if r.Location == nil {
+			return ""
+		}
+		return r.Location.City
+	},
+	"asn": func(r CheckResult) string {
+		if r.Location == nil || r.Location.ASN == 0 {
+			return ""
+		}
+		return strconv.FormatUint(uint64(r.Location.ASN), 10)
+	},
+	"as_org": func(r CheckResult) string {
+		if r.Location == nil {
+			return ""
+		}
+		return r.Location.ASOrg
+	},
+	"isp": func(r CheckResult) string {
+		if r.Location == nil {
+			return ""
+		}
+		return r.Location.ISP
+	},
+	"latency":       func(r CheckResult) string { return strconv.FormatInt(r.Speed.Milliseconds(), 10) },
+	"connect_time":  func(r CheckResult) string { return strconv.FormatInt(r.ConnectTime.Milliseconds(), 10) },
+	"ttfb":          func(r CheckResult) string { return strconv.FormatInt(r.TTFB.Milliseconds(), 10) },
+	"total_time":    func(r CheckResult) string { return strconv.FormatInt(r.TotalTime.Milliseconds(), 10) },
+	"anonymity":     func(r CheckResult) string { return r.Anonymity.String() },
+	"real_ip_leak":  func(r CheckResult) string { return strconv.FormatBool(r.RealIPLeak) },
+	"network":       func(r CheckResult) string { return r.Network.String() },
+	"stability":     func(r CheckResult) string { return strconv.FormatFloat(r.Stability, 'f', 1, 64) },
+	"streak":        func(r CheckResult) string { return strconv.Itoa(r.Streak) },
+	"score":         func(r CheckResult) string { return strconv.FormatFloat(r.Score, 'f', 1, 64) },
+	"throughput":    func(r CheckResult) string { return strconv.FormatFloat(r.Throughput, 'f', 1, 64) },
+	"targets":       func(r CheckResult) string { return strings.Join(r.Targets, ";") },
+	"shared_exit":   func(r CheckResult) string { return r.SharedExit },
+	"blocklists":    func(r CheckResult) string { return strings.Join(r.Blocklists, ";") },
+	"tor_exit":      func(r CheckResult) string { return strconv.FormatBool(r.TorExit) },
+	"risk":          func(r CheckResult) string { return strconv.Itoa(r.Risk) },
+	"risk_flags":    func(r CheckResult) string { return strings.Join(r.RiskFlags, ";") },
+	"tls":           func(r CheckResult) string { return strconv.FormatBool(r.TLS) },
+	"mitm":          func(r CheckResult) string { return strconv.FormatBool(r.MITM) },
+	"clean":         func(r CheckResult) string { return strconv.FormatBool(r.Clean) },
+	"udp_support":   func(r CheckResult) string { return strconv.FormatBool(r.UDP) },
+	"dns":           func(r CheckResult) string { return r.DNS },
+	"keep_alive":    func(r CheckResult) string { return strconv.FormatBool(r.KeepAlive) },
+	"http2":         func(r CheckResult) string { return strconv.FormatBool(r.HTTP2) },
+	"cf_friendly":   func(r CheckResult) string { return strconv.FormatBool(r.CFFriendly) },
+	"search_passed": func(r CheckResult) string { return strings.Join(r.SearchPassed, ";") },
+	"headers":       func(r CheckResult) string { return formatHeaders(r.Headers) },
+	"vantages":      func(r CheckResult) string { return strings.Join(r.Vantages, ";") },
+	"checked_at":    func(r CheckResult) string { return resultTimestamps(r)[0] },
+	"expires_at":    func(r CheckResult) string { return resultTimestamps(r)[1] },
+}
+
+// ProxyRecord is the JSON representation of a checked proxy
+type ProxyRecord struct {
+	Proxy        string    `json:"proxy"`
+	Type         string    `json:"type"`
+	IP           string    `json:"ip,omitempty"`
+	Country      string    `json:"country,omitempty"`
+	CountryCode  string    `json:"country_code,omitempty"`
+	City         string    `json:"city,omitempty"`
+	ASN          uint      `json:"asn,omitempty"`
+	ASOrg        string    `json:"as_org,omitempty"`
+	ISP          string    `json:"isp,omitempty"`
+	LatencyMs    int64     `json:"latency_ms"`
+	ConnectMs    int64     `json:"connect_time_ms"`
+	TTFBMs       int64     `json:"ttfb_ms"`
+	TotalMs      int64     `json:"total_time_ms"`
+	Anonymous    bool      `json:"anonymous"`
+	Anonymity    string    `json:"anonymity"`
+	RealIPLeak   bool      `json:"real_ip_leak,omitempty"` // The judge saw the public IP of this machine
+	Network      string    `json:"network"`
+	Stability    float64   `json:"stability"`
+	Streak       int       `json:"streak"`
+	Score        float64   `json:"score,omitempty"` // Composite score of a working proxy
+	Throughput   float64   `json:"throughput_kbps,omitempty"`
+	Targets      []string  `json:"targets,omitempty"`
+	SearchPassed []string  `json:"search_passed,omitempty"` // Search engines answering without a captcha
+	SharedExit   string    `json:"shared_exit,omitempty"`   // First working proxy seen with the same exit IP
+	Blocklists   []string  `json:"blocklists,omitempty"`    // Blocklists listing the exit IP
+	TorExit      bool      `json:"tor_exit,omitempty"`
+	Risk         int       `json:"risk,omitempty"`        // Risk score from 0 to 100
+	RiskFlags    []string  `json:"risk_flags,omitempty"`  // Heuristics flagging the proxy as a possible honeypot
+	TLS          bool      `json:"tls,omitempty"`         // An https:// request through the proxy succeeded
+	MITM         bool      `json:"mitm,omitempty"`        // The proxy intercepts TLS
+	Clean        bool      `json:"clean,omitempty"`       // The test resource came through unmodified
+	UDP          bool      `json:"udp_support,omitempty"` // The SOCKS5 proxy relays UDP
+	DNS          string    `json:"dns,omitempty"`         // Where the SOCKS5 proxy resolves hostnames: remote or local
+	KeepAlive    bool      `json:"keep_alive,omitempty"`
+	HTTP2        bool      `json:"http2,omitempty"`       // Tunnels through the proxy negotiate HTTP/2
+	CFFriendly   bool      `json:"cf_friendly,omitempty"` // A site fronted by Cloudflare answers without a block or challenge
+	Vantages     []string  `json:"vantages,omitempty"`    // Vantage points the proxy works from
+	CheckedAt    time.Time `json:"checked_at,omitzero"`
+	ExpiresAt    time.Time `json:"expires_at,omitzero"` // Time after which the proxy should be checked again
+
+	// Headers of checker.capture_headers seen through the proxy, by canonical name
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// NewProxyRecord converts a check result to its JSON representation
+func NewProxyRecord(result CheckResult) ProxyRecord {
+	record := ProxyRecord{
+		Proxy:        result.Proxy,
+		Type:         result.Type.String(),
+		IP:           result.ProxyIP,
+		LatencyMs:    result.Speed.Milliseconds(),
+		ConnectMs:    result.ConnectTime.Milliseconds(),
+		TTFBMs:       result.TTFB.Milliseconds(),
+		TotalMs:      result.TotalTime.Milliseconds(),
+		Anonymous:    result.Anonymous,
+		Anonymity:    result.Anonymity.String(),
+		RealIPLeak:   result.RealIPLeak,
+		Network:      result.Network.String(),
+		Stability:    result.Stability,
+		Streak:       result.Streak,
+		Score:        result.Score,
+		Throughput:   result.Throughput,
+		Targets:      result.Targets,
+		SearchPassed: result.SearchPassed,
+		SharedExit:   result.SharedExit,
+		Blocklists:   result.Blocklists,
+		TorExit:      result.TorExit,
+		Risk:         result.Risk,
+		RiskFlags:    result.RiskFlags,
+		TLS:          result.TLS,
+		MITM:         result.MITM,
+		Clean:        result.Clean,
+		UDP:          result.UDP,
+		DNS:          result.DNS,
+		KeepAlive:    result.KeepAlive,
+		HTTP2:        result.HTTP2,
+		CFFriendly:   result.CFFriendly,
+		Headers:      result.Headers,
+		Vantages:     result.Vantages,
+		CheckedAt:    result.CheckedAt,
+		ExpiresAt:    result.ExpiresAt,
+	}
+	if result.Location != nil {
+		record.Country = result.Location.Country
+		record.CountryCode = result.Location.CountryCode
+		record.City = result.Location.City
+		record.ASN = result.Location.ASN
+		record.ASOrg = result.Location.ASOrg
+		record.ISP = result.Location.ISP
+	}
+	return record
+}
+
+// ProxyURI returns proxy prefixed with the scheme of its type, e.g.
+// socks5://1.2.3.4:1080
+func ProxyURI(proxyType ProxyType, proxy string) string {
+	return strings.ToLower(proxyType.String()) + "://" + proxy
+}
+
+// trimScheme returns proxy without its scheme, if any
+func trimScheme(proxy string) string {
+	if _, rest, found := strings.Cut(proxy, "://"); found {
+		return rest
+	}
+	return proxy
+}
+
+// OutputFile returns the path of the output file in dir of working proxies
+// of the given type, e.g. out/http.txt
+func OutputFile(dir string, proxyType ProxyType) string {
+	return filepath.Join(dir, strings.ToLower(proxyType.String())+".txt")
+}
+
+// outputPaths returns the text output files in dir, per-target and
+// per-country files included
+func outputPaths(dir string) ([]string, error) {
+	var paths []string
+	for _, pattern := range []string{
+		filepath.Join(dir, "targets", "*", "*.txt"),
+		filepath.Join(dir, "by_country", "*.txt"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	for _, proxyType := range DetectOrder {
+		paths = append(paths, OutputFile(dir, proxyType))
+	}
+	return paths, nil
+}
+
+// runTimeFormat is the format of the times naming the result sets of runs,
+// in UTC
+const runTimeFormat = "2006-01-02T15-04-05Z"
+
+// resultFiles returns the existing result files in dir: working proxies of
+// each type, per-target and per-country files included, the CSV file, the
+// stable proxies, the PAC file, the top proxies, the target matrix, the run
+// metadata and the client configurations
+func resultFiles(dir string) ([]string, error) {
+	paths, err := outputPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"proxies.csv", "stable.txt", "stable.json", "proxy.pac", "top.txt",
+		"target_matrix.json", "target_matrix.csv", "meta.json"} {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	for _, format := range slices.Sorted(maps.Keys(exporters)) {
+		paths = append(paths, filepath.Join(dir, exporters[format].file))
+	}
+
+	var existing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		existing = append(existing, path)
+	}
+	return existing, nil
+}
+
+// rewriteOutputs rewrites the text output files in dir, per-target and
+// per-country files included, and the CSV file at csvPath if it has a proxy
+// column. reorder is given the proxies of a file and returns the indices of
+// those to keep, in their new order. Header lines stay first.
+func rewriteOutputs(dir, csvPath string, reorder func(proxies []string) []int) error {
+	paths, err := outputPaths(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := rewriteLines(path, reorder); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := rewriteCSV(csvPath, reorder); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// rewriteLines rewrites an output file of working proxies with the lines
+// selected by reorder, keeping the header of detailed output
+func rewriteLines(path string, reorder func(proxies []string) []int) error {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	var kept []string
+	if strings.HasPrefix(lines[0], "Proxy|") {
+		kept, lines = append(kept, lines[0]), lines[1:]
+	}
+	proxies := make([]string, len(lines))
+	for i, line := range lines {
+		proxy, _, _ := strings.Cut(line, "|")
+		proxies[i] = trimScheme(proxy)
+	}
+	for _, i := range reorder(proxies) {
+		kept = append(kept, lines[i])
+	}
+	if len(kept) == 0 {
+		return writeFileAtomic(path, nil)
+	}
+	return writeFileAtomic(path, []byte(strings.Join(kept, "\n")+"\n"))
+}
+
+// rewriteCSV rewrites the CSV output with the rows selected by reorder
+func rewriteCSV(path string, reorder func(proxies []string) []int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	rows, err := csv.NewReader(file).ReadAll()
+	file.Close()
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	header, rows := rows[0], rows[1:]
+	column := slices.Index(header, "proxy")
+	if column < 0 {
+		return nil
+	}
+	proxies := make([]string, len(rows))
+	for i, row := range rows {
+		proxies[i] = row[column]
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(header)
+	for _, i := range reorder(proxies) {
+		w.Write(rows[i])
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b.Bytes())
+}
+
+// ReadOutputFile returns the proxies of an output file of working proxies,
+// dropping the header and extra columns of detailed output, and the scheme
+// of output.uri_scheme
+func ReadOutputFile(path string) ([]string, error) {
+	lines, err := ReadLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	proxies := make([]string, 0, len(lines))
+	for _, line := range lines {
+		proxy, _, _ := strings.Cut(line, "|")
+		if proxy != "Proxy" {
+			proxies = append(proxies, trimScheme(proxy))
+		}
+	}
+	return proxies, nil
+}
+
+// CSVWriter writes check results as CSV rows with a configurable column list
+type CSVWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	writer  *csv.Writer
+	columns []string
+}
+
+// NewCSVWriter creates (or truncates) the file at path and writes the header
+// row. An empty column list selects DefaultCSVColumns.
+func NewCSVWriter(path string, columns []string) (*CSVWriter, error) {
+	return openCSVWriter(path, columns, os.O_TRUNC)
+}
+
+// AppendCSVWriter opens the file at path for appending, writing the header
+// row only if the file is new or empty
+func AppendCSVWriter(path string, columns []string) (*CSVWriter, error) {
+	return openCSVWriter(path, columns, os.O_APPEND)
+}
+
+// openCSVWriter opens the file at path with the extra open flag, truncate or
+// append, and writes the header row to an empty file
+func openCSVWriter(path string, columns []string, flag int) (*CSVWriter, error) {
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+	for _, column := range columns {
+		if _, ok := csvColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	w := &CSVWriter{
+		file:    file,
+		writer:  csv.NewWriter(file),
+		columns: columns,
+	}
+	if info.Size() > 0 {
+		return w, nil
+	}
+	if err := w.writeRow(columns); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends a single result as a CSV row
+func (w *CSVWriter) Write(result CheckResult) error {
+	row := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		row[i] = csvColumns[column](result)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeRow(row)
+}
+
+// writeRow writes and flushes a row so partial results survive interruption
+func (w *CSVWriter) writeRow(row []string) error {
+	if err := w.writer.Write(row); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Close flushes pending data and closes the underlying file
+func (w *CSVWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// countryOutput writes working proxies to
+// <output dir>/by_country/<country code>_<type>.txt, e.g. DE_http.txt
+type countryOutput struct {
+	dir   string
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // Per file, created as countries are found
+}
+
+// newCountryOutput creates the directory of the per-country files, removing
+// those of a previous run if truncate is set
+func newCountryOutput(dir string, truncate bool) (*countryOutput, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if truncate {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &countryOutput{dir: dir, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+// Save appends line to the file of the country and type of result.
+// Proxies of unknown country are skipped.
+func (o *countryOutput) Save(result CheckResult, line string) error {
+	if result.Location == nil || !countryCodeRe.MatchString(result.Location.CountryCode) {
+		return nil
+	}
+
+	path := filepath.Join(o.dir, result.Location.CountryCode+"_"+strings.ToLower(result.Type.String())+".txt")
+	o.mu.Lock()
+	lock, ok := o.locks[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		o.locks[path] = lock
+	}
+	o.mu.Unlock()
+	return AppendLine(path, line, lock)
+}
+
+// countryCodeRe matches ISO 3166-1 alpha-2 country codes
+var countryCodeRe = regexp.MustCompile(`^[A-Z]{2}$`)
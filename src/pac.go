@@ -0,0 +1,154 @@
+package src
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultPACTemplate is the proxy.pac template used when output.pac_template
+// is empty. Each host is sent to one of the proxies, chosen by a hash of the
+// host so that it keeps the same exit, with the others as fallbacks in turn.
+const defaultPACTemplate = `// Generated by ProxyScraperChecker on {{.Generated.Format "2006-01-02 15:04:05 MST"}}
+var proxies = [
+{{- range $i, $proxy := .Proxies}}{{if $i}},{{end}}
+  "{{js $proxy}}"
+{{- end}}
+];
+
+function FindProxyForURL(url, host) {
+  if (isPlainHostName(host) || host === "127.0.0.1" || host === "::1" || shExpMatch(host, "*.local")) {
+    return "DIRECT";
+  }
+  if (proxies.length === 0) {
+    return "DIRECT";
+  }
+  var hash = 0;
+  for (var i = 0; i < host.length; i++) {
+    hash = (hash * 31 + host.charCodeAt(i)) % 2147483647;
+  }
+  var chain = [];
+  for (var i = 0; i < proxies.length; i++) {
+    chain.push(proxies[(hash + i) % proxies.length]);
+  }
+  return chain.join("; ");
+}
+`
+
+// pacData is the data given to the proxy.pac template
+type pacData struct {
+	Proxies   []string  // PAC directives of the fastest proxies, e.g. "PROXY 1.2.3.4:8080", fastest first
+	Generated time.Time // Time the file was written
+}
+
+// parsePACTemplate parses output.pac_template, or the built-in template if
+// it is empty
+func parsePACTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultPACTemplate
+	}
+	return template.New("proxy.pac").Parse(text)
+}
+
+// pacWriter records the working proxies of a run to write <output
+// dir>/proxy.pac from the fastest ones once checking ends. All methods are
+// safe to call on a nil *pacWriter, which writes nothing.
+type pacWriter struct {
+	size     int
+	template *template.Template
+	mu       sync.Mutex
+	results  []CheckResult
+}
+
+// newPACWriter returns the writer configured by output.pac_proxies, nil if
+// it is 0
+func newPACWriter(config *Config) (*pacWriter, error) {
+	if config.Output.PACProxies <= 0 {
+		return nil, nil
+	}
+	tmpl, err := parsePACTemplate(config.Output.PACTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &pacWriter{size: config.Output.PACProxies, template: tmpl}, nil
+}
+
+// add records a result saved to the output files. Proxies requiring
+// credentials are left out, since PAC files cannot carry them.
+func (w *pacWriter) add(result CheckResult) {
+	if w == nil {
+		return
+	}
+	if addr, err := ParseProxyAddr(result.Proxy); err != nil || addr.HasAuth() {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.results = append(w.results, result)
+}
+
+// write renders proxy.pac in dir with the output.pac_proxies fastest
+// proxies still in the output files, those removed by exit IP
+// deduplication excluded
+func (w *pacWriter) write(dir string) error {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	results := slices.Clone(w.results)
+	w.mu.Unlock()
+	results, err := keptResults(dir, results)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(results, func(a, b CheckResult) int { return cmp.Compare(a.Speed, b.Speed) })
+
+	data := pacData{Proxies: []string{}, Generated: time.Now()}
+	for _, result := range results[:min(w.size, len(results))] {
+		data.Proxies = append(data.Proxies, pacDirective(result))
+	}
+	var b bytes.Buffer
+	if err := w.template.Execute(&b, data); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "proxy.pac"), b.Bytes())
+}
+
+// keptResults returns the results whose proxies are still in the output
+// files of dir, those removed by exit IP deduplication excluded
+func keptResults(dir string, results []CheckResult) ([]CheckResult, error) {
+	kept := make(map[string]bool)
+	for _, proxyType := range DetectOrder {
+		proxies, err := ReadOutputFile(OutputFile(dir, proxyType))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, proxy := range proxies {
+			kept[proxyType.String()+" "+proxy] = true
+		}
+	}
+	return slices.DeleteFunc(results, func(r CheckResult) bool { return !kept[r.Type.String()+" "+r.Proxy] }), nil
+}
+
+// pacDirective returns the PAC directive of a proxy, e.g. "SOCKS5
+// 1.2.3.4:1080"
+func pacDirective(result CheckResult) string {
+	keyword := "PROXY"
+	switch result.Type {
+	case ProxyTypeHTTPS:
+		keyword = "HTTPS"
+	case ProxyTypeSOCKS4:
+		keyword = "SOCKS"
+	case ProxyTypeSOCKS5:
+		keyword = "SOCKS5"
+	}
+	return keyword + " " + result.Proxy
+}
@@ -0,0 +1,186 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxySource fetches the proxies of a source. Every source type, built in
+// or added with RegisterSource, is fetched by a ProxySource.
+type ProxySource interface {
+	// Fetch returns the proxies found by the source. On error, the proxies
+	// found so far may be returned along with it.
+	Fetch(ctx context.Context) ([]ProxyCandidate, error)
+}
+
+// ProxyCandidate is a proxy found by a source, checked with the protocol of
+// the source once scraping ends
+type ProxyCandidate struct {
+	Proxy string // In any format the scraper understands, e.g. IP:PORT, user:pass@IP:PORT or socks5://IP:PORT
+}
+
+// SourceFactory creates the ProxySource of a source of the type it is
+// registered for. It is called when sources are loaded, to check the
+// options of the source, then again each time the source is scraped.
+type SourceFactory func(source Source) (ProxySource, error)
+
+var (
+	sourceTypesMu sync.RWMutex
+	sourceTypes   = make(map[string]SourceFactory)
+)
+
+func init() {
+	for _, typ := range []string{SourceURL, SourceTelegram, SourceGitHub} {
+		RegisterSource(typ, func(source Source) (ProxySource, error) { return httpSource{source}, nil })
+	}
+	RegisterSource(SourceFile, func(source Source) (ProxySource, error) { return fileSource{source}, nil })
+	RegisterSource(SourceStdin, func(source Source) (ProxySource, error) { return stdinSource{source}, nil })
+	RegisterSource(SourceCommand, func(source Source) (ProxySource, error) { return commandSource{source}, nil })
+	RegisterSource(SourceCIDR, func(source Source) (ProxySource, error) { return cidrSource{source}, nil })
+}
+
+// RegisterSource makes a source type available to the entries of
+// sources.yaml, which select it with type and pass it settings with
+// options. Types are case-insensitive. It panics if the type is already
+// registered or factory is nil.
+func RegisterSource(typ string, factory SourceFactory) {
+	typ = strings.ToLower(typ)
+	if factory == nil {
+		panic("src: RegisterSource factory is nil for type " + typ)
+	}
+	sourceTypesMu.Lock()
+	defer sourceTypesMu.Unlock()
+	if _, ok := sourceTypes[typ]; ok {
+		panic("src: RegisterSource called twice for type " + typ)
+	}
+	sourceTypes[typ] = factory
+}
+
+// sourceFactory returns the factory registered for a source type
+func sourceFactory(typ string) (SourceFactory, bool) {
+	sourceTypesMu.RLock()
+	defer sourceTypesMu.RUnlock()
+	factory, ok := sourceTypes[strings.ToLower(typ)]
+	return factory, ok
+}
+
+// candidates wraps proxies as ProxyCandidates
+func candidates(proxies []string) []ProxyCandidate {
+	found := make([]ProxyCandidate, len(proxies))
+	for i, proxy := range proxies {
+		found[i] = ProxyCandidate{Proxy: proxy}
+	}
+	return found
+}
+
+// httpSource fetches url, telegram and github sources over HTTP
+type httpSource struct {
+	source Source
+}
+
+func (s httpSource) Fetch(ctx context.Context) ([]ProxyCandidate, error) {
+	proxies, err := scrapeHTTPSource(ctx, s.source.client, s.source, s.source.userAgent)
+	return candidates(proxies), err
+}
+
+// parseLocal extracts the proxies of a local source with its regex or
+// parser, once converted to UTF-8
+func parseLocal(body []byte, source Source) ([]ProxyCandidate, error) {
+	proxies, err := parseSourceBody(toUTF8("", body), "", source)
+	return candidates(proxies), err
+}
+
+// fileSource reads a local proxy list, read again on every scrape
+type fileSource struct {
+	source Source
+}
+
+func (s fileSource) Fetch(ctx context.Context) ([]ProxyCandidate, error) {
+	file, err := os.Open(s.source.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	body, err := readLimited(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseLocal(body, s.source)
+}
+
+// stdinBody is the standard input, read once by the first stdin source
+// scraped
+var stdinBody = sync.OnceValues(func() ([]byte, error) {
+	return readLimited(os.Stdin)
+})
+
+// stdinSource reads a proxy list from the standard input
+type stdinSource struct {
+	source Source
+}
+
+func (s stdinSource) Fetch(ctx context.Context) ([]ProxyCandidate, error) {
+	body, err := stdinBody()
+	if err != nil {
+		return nil, err
+	}
+	return parseLocal(body, s.source)
+}
+
+// commandSource runs a command and reads a proxy list from its output
+type commandSource struct {
+	source Source
+}
+
+// Fetch runs the command, which is killed if it does not exit within the
+// source timeout. If it fails, the proxies it printed are returned along
+// with the last line of its standard error.
+func (s commandSource) Fetch(ctx context.Context) ([]ProxyCandidate, error) {
+	if s.source.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.source.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.source.Command[0], s.source.Command[1:]...)
+	cmd.Stdout = &limitedBuffer{Buffer: &stdout, limit: maxSourceBody}
+	cmd.Stderr = &limitedBuffer{Buffer: &stderr, limit: 64 << 10}
+	// Children left running with the output open must not block Wait
+	cmd.WaitDelay = time.Second
+	runErr := cmd.Run()
+
+	found, err := parseLocal(stdout.Bytes(), s.source)
+	if runErr != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			runErr = fmt.Errorf("%w: %s", runErr, last)
+		}
+		return found, runErr
+	}
+	if stdout.Len() > maxSourceBody {
+		return found, fmt.Errorf("output larger than %d MB", maxSourceBody>>20)
+	}
+	return found, err
+}
+
+// limitedBuffer writes up to limit+1 bytes to Buffer and discards the rest,
+// so that a runaway command cannot exhaust memory
+type limitedBuffer struct {
+	*bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit + 1 - b.Len(); room < len(p) {
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
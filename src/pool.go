@@ -0,0 +1,159 @@
+package src
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Pool holds working proxies that can be handed out to consumers
+type Pool struct {
+	mu      sync.RWMutex
+	entries map[string]*poolEntry
+
+	subMu       sync.Mutex
+	subscribers map[chan CheckResult]struct{}
+}
+
+// poolEntry is a pooled proxy with its consecutive failure count
+type poolEntry struct {
+	result   CheckResult
+	failures int
+}
+
+// NewPool creates an empty proxy pool
+func NewPool() *Pool {
+	return &Pool{
+		entries:     make(map[string]*poolEntry),
+		subscribers: make(map[chan CheckResult]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every result added to the pool,
+// working or not, until cancel is called. Results are dropped while the
+// channel holds buffer unread results, so that a slow subscriber never
+// holds up checking.
+func (p *Pool) Subscribe(buffer int) (results <-chan CheckResult, cancel func()) {
+	ch := make(chan CheckResult, buffer)
+	p.subMu.Lock()
+	p.subscribers[ch] = struct{}{}
+	p.subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			p.subMu.Lock()
+			delete(p.subscribers, ch)
+			p.subMu.Unlock()
+		})
+	}
+}
+
+// publish sends a result to the subscribers with room for it
+func (p *Pool) publish(result CheckResult) {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	for ch := range p.subscribers {
+		select {
+		case ch <- result:
+		default:
+		}
+	}
+}
+
+// Add adds or refreshes a working proxy in the pool and sends the result to
+// the subscribers. Non-working results remove the proxy instead.
+func (p *Pool) Add(result CheckResult) {
+	p.publish(result)
+	if !result.Working {
+		p.Remove(result.Proxy)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[result.Proxy] = &poolEntry{result: result}
+}
+
+// Remove removes a proxy from the pool
+func (p *Pool) Remove(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, proxy)
+}
+
+// Load adds the proxies of an output file of working proxies, such as
+// out/http.txt, to the pool as working proxies of the given type. It returns
+// the number of proxies read.
+func (p *Pool) Load(path string, proxyType ProxyType) (int, error) {
+	proxies, err := ReadOutputFile(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, proxy := range proxies {
+		p.Add(CheckResult{Proxy: proxy, Type: proxyType, Working: true})
+	}
+	return len(proxies), nil
+}
+
+// Len returns the number of proxies in the pool
+func (p *Pool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.entries)
+}
+
+// List returns a snapshot of all proxies in the pool
+func (p *Pool) List() []CheckResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	results := make([]CheckResult, 0, len(p.entries))
+	for _, entry := range p.entries {
+		results = append(results, entry.result)
+	}
+	return results
+}
+
+// Random returns a randomly chosen proxy, skipping the ones in exclude
+func (p *Pool) Random(exclude map[string]bool) (CheckResult, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	candidates := make([]CheckResult, 0, len(p.entries))
+	for proxy, entry := range p.entries {
+		if !exclude[proxy] {
+			candidates = append(candidates, entry.result)
+		}
+	}
+	if len(candidates) == 0 {
+		return CheckResult{}, false
+	}
+	return candidates[rand.Intn(len(candidates))], true
+}
+
+// ReportSuccess resets the failure count of a proxy
+func (p *Pool) ReportSuccess(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[proxy]; ok {
+		entry.failures = 0
+	}
+}
+
+// ReportFailure records a failed use of a proxy and evicts it once it has
+// failed maxFailures times in a row. It returns true if the proxy was evicted.
+func (p *Pool) ReportFailure(proxy string, maxFailures int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.entries[proxy]
+	if !ok {
+		return false
+	}
+	entry.failures++
+	if entry.failures >= maxFailures {
+		delete(p.entries, proxy)
+		return true
+	}
+	return false
+}
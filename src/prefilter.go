@@ -0,0 +1,79 @@
+package src
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// Prefilter connects to the port of every proxy of lists, up to
+// checker.prefilter_concurrent at once, and removes the proxies whose port
+// does not accept a TCP connection within checker.prefilter_timeout. Each
+// address is tried once, even if it is in several lists, and the lists keep
+// their order. Connections go through checker.upstream_proxy if set. It
+// returns the filtered lists and the number of proxies removed. If ctx is cancelled, the proxies not tried yet are kept.
+func Prefilter(ctx context.Context, config *Config, lists ...[]string) ([][]string, int) {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, proxies := range lists {
+		for _, proxy := range proxies {
+			addr, err := ParseProxyAddr(proxy)
+			if err != nil || seen[addr.HostPort()] {
+				continue
+			}
+			seen[addr.HostPort()] = true
+			addrs = append(addrs, addr.HostPort())
+		}
+	}
+
+	var mu sync.Mutex
+	unreachable := make(map[string]bool)
+	// checker.upstream_proxy was validated with the configuration
+	upstream, _ := CheckerUpstream(config)
+	dialer := newUpstreamDialer(&net.Dialer{Timeout: config.Checker.PrefilterTimeout}, upstream, config.Checker.PrefilterTimeout)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(config.Checker.PrefilterConcurrent, len(addrs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				conn, err := dialer.DialContext(ctx, "tcp", addr)
+				if err == nil {
+					conn.Close()
+					continue
+				}
+				if ctx.Err() == nil {
+					mu.Lock()
+					unreachable[addr] = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+feed:
+	for _, addr := range addrs {
+		select {
+		case jobs <- addr:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	filtered := make([][]string, len(lists))
+	removed := 0
+	for i, proxies := range lists {
+		kept := proxies[:0:0]
+		for _, proxy := range proxies {
+			if addr, err := ParseProxyAddr(proxy); err == nil && unreachable[addr.HostPort()] {
+				removed++
+				continue
+			}
+			kept = append(kept, proxy)
+		}
+		filtered[i] = kept
+	}
+	return filtered, removed
+}
@@ -0,0 +1,117 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// SourceProbe is the outcome of checking that a source can be reached,
+// without scraping it
+type SourceProbe struct {
+	Source Source
+	Target string // URL requested, file or program looked up, empty if the source type is not probed
+	Err    error
+}
+
+// ProbeSources checks that sources can be reached without scraping them,
+// config.Concurrent at once: url and telegram sources with a HEAD request
+// for their first page, file sources by the existence of their file and
+// command sources by the lookup of their program. github, stdin and
+// registered sources are not probed. Probes are returned in the order of
+// sources.
+func ProbeSources(ctx context.Context, client *http.Client, sources []Source, config ScraperConfig) []SourceProbe {
+	probes := make([]SourceProbe, len(sources))
+	semaphore := make(chan struct{}, config.Concurrent)
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				probes[i] = SourceProbe{Source: source, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-semaphore }()
+
+			if source.Timeout == 0 {
+				source.Timeout = config.Timeout
+			}
+			probes[i] = probeSource(ctx, client, source, config.UserAgents[i%len(config.UserAgents)])
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+// probeSource checks that a single source can be reached
+func probeSource(ctx context.Context, client *http.Client, source Source, userAgent string) SourceProbe {
+	probe := SourceProbe{Source: source}
+	switch source.Type {
+	case SourceURL:
+		probe.Target = source.PageURLs()[0]
+	case SourceTelegram:
+		probe.Target = telegramPreviewURL + source.Channel
+		if source.BotToken != "" {
+			probe.Target = telegramAPIURL
+		}
+	case SourceFile:
+		probe.Target = source.Path
+		_, probe.Err = os.Stat(source.Path)
+		return probe
+	case SourceCommand:
+		probe.Target = source.Command[0]
+		_, probe.Err = exec.LookPath(source.Command[0])
+		return probe
+	default:
+		return probe
+	}
+	probe.Err = headSource(ctx, client, source, probe.Target, userAgent)
+	return probe
+}
+
+// headSource sends a HEAD request for a page of source, applying its
+// timeout and headers. Servers answering that the method is not allowed or
+// implemented are reachable, as they answer GET requests instead.
+func headSource(ctx context.Context, client *http.Client, source Source, rawURL, userAgent string) error {
+	if source.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, source.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	for name, value := range source.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL is already the target of the probe
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed, resp.StatusCode == http.StatusNotImplemented:
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return &statusError{status: resp.Status, code: resp.StatusCode}
+	}
+	return nil
+}
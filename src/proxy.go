@@ -0,0 +1,119 @@
+package src
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ProxyAddr is a proxy address with optional credentials
+type ProxyAddr struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+// ParseProxyAddr parses a proxy in one of the formats host:port,
+// user:pass@host:port or host:port:user:pass. A leading scheme such as
+// http:// or socks5:// is ignored.
+func ParseProxyAddr(s string) (ProxyAddr, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+
+	var addr ProxyAddr
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		// user:pass@host:port
+		user, pass, ok := strings.Cut(s[:i], ":")
+		if !ok {
+			return ProxyAddr{}, fmt.Errorf("invalid credentials in proxy %q", s)
+		}
+		addr.Username, addr.Password = user, pass
+		s = s[i+1:]
+	} else if parts := strings.Split(s, ":"); len(parts) == 4 && !strings.HasPrefix(s, "[") {
+		// host:port:user:pass, not a bracketed IPv6 address
+		addr.Username, addr.Password = parts[2], parts[3]
+		s = parts[0] + ":" + parts[1]
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return ProxyAddr{}, err
+	}
+	if host == "" || port == "" {
+		return ProxyAddr{}, fmt.Errorf("missing host or port in proxy %q", s)
+	}
+	addr.Host, addr.Port = host, port
+	return addr, nil
+}
+
+// HostPort returns the host:port part of the address
+func (a ProxyAddr) HostPort() string {
+	return net.JoinHostPort(a.Host, a.Port)
+}
+
+// HasAuth reports whether the proxy requires credentials
+func (a ProxyAddr) HasAuth() bool {
+	return a.Username != ""
+}
+
+// String returns the canonical user:pass@host:port form of the address
+func (a ProxyAddr) String() string {
+	if a.HasAuth() {
+		return a.Username + ":" + a.Password + "@" + a.HostPort()
+	}
+	return a.HostPort()
+}
+
+// URL returns the proxy as a URL with the given scheme, including credentials
+func (a ProxyAddr) URL(scheme string) *url.URL {
+	u := &url.URL{Scheme: scheme, Host: a.HostPort()}
+	if a.HasAuth() {
+		u.User = url.UserPassword(a.Username, a.Password)
+	}
+	return u
+}
+
+// IsIPv6 reports whether the proxy host is an IPv6 address
+func (a ProxyAddr) IsIPv6() bool {
+	ip := net.ParseIP(a.Host)
+	return ip != nil && ip.To4() == nil
+}
+
+// hasIPv6Route reports whether the local host can reach IPv6 destinations.
+// Dialing UDP sends no packets but fails when there is no route.
+func hasIPv6Route() bool {
+	conn, err := net.Dial("udp6", "[2001:4860:4860::8888]:53")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// FilterIPv6 removes IPv6 proxies according to the checker.ipv6 mode:
+// "off" always removes them, "on" keeps them and "auto" removes them only
+// when the local host has no IPv6 route. It returns the remaining proxies
+// and the number removed.
+func FilterIPv6(proxies []string, mode string) ([]string, int) {
+	switch mode {
+	case "on":
+		return proxies, 0
+	case "auto":
+		if hasIPv6Route() {
+			return proxies, 0
+		}
+	}
+
+	kept := proxies[:0:0]
+	for _, p := range proxies {
+		if addr, err := ParseProxyAddr(p); err == nil && addr.IsIPv6() {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, len(proxies) - len(kept)
+}
@@ -0,0 +1,59 @@
+package src
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by concurrent workers. Tokens are
+// added at a fixed rate up to the burst size, and each request takes one,
+// waiting for it if the bucket is empty. All methods are safe to call on a
+// nil *RateLimiter, which does not limit.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64
+	tokens float64 // Negative when requests are waiting for tokens
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perSecond requests per second on
+// average, and bursts of up to one second worth of requests
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	burst := math.Max(1, math.Ceil(perSecond))
+	return &RateLimiter{rate: perSecond, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	// Reserve a token, possibly one that is only added in the future
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the reserved token back to the waiting requests
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
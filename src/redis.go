@@ -0,0 +1,481 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisTimeout       = 10 * time.Second // Limit of a connection or pipeline round trip
+	redisBatch         = 500              // Maximum number of results published in one pipeline
+	redisQueue         = 1000             // Results waiting to be published before Publish blocks
+	redisPruneInterval = time.Minute      // Interval at which expired proxies are removed from the sorted sets
+	redisPruneBatch    = 10000            // Maximum number of expired proxies removed at once
+)
+
+// RedisPool publishes check results to Redis, where several instances and
+// any number of consumers share them as one live pool. With the prefix
+// proxies:, working proxies are stored in:
+//
+//   - proxies:proxy:<uri>, the JSON record of the REST API, expiring after redis.ttl
+//   - proxies:type:<type>, a sorted set of the proxies of a type scored by latency in milliseconds
+//   - proxies:country:<code>, a sorted set of the proxies of a country scored by latency in milliseconds
+//   - proxies:countries, the set of the country codes with a sorted set
+//   - proxies:expiry, a sorted set of all proxies scored by the Unix time in milliseconds they expire at
+//
+// where <uri> is the proxy prefixed with its type, e.g. socks5://1.2.3.4:1080
+// and <type> is http, https, socks4 or socks5. Proxies failing a check are
+// removed, and expired ones once a minute. Every set expires redis.ttl after
+// the last result published to it. All methods are safe to call on a nil
+// *RedisPool, which publishes nothing.
+type RedisPool struct {
+	client *redisClient
+	prefix string
+	ttl    time.Duration
+	queue  chan CheckResult
+	done   chan struct{}
+	pruned time.Time
+}
+
+// NewRedisPool connects to the Redis server of redis.url and returns the
+// pool publishing to it, nil if no URL is set
+func NewRedisPool(ctx context.Context, config *Config) (*RedisPool, error) {
+	if config.Redis.URL == "" {
+		return nil, nil
+	}
+	client, err := newRedisClient(config.Redis.URL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.do(ctx, []string{"PING"}); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	p := &RedisPool{
+		client: client,
+		prefix: config.Redis.Prefix,
+		ttl:    config.Redis.TTL,
+		queue:  make(chan CheckResult, redisQueue),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Addr returns the address of the Redis server
+func (p *RedisPool) Addr() string {
+	if p == nil {
+		return ""
+	}
+	return p.client.addr
+}
+
+// Publish queues a check result for publishing: a working proxy is added
+// to the pool or refreshed, a failing one removed from it. It only blocks
+// while the queue is full.
+func (p *RedisPool) Publish(result CheckResult) {
+	if p == nil {
+		return
+	}
+	p.queue <- result
+}
+
+// Close publishes the queued results and closes the connection. Publish
+// must not be called afterwards.
+func (p *RedisPool) Close() error {
+	if p == nil {
+		return nil
+	}
+	close(p.queue)
+	<-p.done
+	return p.client.Close()
+}
+
+// run publishes the queued results in pipelines of up to redisBatch results
+// until the queue is closed, and prunes expired proxies between them
+func (p *RedisPool) run() {
+	defer close(p.done)
+	for result := range p.queue {
+		batch := []CheckResult{result}
+		for len(batch) < redisBatch && len(p.queue) > 0 {
+			batch = append(batch, <-p.queue)
+		}
+		if err := p.publish(batch); err != nil {
+			slog.Error("Error publishing proxies to Redis", "count", len(batch), "error", err)
+		}
+
+		if time.Since(p.pruned) >= redisPruneInterval {
+			if err := p.prune(); err != nil {
+				slog.Error("Error pruning Redis pool", "error", err)
+			}
+			p.pruned = time.Now()
+		}
+	}
+}
+
+// key returns the key named by parts, e.g. proxies:type:http
+func (p *RedisPool) key(parts ...string) string {
+	return p.prefix + strings.Join(parts, ":")
+}
+
+// publish adds the working proxies of batch to the pool and removes the
+// failing ones. Only the last result of a proxy in the batch counts.
+func (p *RedisPool) publish(batch []CheckResult) error {
+	ctx := context.Background()
+	latest := make(map[string]CheckResult, len(batch))
+	for _, result := range batch {
+		latest[result.Proxy] = result
+	}
+
+	now := time.Now()
+	ttl := strconv.FormatInt(p.ttl.Milliseconds(), 10)
+	expiresAt := strconv.FormatInt(now.Add(p.ttl).UnixMilli(), 10)
+	var failed []string
+	var cmds [][]string
+	touched := make(map[string]bool)
+	for _, result := range latest {
+		if !result.Working {
+			failed = append(failed, redisMembers(result)...)
+			continue
+		}
+
+		uri := ProxyURI(result.Type, result.Proxy)
+		record, err := json.Marshal(NewProxyRecord(result))
+		if err != nil {
+			return err
+		}
+		score := strconv.FormatInt(result.Speed.Milliseconds(), 10)
+		typeKey := p.key("type", strings.ToLower(result.Type.String()))
+		cmds = append(cmds,
+			[]string{"SET", p.key("proxy", uri), string(record), "PX", ttl},
+			[]string{"ZADD", typeKey, score, uri},
+			[]string{"ZADD", p.key("expiry"), expiresAt, uri})
+		touched[typeKey] = true
+		touched[p.key("expiry")] = true
+		if result.Location != nil && result.Location.CountryCode != "" {
+			code := strings.ToUpper(result.Location.CountryCode)
+			countryKey := p.key("country", code)
+			cmds = append(cmds,
+				[]string{"ZADD", countryKey, score, uri},
+				[]string{"SADD", p.key("countries"), code})
+			touched[countryKey] = true
+			touched[p.key("countries")] = true
+		}
+	}
+	for key := range touched {
+		cmds = append(cmds, []string{"PEXPIRE", key, ttl})
+	}
+
+	// Most failing proxies were never in the pool, and need no removal
+	if len(failed) > 0 {
+		scores := make([][]string, len(failed))
+		for i, uri := range failed {
+			scores[i] = []string{"ZSCORE", p.key("expiry"), uri}
+		}
+		replies, err := p.client.do(ctx, scores...)
+		if err != nil {
+			return err
+		}
+		var pooled []string
+		for i, reply := range replies {
+			if reply != nil {
+				pooled = append(pooled, failed[i])
+			}
+		}
+		if len(pooled) > 0 {
+			removal, err := p.removeCommands(ctx, pooled)
+			if err != nil {
+				return err
+			}
+			cmds = append(cmds, removal...)
+		}
+	}
+
+	if len(cmds) == 0 {
+		return nil
+	}
+	_, err := p.client.do(ctx, cmds...)
+	return err
+}
+
+// redisMembers returns the members a failing proxy may be pooled as: as
+// its type, or as every type it may have been detected as if its protocol
+// is unknown
+func redisMembers(result CheckResult) []string {
+	if result.Type != ProxyTypeAuto {
+		return []string{ProxyURI(result.Type, result.Proxy)}
+	}
+	members := make([]string, len(DetectOrder))
+	for i, proxyType := range DetectOrder {
+		members[i] = ProxyURI(proxyType, result.Proxy)
+	}
+	return members
+}
+
+// removeCommands returns the commands removing the proxies with the given
+// URIs from the pool
+func (p *RedisPool) removeCommands(ctx context.Context, uris []string) ([][]string, error) {
+	replies, err := p.client.do(ctx, []string{"SMEMBERS", p.key("countries")})
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{p.key("expiry")}
+	for _, proxyType := range DetectOrder {
+		keys = append(keys, p.key("type", strings.ToLower(proxyType.String())))
+	}
+	codes, _ := replies[0].([]any)
+	for _, code := range codes {
+		if code, ok := code.(string); ok {
+			keys = append(keys, p.key("country", code))
+		}
+	}
+
+	records := []string{"DEL"}
+	for _, uri := range uris {
+		records = append(records, p.key("proxy", uri))
+	}
+	cmds := [][]string{records}
+	for _, key := range keys {
+		cmds = append(cmds, append([]string{"ZREM", key}, uris...))
+	}
+	return cmds, nil
+}
+
+// prune removes the proxies that expired from the sorted sets
+func (p *RedisPool) prune() error {
+	ctx := context.Background()
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	replies, err := p.client.do(ctx, []string{"ZRANGEBYSCORE", p.key("expiry"), "-inf", now, "LIMIT", "0", strconv.Itoa(redisPruneBatch)})
+	if err != nil {
+		return err
+	}
+	members, _ := replies[0].([]any)
+	uris := make([]string, 0, len(members))
+	for _, member := range members {
+		if uri, ok := member.(string); ok {
+			uris = append(uris, uri)
+		}
+	}
+	if len(uris) == 0 {
+		return nil
+	}
+	cmds, err := p.removeCommands(ctx, uris)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.do(ctx, cmds...)
+	return err
+}
+
+// redisClient is a minimal client of the Redis protocol, RESP2, sending
+// commands in pipelines over a single connection that is opened again after
+// a network error
+type redisClient struct {
+	mu       sync.Mutex
+	addr     string
+	tls      *tls.Config // nil for redis:// URLs
+	username string
+	password string
+	db       int
+
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// newRedisClient returns a client of the server at rawURL, of the form
+// redis://[user:password@]host[:port][/db], or rediss:// over TLS. It does
+// not connect until the first command is sent.
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid URL %q, expected redis:// or rediss://", rawURL)
+	}
+
+	c := &redisClient{addr: u.Host}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Scheme == "rediss" {
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("invalid database %q in URL %q", db, rawURL)
+		}
+	}
+	return c, nil
+}
+
+// do sends cmds in a pipeline and returns their replies, each a string,
+// int64, []any or nil. If a command fails, all replies are still returned,
+// along with the error of the first failing command.
+func (c *redisClient) do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	replies, err := c.roundTrip(ctx, cmds)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// Replies left unread would be taken for those of the next commands
+		c.conn.Close()
+		c.conn = nil
+	}
+	return replies, err
+}
+
+// connect opens the connection, authenticates and selects the database
+func (c *redisClient) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	if c.tls != nil {
+		conn = tls.Client(conn, c.tls)
+	}
+	c.conn, c.r, c.w = conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+
+	var setup [][]string
+	switch {
+	case c.username != "" && c.password != "":
+		setup = append(setup, []string{"AUTH", c.username, c.password})
+	case c.password != "":
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		if _, err := c.roundTrip(ctx, setup); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip writes cmds and reads their replies within redisTimeout
+func (c *redisClient) roundTrip(ctx context.Context, cmds [][]string) ([]any, error) {
+	deadline := time.Now().Add(redisTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+
+	for _, cmd := range cmds {
+		fmt.Fprintf(c.w, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, len(cmds))
+	var firstErr error
+	for i := range replies {
+		reply, err := readRedisReply(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if replyErr, ok := reply.(redisError); ok && firstErr == nil {
+			firstErr = replyErr
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// Close closes the connection, if open
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// readRedisReply reads a reply: a string for simple and bulk strings, nil
+// for null ones, an int64 for integers, a []any for arrays and a redisError
+// for errors
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("invalid reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return redisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[n:]) != "\r\n" {
+			return nil, fmt.Errorf("invalid bulk string of %d bytes %q", n, buf)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("invalid reply %q", line)
+}
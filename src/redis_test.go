@@ -0,0 +1,182 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRedisReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  any
+		err   bool
+	}{
+		{"simple string", "+OK\r\n", "OK", false},
+		{"error", "-ERR unknown command\r\n", redisError("ERR unknown command"), false},
+		{"integer", ":42\r\n", int64(42), false},
+		{"bulk string", "$5\r\nhello\r\n", "hello", false},
+		{"bulk string with CRLF", "$7\r\nab\r\ncd \r\n", "ab\r\ncd ", false},
+		{"empty bulk string", "$0\r\n\r\n", "", false},
+		{"nil bulk string", "$-1\r\n", nil, false},
+		{"nil array", "*-1\r\n", nil, false},
+		{"nested array", "*3\r\n$2\r\nDE\r\n*2\r\n:1\r\n$-1\r\n-WRONGTYPE\r\n", []any{"DE", []any{int64(1), nil}, redisError("WRONGTYPE")}, false},
+		{"short bulk string", "$10\r\nhello\r\n", nil, true},
+		{"bulk string without CRLF", "$2\r\nabcd", nil, true},
+		{"short array", "*2\r\n:1\r\n", nil, true},
+		{"missing CRLF", "+OK\n", nil, true},
+		{"unknown type", "?1\r\n", nil, true},
+		{"invalid integer", ":x\r\n", nil, true},
+		{"empty", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRedisReply(bufio.NewReader(strings.NewReader(tt.reply)))
+			if (err != nil) != tt.err {
+				t.Fatalf("readRedisReply error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readRedisReply = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRedis serves conn, answering each command with the reply of answer,
+// and sends the commands it received on the returned channel
+func fakeRedis(conn net.Conn, answer func(cmd []string) string) <-chan []string {
+	received := make(chan []string, 100)
+	go func() {
+		defer close(received)
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			reply, err := readRedisReply(r)
+			if err != nil {
+				return
+			}
+			items, _ := reply.([]any)
+			cmd := make([]string, len(items))
+			for i, item := range items {
+				cmd[i], _ = item.(string)
+			}
+			received <- cmd
+			if _, err := io.WriteString(conn, answer(cmd)); err != nil {
+				return
+			}
+		}
+	}()
+	return received
+}
+
+// pipeRedisClient returns a client connected to the server end of a pipe
+func pipeRedisClient() (*redisClient, net.Conn) {
+	client, server := net.Pipe()
+	c := &redisClient{addr: "pipe", conn: client, r: bufio.NewReader(client), w: bufio.NewWriter(client)}
+	return c, server
+}
+
+func TestRedisClientRoundTrip(t *testing.T) {
+	c, server := pipeRedisClient()
+	defer c.Close()
+	received := fakeRedis(server, func(cmd []string) string {
+		switch cmd[0] {
+		case "SET":
+			return "+OK\r\n"
+		case "ZSCORE":
+			return "$-1\r\n"
+		case "SMEMBERS":
+			return "*2\r\n$2\r\nDE\r\n$2\r\nUS\r\n"
+		default:
+			return "-ERR unknown command '" + cmd[0] + "'\r\n"
+		}
+	})
+
+	replies, err := c.do(context.Background(),
+		[]string{"SET", "proxies:proxy:http://1.2.3.4:8080", "{\"a\": \"b c\"}\r\n", "PX", "60000"},
+		[]string{"ZSCORE", "proxies:expiry", "http://1.2.3.4:8080"},
+		[]string{"SMEMBERS", "proxies:countries"})
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	want := []any{"OK", nil, []any{"DE", "US"}}
+	if !reflect.DeepEqual(replies, want) {
+		t.Errorf("do = %#v, want %#v", replies, want)
+	}
+	if got := <-received; !reflect.DeepEqual(got, []string{"SET", "proxies:proxy:http://1.2.3.4:8080", "{\"a\": \"b c\"}\r\n", "PX", "60000"}) {
+		t.Errorf("server received %q", got)
+	}
+
+	// An error reply fails the pipeline but keeps the connection usable
+	replies, err = c.do(context.Background(), []string{"BOGUS"}, []string{"SET", "k", "v"})
+	var replyErr redisError
+	if !errors.As(err, &replyErr) || string(replyErr) != "ERR unknown command 'BOGUS'" {
+		t.Errorf("do error = %v, want the error reply", err)
+	}
+	if len(replies) != 2 || replies[1] != "OK" {
+		t.Errorf("do = %#v, want the replies of all commands", replies)
+	}
+	if c.conn == nil {
+		t.Error("connection closed after an error reply")
+	}
+}
+
+func TestRedisClientShortReply(t *testing.T) {
+	c, server := pipeRedisClient()
+	defer c.Close()
+	go func() {
+		r := bufio.NewReader(server)
+		if _, err := readRedisReply(r); err == nil {
+			fmt.Fprint(server, "$10\r\nhello")
+		}
+		server.Close()
+	}()
+
+	if _, err := c.do(context.Background(), []string{"GET", "k"}); err == nil {
+		t.Fatal("do succeeded with a truncated reply")
+	}
+	// Unread replies would be taken for those of the next commands
+	if c.conn != nil {
+		t.Error("connection kept after a truncated reply")
+	}
+}
+
+func TestRedisClientConnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	commands := make(chan (<-chan []string), 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		commands <- fakeRedis(conn, func(cmd []string) string { return "+OK\r\n" })
+	}()
+
+	c, err := newRedisClient("redis://user:secret@" + listener.Addr().String() + "/2")
+	if err != nil {
+		t.Fatalf("newRedisClient: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.do(context.Background(), []string{"PING"}); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+
+	received := <-commands
+	for _, want := range [][]string{{"AUTH", "user", "secret"}, {"SELECT", "2"}, {"PING"}} {
+		if got := <-received; !reflect.DeepEqual(got, want) {
+			t.Errorf("server received %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blocklistName is the name reported for proxies listed in reputation.blocklist
+const blocklistName = "blocklist"
+
+// Answers of DNSBL queries for listed addresses, and the Spamhaus range of
+// error codes among them
+var (
+	dnsblListed = netip.MustParsePrefix("127.0.0.0/8")
+	dnsblErrors = netip.MustParsePrefix("127.255.255.0/24")
+)
+
+// Reputation checks exit IPs against DNS blocklists and a local list of
+// blocked networks. All methods are safe to call on a nil *Reputation,
+// which lists nothing.
+type Reputation struct {
+	zones    []string
+	networks []netip.Prefix
+	timeout  time.Duration // Timeout of each DNSBL query
+	mu       sync.Mutex
+	cache    map[netip.Addr][]string // Blocklists listing each IP looked up so far
+}
+
+// NewReputation returns the reputation checker configured by the reputation
+// section, nil if neither DNSBL zones nor a blocklist file are set
+func NewReputation(config *Config) (*Reputation, error) {
+	if !config.Reputation.Enabled() {
+		return nil, nil
+	}
+
+	r := &Reputation{
+		zones:   config.Reputation.DNSBLZones,
+		timeout: config.Checker.ConnectTimeout,
+		cache:   make(map[netip.Addr][]string),
+	}
+	if config.Reputation.Blocklist != "" {
+		networks, err := readBlocklist(config.Reputation.Blocklist)
+		if err != nil {
+			return nil, err
+		}
+		r.networks = networks
+	}
+	return r, nil
+}
+
+// readBlocklist reads a file of IPs and CIDR ranges, one per line. Blank
+// lines and comments starting with # or ; are skipped, so lists such as
+// Spamhaus DROP can be used as downloaded.
+func readBlocklist(path string) ([]netip.Prefix, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var networks []netip.Prefix
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, _, _ = strings.Cut(line, ";")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !strings.Contains(line, "/") {
+			addr, err := netip.ParseAddr(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid IP or CIDR range %q", path, n, line)
+			}
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid IP or CIDR range %q", path, n, line)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, scanner.Err()
+}
+
+// Listed returns the blocklists listing ip: "blocklist" for the local
+// blocklist file, followed by the DNSBL zones in configuration order.
+// Results are cached, so each IP is only queried once per run. DNSBL
+// queries that fail are logged and count as not listed.
+func (r *Reputation) Listed(ctx context.Context, ip string) []string {
+	if r == nil {
+		return nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	r.mu.Lock()
+	listed, ok := r.cache[addr]
+	r.mu.Unlock()
+	if ok {
+		return listed
+	}
+
+	if slices.ContainsFunc(r.networks, func(network netip.Prefix) bool { return network.Contains(addr) }) {
+		listed = append(listed, blocklistName)
+	}
+	for _, zone := range r.zones {
+		found, err := r.queryDNSBL(ctx, addr, zone)
+		if err != nil {
+			slog.Debug("Error querying DNSBL", "ip", ip, "zone", zone, "error", err)
+			if ctx.Err() != nil {
+				// Do not cache results cut short by cancellation
+				return listed
+			}
+			continue
+		}
+		if found {
+			listed = append(listed, zone)
+		}
+	}
+
+	r.mu.Lock()
+	r.cache[addr] = listed
+	r.mu.Unlock()
+	return listed
+}
+
+// queryDNSBL reports whether zone lists addr. Listed addresses resolve to
+// an address in 127.0.0.0/8, except for the error codes Spamhaus returns,
+// e.g. to queries sent through public resolvers.
+func (r *Reputation) queryDNSBL(ctx context.Context, addr netip.Addr, zone string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip4", dnsblName(addr, zone))
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	listed := false
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if dnsblErrors.Contains(ip) {
+			return false, fmt.Errorf("DNSBL returned error code %s", ip)
+		}
+		if dnsblListed.Contains(ip) {
+			listed = true
+		}
+	}
+	return listed, nil
+}
+
+// dnsblName returns the DNSBL query name of addr in zone: the reversed
+// octets of an IPv4 address, or the reversed nibbles of an IPv6 address,
+// followed by the zone, e.g. 4.3.2.1.zen.spamhaus.org for 1.2.3.4
+func dnsblName(addr netip.Addr, zone string) string {
+	var labels []string
+	if addr.Is4() {
+		for _, b := range addr.As4() {
+			labels = append(labels, fmt.Sprint(b))
+		}
+	} else {
+		for _, b := range addr.As16() {
+			labels = append(labels, fmt.Sprintf("%x", b>>4), fmt.Sprintf("%x", b&0xf))
+		}
+	}
+	slices.Reverse(labels)
+	return strings.Join(labels, ".") + "." + strings.TrimSuffix(zone, ".")
+}
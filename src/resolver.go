@@ -0,0 +1,285 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dohContentType is the media type of the DNS messages exchanged with
+// DNS-over-HTTPS servers
+const dohContentType = "application/dns-message"
+
+// resolverDefaultPorts are the ports of checker.resolver servers given
+// without one, by scheme
+var resolverDefaultPorts = map[string]string{"udp": "53", "tcp": "53", "tls": "853"}
+
+// newResolver returns the resolver of checker.resolver: the system resolver
+// if empty, else one querying a nameserver given as host[:port] or
+// udp://host[:port], over TCP with tcp://, over TLS with tls:// or over
+// HTTPS with the https:// URL of a DNS-over-HTTPS endpoint
+func newResolver(rawURL string, timeout time.Duration) (*net.Resolver, error) {
+	if rawURL == "" {
+		return net.DefaultResolver, nil
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "udp://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no server", rawURL)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), resolverDefaultPorts[u.Scheme])
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var dial func(ctx context.Context, network string) (net.Conn, error)
+	switch u.Scheme {
+	case "udp":
+		// Truncated answers are asked again over TCP
+		dial = func(ctx context.Context, network string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	case "tcp":
+		dial = func(ctx context.Context, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		}
+	case "tls":
+		tlsDialer := &tls.Dialer{NetDialer: dialer}
+		dial = func(ctx context.Context, _ string) (net.Conn, error) {
+			return tlsDialer.DialContext(ctx, "tcp", addr)
+		}
+	case "https":
+		client := &http.Client{Timeout: timeout}
+		dial = func(ctx context.Context, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, url: u.String()}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown scheme %q, expected udp, tcp, tls or https", u.Scheme)
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network)
+		},
+	}, nil
+}
+
+// dohConn is a connection to a DNS-over-HTTPS server as net.Resolver sees
+// it: a stream of DNS messages prefixed by their length, as over TCP. Each
+// query written is posted to the server, and its answer is read back.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	deadline time.Time
+	query    bytes.Buffer
+	answer   bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.query.Write(b)
+	query := c.query.Bytes()
+	if len(query) < 2 || len(query) < 2+int(binary.BigEndian.Uint16(query)) {
+		return len(b), nil
+	}
+	err := c.post(query[2 : 2+int(binary.BigEndian.Uint16(query))])
+	c.query.Reset()
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// post sends a query to the server and queues its answer
+func (c *dohConn) post(query []byte) error {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{status: resp.Status, code: resp.StatusCode}
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16-1))
+	if err != nil {
+		return err
+	}
+	c.answer.Write(binary.BigEndian.AppendUint16(nil, uint16(len(answer))))
+	c.answer.Write(answer)
+	return nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.answer.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.answer.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { c.deadline = t; return nil }
+
+// dohAddr is the address of a DNS-over-HTTPS server: its URL
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
+
+// dnsCache resolves hostnames with a resolver, caching their addresses for
+// a TTL. Concurrent lookups of a hostname share a single query, and failed
+// lookups are not cached. Expired entries are evicted at most once per TTL.
+type dnsCache struct {
+	resolver *net.Resolver
+	ttl      time.Duration
+	timeout  time.Duration
+	mu       sync.Mutex
+	entries  map[string]*dnsEntry
+	pruned   time.Time // When expired entries were last evicted
+}
+
+// dnsEntry is the lookup of a hostname, in flight or done
+type dnsEntry struct {
+	ready   chan struct{} // Closed once the lookup is done
+	done    bool
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// newDNSCache returns a cache of the addresses resolved by resolver, kept
+// for ttl, each lookup bounded by timeout
+func newDNSCache(resolver *net.Resolver, ttl, timeout time.Duration) *dnsCache {
+	return &dnsCache{resolver: resolver, ttl: ttl, timeout: timeout, entries: make(map[string]*dnsEntry)}
+}
+
+// LookupHost returns the addresses of host, from the cache if they have
+// not expired
+func (d *dnsCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	host = strings.ToLower(host)
+
+	d.mu.Lock()
+	now := time.Now()
+	e := d.entries[host]
+	if e == nil || e.expired(now) {
+		if now.Sub(d.pruned) >= d.ttl {
+			d.prune(now)
+		}
+		e = &dnsEntry{ready: make(chan struct{})}
+		d.entries[host] = e
+		// The lookup is not bound to ctx, as other checks may wait for it
+		go d.lookup(host, e)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// prune evicts the expired entries, d.mu held
+func (d *dnsCache) prune(now time.Time) {
+	for host, e := range d.entries {
+		if e.expired(now) {
+			delete(d.entries, host)
+		}
+	}
+	d.pruned = now
+}
+
+// expired reports whether the lookup is done and its addresses must be
+// resolved again, failed lookups always
+func (e *dnsEntry) expired(now time.Time) bool {
+	return e.done && now.After(e.expires)
+}
+
+// lookup resolves host and completes its entry
+func (d *dnsCache) lookup(host string, e *dnsEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	addrs, err := d.resolver.LookupHost(ctx, host)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e.addrs, e.err, e.done = addrs, err, true
+	if err == nil {
+		e.expires = time.Now().Add(d.ttl)
+	}
+	close(e.ready)
+}
+
+// dialer returns a dialer resolving hostnames with the cache, then opening
+// a connection with next to each of their addresses in turn until one
+// succeeds
+func (d *dnsCache) dialer(next contextDialer) contextDialer {
+	return &resolvingDialer{dialer: next, dns: d}
+}
+
+// resolvingDialer opens connections to the addresses of hostnames resolved
+// with its cache
+type resolvingDialer struct {
+	dialer contextDialer
+	dns    *dnsCache
+}
+
+func (d *resolvingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	addrs, err := d.dns.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, ip := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
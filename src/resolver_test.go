@@ -0,0 +1,252 @@
+package src
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dohServer is a DNS-over-HTTPS server answering A queries with 192.0.2.1
+// and AAAA queries with no address
+type dohServer struct {
+	*httptest.Server
+	queries atomic.Int32  // A queries answered
+	status  atomic.Int32  // Status of the answers, 200 if unset
+	block   chan struct{} // If set, answers wait for it to be closed
+}
+
+func newDoHServer(t *testing.T) *dohServer {
+	s := &dohServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohContentType || r.Header.Get("Accept") != dohContentType {
+			t.Errorf("request %s with Content-Type %q and Accept %q, want a POST of %s", r.Method, r.Header.Get("Content-Type"), r.Header.Get("Accept"), dohContentType)
+		}
+		if s.block != nil {
+			<-s.block
+		}
+		if status := s.status.Load(); status != 0 && status != http.StatusOK {
+			w.WriteHeader(int(status))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		answer, err := dnsAnswer(body)
+		if err != nil {
+			t.Errorf("dnsAnswer: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if answer.Questions[0].Type == dnsmessage.TypeA {
+			s.queries.Add(1)
+		}
+		packed, _ := answer.Pack()
+		w.Header().Set("Content-Type", dohContentType)
+		w.Write(packed)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// resolver returns a resolver querying the server with dohConn
+func (s *dohServer) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: s.Client(), url: s.URL}, nil
+		},
+	}
+}
+
+// dnsAnswer parses a DNS query and returns its answer
+func dnsAnswer(query []byte) (*dnsmessage.Message, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil, err
+	}
+	if len(msg.Questions) != 1 {
+		return nil, errors.New("expected one question")
+	}
+	msg.Header.Response = true
+	msg.Header.RecursionAvailable = true
+	q := msg.Questions[0]
+	if q.Type == dnsmessage.TypeA {
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: netip.MustParseAddr("192.0.2.1").As4()},
+		}}
+	}
+	return &msg, nil
+}
+
+func TestDoHConn(t *testing.T) {
+	s := newDoHServer(t)
+
+	addrs, err := s.resolver().LookupHost(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("LookupHost: %v", err)
+	}
+	if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("LookupHost = %q, want %q", addrs, want)
+	}
+
+	// A query split across writes is posted once complete
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 7, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("example.com."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	framed := append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)
+	conn := &dohConn{ctx: context.Background(), client: s.Client(), url: s.URL}
+	before := s.queries.Load()
+	for _, part := range [][]byte{framed[:1], framed[1:5], framed[5:]} {
+		if n, err := conn.Write(part); n != len(part) || err != nil {
+			t.Fatalf("Write = %d, %v, want %d, nil", n, err, len(part))
+		}
+	}
+	if got := s.queries.Load() - before; got != 1 {
+		t.Fatalf("%d queries posted, want 1", got)
+	}
+	answer, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(answer) < 2 || int(binary.BigEndian.Uint16(answer)) != len(answer)-2 {
+		t.Fatalf("answer of %d bytes is not prefixed by its length", len(answer))
+	}
+	var got dnsmessage.Message
+	if err := got.Unpack(answer[2:]); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if got.Header.ID != 7 || len(got.Answers) != 1 {
+		t.Errorf("answer has ID %d and %d records, want 7 and 1", got.Header.ID, len(got.Answers))
+	}
+
+	// Errors of the server fail the write
+	s.status.Store(http.StatusBadGateway)
+	var statusErr *statusError
+	if _, err := conn.Write(framed); !errors.As(err, &statusErr) || statusErr.code != http.StatusBadGateway {
+		t.Errorf("Write = %v, want a status error 502", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read after a failed query = %v, want EOF", err)
+	}
+}
+
+func TestDNSCacheSharesLookups(t *testing.T) {
+	s := newDoHServer(t)
+	s.block = make(chan struct{})
+	cache := newDNSCache(s.resolver(), time.Minute, 5*time.Second)
+
+	var wg sync.WaitGroup
+	var started atomic.Int32
+	results := make([][]string, 10)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Add(1)
+			results[i], errs[i] = cache.LookupHost(context.Background(), "Example.com")
+		}()
+	}
+	// Every caller waits for the blocked lookup before it is answered
+	for started.Load() < int32(len(results)) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(s.block)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil || !reflect.DeepEqual(results[i], []string{"192.0.2.1"}) {
+			t.Errorf("LookupHost = %q, %v, want [192.0.2.1]", results[i], errs[i])
+		}
+	}
+	if got := s.queries.Load(); got != 1 {
+		t.Errorf("%d queries for concurrent lookups, want 1", got)
+	}
+
+	// A caller giving up does not cancel the shared lookup
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.LookupHost(ctx, "other.example"); err != context.Canceled {
+		t.Errorf("LookupHost with a cancelled context = %v, want context.Canceled", err)
+	}
+	if addrs, err := cache.LookupHost(context.Background(), "other.example"); err != nil || len(addrs) != 1 {
+		t.Errorf("LookupHost = %q, %v, want one address", addrs, err)
+	}
+}
+
+func TestDNSCacheFailuresNotCached(t *testing.T) {
+	s := newDoHServer(t)
+	cache := newDNSCache(s.resolver(), time.Minute, 5*time.Second)
+
+	s.status.Store(http.StatusServiceUnavailable)
+	if _, err := cache.LookupHost(context.Background(), "example.com"); err == nil {
+		t.Fatal("LookupHost succeeded while the server fails")
+	}
+	s.status.Store(http.StatusOK)
+	addrs, err := cache.LookupHost(context.Background(), "example.com")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"192.0.2.1"}) {
+		t.Fatalf("LookupHost after a failure = %q, %v, want [192.0.2.1]", addrs, err)
+	}
+	if got := s.queries.Load(); got != 1 {
+		t.Errorf("%d queries answered, want 1", got)
+	}
+}
+
+func TestDNSCacheExpiry(t *testing.T) {
+	s := newDoHServer(t)
+	const ttl = 50 * time.Millisecond
+	cache := newDNSCache(s.resolver(), ttl, 5*time.Second)
+	lookup := func(host string) {
+		t.Helper()
+		if _, err := cache.LookupHost(context.Background(), host); err != nil {
+			t.Fatalf("LookupHost(%q): %v", host, err)
+		}
+	}
+
+	lookup("a.example")
+	lookup("a.example")
+	if got := s.queries.Load(); got != 1 {
+		t.Fatalf("%d queries before the TTL, want 1", got)
+	}
+	time.Sleep(ttl + 10*time.Millisecond)
+	lookup("a.example")
+	if got := s.queries.Load(); got != 2 {
+		t.Fatalf("%d queries after the TTL, want 2", got)
+	}
+
+	// Expired entries of other hostnames are evicted
+	time.Sleep(ttl + 10*time.Millisecond)
+	lookup("b.example")
+	cache.mu.Lock()
+	_, kept := cache.entries["a.example"]
+	n := len(cache.entries)
+	cache.mu.Unlock()
+	if kept || n != 1 {
+		t.Errorf("%d entries, a.example kept %v, want only b.example", n, kept)
+	}
+
+	// IP addresses are not looked up
+	if addrs, err := cache.LookupHost(context.Background(), "198.51.100.7"); err != nil || !reflect.DeepEqual(addrs, []string{"198.51.100.7"}) {
+		t.Errorf("LookupHost of an IP = %q, %v", addrs, err)
+	}
+	if got := s.queries.Load(); got != 3 {
+		t.Errorf("%d queries, want 3", got)
+	}
+}
@@ -0,0 +1,109 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Risk flags of proxies that may be honeypots or tamper with traffic
+const (
+	RiskPorts    = "ports"        // The host of the proxy listens on risk.min_open_ports of risk.ports
+	RiskMITM     = "mitm"         // The proxy intercepts TLS, requires checker.tls_check
+	RiskTampered = "tampered"     // The proxy modifies requests or responses, requires strict_check or checker.content_check
+	RiskHoneypot = "honeypot_asn" // The exit IP belongs to an AS of risk.honeypot_asns
+)
+
+// riskWeights are the points each risk flag adds to the risk score of a
+// proxy, capped at 100
+var riskWeights = map[string]int{
+	RiskPorts:    30,
+	RiskMITM:     40,
+	RiskTampered: 30,
+	RiskHoneypot: 50,
+}
+
+// loadASNList reads a file of AS numbers in the format of parseASNList, nil
+// if path is empty
+func loadASNList(path string) (map[uint]bool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseASNList(string(data)), nil
+}
+
+// flagRisk adds a risk flag to a proxy and updates its risk score, with
+// risk.enabled
+func (c *ProxyChecker) flagRisk(result *CheckResult, flag string) {
+	if !c.config.Risk.Enabled || slices.Contains(result.RiskFlags, flag) {
+		return
+	}
+	result.RiskFlags = append(result.RiskFlags, flag)
+	result.Risk = min(result.Risk+riskWeights[flag], 100)
+	slog.Debug("Proxy flagged as risky", "proxy", result.Proxy, "flag", flag, "risk", result.Risk)
+}
+
+// formatRisk returns the risk score of a proxy followed by its flags, e.g.
+// "70 (mitm,ports)"
+func formatRisk(result CheckResult) string {
+	if len(result.RiskFlags) == 0 {
+		return strconv.Itoa(result.Risk)
+	}
+	return fmt.Sprintf("%d (%s)", result.Risk, strings.Join(result.RiskFlags, ","))
+}
+
+// checkRisk is the risk step of risk.enabled, flagging proxies whose host
+// listens on many of risk.ports or whose exit IP belongs to an AS of
+// risk.honeypot_asns. The tls, content and anonymity steps flag
+// intercepted TLS and tampering themselves.
+func (c *ProxyChecker) checkRisk(ctx context.Context, client *http.Client, result *CheckResult) {
+	if !c.config.Risk.Enabled {
+		return
+	}
+	if result.Location != nil && c.honeypotASNs[result.Location.ASN] {
+		c.flagRisk(result, RiskHoneypot)
+	}
+	if minOpen := c.config.Risk.MinOpenPorts; minOpen > 0 && c.openRiskPorts(ctx, result.Proxy) >= minOpen {
+		c.flagRisk(result, RiskPorts)
+	}
+}
+
+// openRiskPorts connects to the risk.ports of the host of a proxy at once,
+// its own port aside, and returns how many accepted the connection
+func (c *ProxyChecker) openRiskPorts(ctx context.Context, proxyStr string) int {
+	addr, err := ParseProxyAddr(proxyStr)
+	if err != nil {
+		return 0
+	}
+	dialer := c.dialer(&net.Dialer{Timeout: c.config.Checker.ConnectTimeout})
+	var open atomic.Int32
+	var wg sync.WaitGroup
+	for _, port := range c.config.Risk.Ports {
+		if strconv.Itoa(port) == addr.Port {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr.Host, strconv.Itoa(port)))
+			if err == nil {
+				conn.Close()
+				open.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	return int(open.Load())
+}
@@ -0,0 +1,761 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.4
+// 	protoc        (unknown)
+// source: checker.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CheckProxyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Proxy in any format the scraper understands, e.g. user:pass@host:port
+	Proxy string `protobuf:"bytes,1,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	// http, https, socks4, socks5 or auto to detect it; defaults to the
+	// scheme of the proxy, or http
+	Protocol      string `protobuf:"bytes,2,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckProxyRequest) Reset() {
+	*x = CheckProxyRequest{}
+	mi := &file_checker_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckProxyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckProxyRequest) ProtoMessage() {}
+
+func (x *CheckProxyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_checker_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckProxyRequest.ProtoReflect.Descriptor instead.
+func (*CheckProxyRequest) Descriptor() ([]byte, []int) {
+	return file_checker_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CheckProxyRequest) GetProxy() string {
+	if x != nil {
+		return x.Proxy
+	}
+	return ""
+}
+
+func (x *CheckProxyRequest) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+type CheckBatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Proxies in any format the scraper understands
+	Proxies []string `protobuf:"bytes,1,rep,name=proxies,proto3" json:"proxies,omitempty"`
+	// Protocol of the proxies without a scheme, see CheckProxyRequest
+	Protocol      string `protobuf:"bytes,2,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckBatchRequest) Reset() {
+	*x = CheckBatchRequest{}
+	mi := &file_checker_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckBatchRequest) ProtoMessage() {}
+
+func (x *CheckBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_checker_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckBatchRequest.ProtoReflect.Descriptor instead.
+func (*CheckBatchRequest) Descriptor() ([]byte, []int) {
+	return file_checker_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CheckBatchRequest) GetProxies() []string {
+	if x != nil {
+		return x.Proxies
+	}
+	return nil
+}
+
+func (x *CheckBatchRequest) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+type GetPoolRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Filters, empty or 0 to match any proxy, as in the REST API
+	Type         string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Country      string `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	MaxLatencyMs int64  `protobuf:"varint,3,opt,name=max_latency_ms,json=maxLatencyMs,proto3" json:"max_latency_ms,omitempty"`
+	Anonymity    string `protobuf:"bytes,4,opt,name=anonymity,proto3" json:"anonymity,omitempty"`
+	Network      string `protobuf:"bytes,5,opt,name=network,proto3" json:"network,omitempty"`
+	Target       string `protobuf:"bytes,6,opt,name=target,proto3" json:"target,omitempty"`
+	// Maximum number of proxies returned, 0 for all
+	Limit         int32 `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPoolRequest) Reset() {
+	*x = GetPoolRequest{}
+	mi := &file_checker_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPoolRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPoolRequest) ProtoMessage() {}
+
+func (x *GetPoolRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_checker_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPoolRequest.ProtoReflect.Descriptor instead.
+func (*GetPoolRequest) Descriptor() ([]byte, []int) {
+	return file_checker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetPoolRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *GetPoolRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *GetPoolRequest) GetMaxLatencyMs() int64 {
+	if x != nil {
+		return x.MaxLatencyMs
+	}
+	return 0
+}
+
+func (x *GetPoolRequest) GetAnonymity() string {
+	if x != nil {
+		return x.Anonymity
+	}
+	return ""
+}
+
+func (x *GetPoolRequest) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *GetPoolRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *GetPoolRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetPoolResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Proxies       []*CheckResult         `protobuf:"bytes,1,rep,name=proxies,proto3" json:"proxies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPoolResponse) Reset() {
+	*x = GetPoolResponse{}
+	mi := &file_checker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPoolResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPoolResponse) ProtoMessage() {}
+
+func (x *GetPoolResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_checker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPoolResponse.ProtoReflect.Descriptor instead.
+func (*GetPoolResponse) Descriptor() ([]byte, []int) {
+	return file_checker_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetPoolResponse) GetProxies() []*CheckResult {
+	if x != nil {
+		return x.Proxies
+	}
+	return nil
+}
+
+// CheckResult is the result of the check of a proxy, with the fields of the
+// REST API records
+type CheckResult struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Proxy          string                 `protobuf:"bytes,1,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	Type           string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Working        bool                   `protobuf:"varint,3,opt,name=working,proto3" json:"working,omitempty"`
+	Ip             string                 `protobuf:"bytes,4,opt,name=ip,proto3" json:"ip,omitempty"`
+	Country        string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`
+	CountryCode    string                 `protobuf:"bytes,6,opt,name=country_code,json=countryCode,proto3" json:"country_code,omitempty"`
+	City           string                 `protobuf:"bytes,7,opt,name=city,proto3" json:"city,omitempty"`
+	Asn            uint32                 `protobuf:"varint,8,opt,name=asn,proto3" json:"asn,omitempty"`
+	AsOrg          string                 `protobuf:"bytes,9,opt,name=as_org,json=asOrg,proto3" json:"as_org,omitempty"`
+	Isp            string                 `protobuf:"bytes,10,opt,name=isp,proto3" json:"isp,omitempty"`
+	LatencyMs      int64                  `protobuf:"varint,11,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	ConnectTimeMs  int64                  `protobuf:"varint,12,opt,name=connect_time_ms,json=connectTimeMs,proto3" json:"connect_time_ms,omitempty"`
+	TtfbMs         int64                  `protobuf:"varint,13,opt,name=ttfb_ms,json=ttfbMs,proto3" json:"ttfb_ms,omitempty"`
+	TotalTimeMs    int64                  `protobuf:"varint,14,opt,name=total_time_ms,json=totalTimeMs,proto3" json:"total_time_ms,omitempty"`
+	Anonymous      bool                   `protobuf:"varint,15,opt,name=anonymous,proto3" json:"anonymous,omitempty"`
+	Anonymity      string                 `protobuf:"bytes,16,opt,name=anonymity,proto3" json:"anonymity,omitempty"`
+	Network        string                 `protobuf:"bytes,17,opt,name=network,proto3" json:"network,omitempty"`
+	Stability      float64                `protobuf:"fixed64,18,opt,name=stability,proto3" json:"stability,omitempty"`
+	Streak         int32                  `protobuf:"varint,19,opt,name=streak,proto3" json:"streak,omitempty"`
+	ThroughputKbps float64                `protobuf:"fixed64,20,opt,name=throughput_kbps,json=throughputKbps,proto3" json:"throughput_kbps,omitempty"`
+	Targets        []string               `protobuf:"bytes,21,rep,name=targets,proto3" json:"targets,omitempty"`
+	SharedExit     string                 `protobuf:"bytes,22,opt,name=shared_exit,json=sharedExit,proto3" json:"shared_exit,omitempty"`
+	Blocklists     []string               `protobuf:"bytes,23,rep,name=blocklists,proto3" json:"blocklists,omitempty"`
+	TorExit        bool                   `protobuf:"varint,24,opt,name=tor_exit,json=torExit,proto3" json:"tor_exit,omitempty"`
+	Tls            bool                   `protobuf:"varint,25,opt,name=tls,proto3" json:"tls,omitempty"`
+	Mitm           bool                   `protobuf:"varint,26,opt,name=mitm,proto3" json:"mitm,omitempty"`
+	Clean          bool                   `protobuf:"varint,27,opt,name=clean,proto3" json:"clean,omitempty"`
+	UdpSupport     bool                   `protobuf:"varint,28,opt,name=udp_support,json=udpSupport,proto3" json:"udp_support,omitempty"`
+	Dns            string                 `protobuf:"bytes,29,opt,name=dns,proto3" json:"dns,omitempty"`
+	KeepAlive      bool                   `protobuf:"varint,30,opt,name=keep_alive,json=keepAlive,proto3" json:"keep_alive,omitempty"`
+	Http2          bool                   `protobuf:"varint,31,opt,name=http2,proto3" json:"http2,omitempty"`
+	CheckedAt      *timestamppb.Timestamp `protobuf:"bytes,32,opt,name=checked_at,json=checkedAt,proto3" json:"checked_at,omitempty"`
+	ExpiresAt      *timestamppb.Timestamp `protobuf:"bytes,33,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// Position of the proxy in CheckBatchRequest.proxies, set in the results
+	// of CheckBatch
+	Index         int32 `protobuf:"varint,34,opt,name=index,proto3" json:"index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckResult) Reset() {
+	*x = CheckResult{}
+	mi := &file_checker_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckResult) ProtoMessage() {}
+
+func (x *CheckResult) ProtoReflect() protoreflect.Message {
+	mi := &file_checker_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckResult.ProtoReflect.Descriptor instead.
+func (*CheckResult) Descriptor() ([]byte, []int) {
+	return file_checker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CheckResult) GetProxy() string {
+	if x != nil {
+		return x.Proxy
+	}
+	return ""
+}
+
+func (x *CheckResult) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CheckResult) GetWorking() bool {
+	if x != nil {
+		return x.Working
+	}
+	return false
+}
+
+func (x *CheckResult) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *CheckResult) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *CheckResult) GetCountryCode() string {
+	if x != nil {
+		return x.CountryCode
+	}
+	return ""
+}
+
+func (x *CheckResult) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *CheckResult) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *CheckResult) GetAsOrg() string {
+	if x != nil {
+		return x.AsOrg
+	}
+	return ""
+}
+
+func (x *CheckResult) GetIsp() string {
+	if x != nil {
+		return x.Isp
+	}
+	return ""
+}
+
+func (x *CheckResult) GetLatencyMs() int64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *CheckResult) GetConnectTimeMs() int64 {
+	if x != nil {
+		return x.ConnectTimeMs
+	}
+	return 0
+}
+
+func (x *CheckResult) GetTtfbMs() int64 {
+	if x != nil {
+		return x.TtfbMs
+	}
+	return 0
+}
+
+func (x *CheckResult) GetTotalTimeMs() int64 {
+	if x != nil {
+		return x.TotalTimeMs
+	}
+	return 0
+}
+
+func (x *CheckResult) GetAnonymous() bool {
+	if x != nil {
+		return x.Anonymous
+	}
+	return false
+}
+
+func (x *CheckResult) GetAnonymity() string {
+	if x != nil {
+		return x.Anonymity
+	}
+	return ""
+}
+
+func (x *CheckResult) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *CheckResult) GetStability() float64 {
+	if x != nil {
+		return x.Stability
+	}
+	return 0
+}
+
+func (x *CheckResult) GetStreak() int32 {
+	if x != nil {
+		return x.Streak
+	}
+	return 0
+}
+
+func (x *CheckResult) GetThroughputKbps() float64 {
+	if x != nil {
+		return x.ThroughputKbps
+	}
+	return 0
+}
+
+func (x *CheckResult) GetTargets() []string {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+func (x *CheckResult) GetSharedExit() string {
+	if x != nil {
+		return x.SharedExit
+	}
+	return ""
+}
+
+func (x *CheckResult) GetBlocklists() []string {
+	if x != nil {
+		return x.Blocklists
+	}
+	return nil
+}
+
+func (x *CheckResult) GetTorExit() bool {
+	if x != nil {
+		return x.TorExit
+	}
+	return false
+}
+
+func (x *CheckResult) GetTls() bool {
+	if x != nil {
+		return x.Tls
+	}
+	return false
+}
+
+func (x *CheckResult) GetMitm() bool {
+	if x != nil {
+		return x.Mitm
+	}
+	return false
+}
+
+func (x *CheckResult) GetClean() bool {
+	if x != nil {
+		return x.Clean
+	}
+	return false
+}
+
+func (x *CheckResult) GetUdpSupport() bool {
+	if x != nil {
+		return x.UdpSupport
+	}
+	return false
+}
+
+func (x *CheckResult) GetDns() string {
+	if x != nil {
+		return x.Dns
+	}
+	return ""
+}
+
+func (x *CheckResult) GetKeepAlive() bool {
+	if x != nil {
+		return x.KeepAlive
+	}
+	return false
+}
+
+func (x *CheckResult) GetHttp2() bool {
+	if x != nil {
+		return x.Http2
+	}
+	return false
+}
+
+func (x *CheckResult) GetCheckedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CheckedAt
+	}
+	return nil
+}
+
+func (x *CheckResult) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *CheckResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+var File_checker_proto protoreflect.FileDescriptor
+
+var file_checker_proto_rawDesc = string([]byte{
+	0x0a, 0x0d, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x13, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x63, 0x68, 0x65,
+	0x63, 0x6b, 0x65, 0x72, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x45, 0x0a, 0x11, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x50, 0x72,
+	0x6f, 0x78, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72,
+	0x6f, 0x78, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x78, 0x79,
+	0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x22, 0x49, 0x0a, 0x11,
+	0x43, 0x68, 0x65, 0x63, 0x6b, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x78, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x78, 0x69, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x22, 0xca, 0x01, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x50,
+	0x6f, 0x6f, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x24, 0x0a, 0x0e, 0x6d, 0x61, 0x78, 0x5f,
+	0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0c, 0x6d, 0x61, 0x78, 0x4c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x4d, 0x73, 0x12, 0x1c,
+	0x0a, 0x09, 0x61, 0x6e, 0x6f, 0x6e, 0x79, 0x6d, 0x69, 0x74, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x61, 0x6e, 0x6f, 0x6e, 0x79, 0x6d, 0x69, 0x74, 0x79, 0x12, 0x18, 0x0a, 0x07,
+	0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e,
+	0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c,
+	0x69, 0x6d, 0x69, 0x74, 0x22, 0x4d, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x50, 0x6f, 0x6f, 0x6c, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3a, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x78, 0x69,
+	0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79,
+	0x73, 0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x2e, 0x43,
+	0x68, 0x65, 0x63, 0x6b, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x78,
+	0x69, 0x65, 0x73, 0x22, 0xcc, 0x07, 0x0a, 0x0b, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x70, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x72, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x5f, 0x63, 0x6f, 0x64,
+	0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79,
+	0x43, 0x6f, 0x64, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x69, 0x74, 0x79, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x63, 0x69, 0x74, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x61, 0x73, 0x6e, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x61, 0x73, 0x6e, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x73,
+	0x5f, 0x6f, 0x72, 0x67, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x61, 0x73, 0x4f, 0x72,
+	0x67, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x73, 0x70, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x69, 0x73, 0x70, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x6d,
+	0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79,
+	0x4d, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x5f, 0x74, 0x69,
+	0x6d, 0x65, 0x5f, 0x6d, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x63, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x74,
+	0x66, 0x62, 0x5f, 0x6d, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x74, 0x66,
+	0x62, 0x4d, 0x73, 0x12, 0x22, 0x0a, 0x0d, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x6d, 0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x6e, 0x6f, 0x6e, 0x79,
+	0x6d, 0x6f, 0x75, 0x73, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x61, 0x6e, 0x6f, 0x6e,
+	0x79, 0x6d, 0x6f, 0x75, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x6e, 0x6f, 0x6e, 0x79, 0x6d, 0x69,
+	0x74, 0x79, 0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x61, 0x6e, 0x6f, 0x6e, 0x79, 0x6d,
+	0x69, 0x74, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x11,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x12, 0x1c, 0x0a,
+	0x09, 0x73, 0x74, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x12, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x09, 0x73, 0x74, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6b, 0x18, 0x13, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6b, 0x12, 0x27, 0x0a, 0x0f, 0x74, 0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x70, 0x75,
+	0x74, 0x5f, 0x6b, 0x62, 0x70, 0x73, 0x18, 0x14, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x74, 0x68,
+	0x72, 0x6f, 0x75, 0x67, 0x68, 0x70, 0x75, 0x74, 0x4b, 0x62, 0x70, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x73, 0x18, 0x15, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x74,
+	0x61, 0x72, 0x67, 0x65, 0x74, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x68, 0x61, 0x72, 0x65, 0x64,
+	0x5f, 0x65, 0x78, 0x69, 0x74, 0x18, 0x16, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x73, 0x68, 0x61,
+	0x72, 0x65, 0x64, 0x45, 0x78, 0x69, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x62, 0x6c, 0x6f, 0x63, 0x6b,
+	0x6c, 0x69, 0x73, 0x74, 0x73, 0x18, 0x17, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x62, 0x6c, 0x6f,
+	0x63, 0x6b, 0x6c, 0x69, 0x73, 0x74, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x74, 0x6f, 0x72, 0x5f, 0x65,
+	0x78, 0x69, 0x74, 0x18, 0x18, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x74, 0x6f, 0x72, 0x45, 0x78,
+	0x69, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x6c, 0x73, 0x18, 0x19, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x03, 0x74, 0x6c, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x69, 0x74, 0x6d, 0x18, 0x1a, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x04, 0x6d, 0x69, 0x74, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6c, 0x65, 0x61,
+	0x6e, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x63, 0x6c, 0x65, 0x61, 0x6e, 0x12, 0x1f,
+	0x0a, 0x0b, 0x75, 0x64, 0x70, 0x5f, 0x73, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x1c, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0a, 0x75, 0x64, 0x70, 0x53, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x12,
+	0x10, 0x0a, 0x03, 0x64, 0x6e, 0x73, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x64, 0x6e,
+	0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x6b, 0x65, 0x65, 0x70, 0x5f, 0x61, 0x6c, 0x69, 0x76, 0x65, 0x18,
+	0x1e, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x6b, 0x65, 0x65, 0x70, 0x41, 0x6c, 0x69, 0x76, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x68, 0x74, 0x74, 0x70, 0x32, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x05, 0x68, 0x74, 0x74, 0x70, 0x32, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65,
+	0x64, 0x5f, 0x61, 0x74, 0x18, 0x20, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x64, 0x41,
+	0x74, 0x12, 0x39, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x5f, 0x61, 0x74, 0x18,
+	0x21, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x09, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x12, 0x14, 0x0a, 0x05,
+	0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x22, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x32, 0x91, 0x02, 0x0a, 0x07, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x12, 0x56,
+	0x0a, 0x0a, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x12, 0x26, 0x2e, 0x70,
+	0x72, 0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x63, 0x68, 0x65, 0x63, 0x6b,
+	0x65, 0x72, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61,
+	0x70, 0x65, 0x72, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x58, 0x0a, 0x0a, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x42,
+	0x61, 0x74, 0x63, 0x68, 0x12, 0x26, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61,
+	0x70, 0x65, 0x72, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x70,
+	0x72, 0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x63, 0x68, 0x65, 0x63, 0x6b,
+	0x65, 0x72, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x30, 0x01,
+	0x12, 0x54, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x50, 0x6f, 0x6f, 0x6c, 0x12, 0x23, 0x2e, 0x70, 0x72,
+	0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65,
+	0x72, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x6f, 0x6f, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x24, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x73, 0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x63,
+	0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x6f, 0x6f, 0x6c, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x1d, 0x5a, 0x1b, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x53,
+	0x63, 0x72, 0x61, 0x70, 0x65, 0x72, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x72, 0x2f, 0x73, 0x72,
+	0x63, 0x2f, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+})
+
+var (
+	file_checker_proto_rawDescOnce sync.Once
+	file_checker_proto_rawDescData []byte
+)
+
+func file_checker_proto_rawDescGZIP() []byte {
+	file_checker_proto_rawDescOnce.Do(func() {
+		file_checker_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_checker_proto_rawDesc), len(file_checker_proto_rawDesc)))
+	})
+	return file_checker_proto_rawDescData
+}
+
+var file_checker_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_checker_proto_goTypes = []any{
+	(*CheckProxyRequest)(nil),     // 0: proxyscraperchecker.CheckProxyRequest
+	(*CheckBatchRequest)(nil),     // 1: proxyscraperchecker.CheckBatchRequest
+	(*GetPoolRequest)(nil),        // 2: proxyscraperchecker.GetPoolRequest
+	(*GetPoolResponse)(nil),       // 3: proxyscraperchecker.GetPoolResponse
+	(*CheckResult)(nil),           // 4: proxyscraperchecker.CheckResult
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_checker_proto_depIdxs = []int32{
+	4, // 0: proxyscraperchecker.GetPoolResponse.proxies:type_name -> proxyscraperchecker.CheckResult
+	5, // 1: proxyscraperchecker.CheckResult.checked_at:type_name -> google.protobuf.Timestamp
+	5, // 2: proxyscraperchecker.CheckResult.expires_at:type_name -> google.protobuf.Timestamp
+	0, // 3: proxyscraperchecker.Checker.CheckProxy:input_type -> proxyscraperchecker.CheckProxyRequest
+	1, // 4: proxyscraperchecker.Checker.CheckBatch:input_type -> proxyscraperchecker.CheckBatchRequest
+	2, // 5: proxyscraperchecker.Checker.GetPool:input_type -> proxyscraperchecker.GetPoolRequest
+	4, // 6: proxyscraperchecker.Checker.CheckProxy:output_type -> proxyscraperchecker.CheckResult
+	4, // 7: proxyscraperchecker.Checker.CheckBatch:output_type -> proxyscraperchecker.CheckResult
+	3, // 8: proxyscraperchecker.Checker.GetPool:output_type -> proxyscraperchecker.GetPoolResponse
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_checker_proto_init() }
+func file_checker_proto_init() {
+	if File_checker_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_checker_proto_rawDesc), len(file_checker_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_checker_proto_goTypes,
+		DependencyIndexes: file_checker_proto_depIdxs,
+		MessageInfos:      file_checker_proto_msgTypes,
+	}.Build()
+	File_checker_proto = out.File
+	file_checker_proto_goTypes = nil
+	file_checker_proto_depIdxs = nil
+}
@@ -0,0 +1,215 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: checker.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Checker_CheckProxy_FullMethodName = "/proxyscraperchecker.Checker/CheckProxy"
+	Checker_CheckBatch_FullMethodName = "/proxyscraperchecker.Checker/CheckBatch"
+	Checker_GetPool_FullMethodName    = "/proxyscraperchecker.Checker/GetPool"
+)
+
+// CheckerClient is the client API for Checker service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Checker validates proxies submitted by other services and serves the pool
+// of working proxies
+type CheckerClient interface {
+	// CheckProxy checks a single proxy
+	CheckProxy(ctx context.Context, in *CheckProxyRequest, opts ...grpc.CallOption) (*CheckResult, error)
+	// CheckBatch checks a list of proxies concurrently and streams their
+	// results as the checks end
+	CheckBatch(ctx context.Context, in *CheckBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CheckResult], error)
+	// GetPool lists the working proxies of the pool, fastest first
+	GetPool(ctx context.Context, in *GetPoolRequest, opts ...grpc.CallOption) (*GetPoolResponse, error)
+}
+
+type checkerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCheckerClient(cc grpc.ClientConnInterface) CheckerClient {
+	return &checkerClient{cc}
+}
+
+func (c *checkerClient) CheckProxy(ctx context.Context, in *CheckProxyRequest, opts ...grpc.CallOption) (*CheckResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckResult)
+	err := c.cc.Invoke(ctx, Checker_CheckProxy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *checkerClient) CheckBatch(ctx context.Context, in *CheckBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CheckResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Checker_ServiceDesc.Streams[0], Checker_CheckBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[CheckBatchRequest, CheckResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Checker_CheckBatchClient = grpc.ServerStreamingClient[CheckResult]
+
+func (c *checkerClient) GetPool(ctx context.Context, in *GetPoolRequest, opts ...grpc.CallOption) (*GetPoolResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPoolResponse)
+	err := c.cc.Invoke(ctx, Checker_GetPool_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckerServer is the server API for Checker service.
+// All implementations must embed UnimplementedCheckerServer
+// for forward compatibility.
+//
+// Checker validates proxies submitted by other services and serves the pool
+// of working proxies
+type CheckerServer interface {
+	// CheckProxy checks a single proxy
+	CheckProxy(context.Context, *CheckProxyRequest) (*CheckResult, error)
+	// CheckBatch checks a list of proxies concurrently and streams their
+	// results as the checks end
+	CheckBatch(*CheckBatchRequest, grpc.ServerStreamingServer[CheckResult]) error
+	// GetPool lists the working proxies of the pool, fastest first
+	GetPool(context.Context, *GetPoolRequest) (*GetPoolResponse, error)
+	mustEmbedUnimplementedCheckerServer()
+}
+
+// UnimplementedCheckerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCheckerServer struct{}
+
+func (UnimplementedCheckerServer) CheckProxy(context.Context, *CheckProxyRequest) (*CheckResult, error) {
+	return nil, status.Error(codes.Unimplemented, "method CheckProxy not implemented")
+}
+func (UnimplementedCheckerServer) CheckBatch(*CheckBatchRequest, grpc.ServerStreamingServer[CheckResult]) error {
+	return status.Error(codes.Unimplemented, "method CheckBatch not implemented")
+}
+func (UnimplementedCheckerServer) GetPool(context.Context, *GetPoolRequest) (*GetPoolResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetPool not implemented")
+}
+func (UnimplementedCheckerServer) mustEmbedUnimplementedCheckerServer() {}
+func (UnimplementedCheckerServer) testEmbeddedByValue()                 {}
+
+// UnsafeCheckerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CheckerServer will
+// result in compilation errors.
+type UnsafeCheckerServer interface {
+	mustEmbedUnimplementedCheckerServer()
+}
+
+func RegisterCheckerServer(s grpc.ServiceRegistrar, srv CheckerServer) {
+	// If the following call panics, it indicates UnimplementedCheckerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Checker_ServiceDesc, srv)
+}
+
+func _Checker_CheckProxy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckProxyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CheckerServer).CheckProxy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Checker_CheckProxy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CheckerServer).CheckProxy(ctx, req.(*CheckProxyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Checker_CheckBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CheckBatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CheckerServer).CheckBatch(m, &grpc.GenericServerStream[CheckBatchRequest, CheckResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Checker_CheckBatchServer = grpc.ServerStreamingServer[CheckResult]
+
+func _Checker_GetPool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CheckerServer).GetPool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Checker_GetPool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CheckerServer).GetPool(ctx, req.(*GetPoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Checker_ServiceDesc is the grpc.ServiceDesc for Checker service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Checker_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proxyscraperchecker.Checker",
+	HandlerType: (*CheckerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CheckProxy",
+			Handler:    _Checker_CheckProxy_Handler,
+		},
+		{
+			MethodName: "GetPool",
+			Handler:    _Checker_GetPool_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CheckBatch",
+			Handler:       _Checker_CheckBatch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "checker.proto",
+}
@@ -0,0 +1,110 @@
+package src
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// anonymityScores are the anonymity components of the score per level,
+// unknown levels left out
+var anonymityScores = map[AnonymityLevel]float64{
+	AnonymityTransparent: 0,
+	AnonymityAnonymous:   0.5,
+	AnonymityElite:       1,
+}
+
+// score returns the composite score of a working proxy, from 0 to 100 times
+// the weight of its country: the weighted average of its latency relative
+// to checker.max_latency, or else checker.timeout, its anonymity level and
+// its stability across runs. Components that were not measured are left
+// out of the average.
+func (c *ProxyChecker) score(result CheckResult) float64 {
+	weights := c.config.Score
+	var sum, total float64
+	add := func(weight, value float64) {
+		sum += weight * value
+		total += weight
+	}
+
+	limit := c.config.Checker.MaxLatency
+	if limit <= 0 {
+		limit = c.config.Checker.Timeout
+	}
+	if limit > 0 {
+		add(weights.Latency, max(0, 1-float64(result.Speed)/float64(limit)))
+	}
+	if value, ok := anonymityScores[result.Anonymity]; ok {
+		add(weights.Anonymity, value)
+	}
+	if c.Store != nil {
+		add(weights.Stability, result.Stability/100)
+	}
+	if total == 0 {
+		return 0
+	}
+
+	score := 100 * sum / total
+	if result.Location != nil {
+		if factor, ok := weights.Countries[strings.ToUpper(result.Location.CountryCode)]; ok {
+			score *= factor
+		}
+	}
+	return score
+}
+
+// topWriter records the working proxies of a run to write <output
+// dir>/top.txt with the best-scored ones once checking ends. All methods are
+// safe to call on a nil *topWriter, which writes nothing.
+type topWriter struct {
+	size    int
+	mu      sync.Mutex
+	results []CheckResult
+}
+
+// newTopWriter returns the writer configured by output.top_proxies, nil if
+// it is 0
+func newTopWriter(config *Config) *topWriter {
+	if config.Output.TopProxies <= 0 {
+		return nil
+	}
+	return &topWriter{size: config.Output.TopProxies}
+}
+
+// add records a result saved to the output files
+func (w *topWriter) add(result CheckResult) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.results = append(w.results, result)
+}
+
+// write writes top.txt in dir with the output.top_proxies best-scored
+// proxies still in the output files, one URI per line, the fastest first
+// among equal scores
+func (w *topWriter) write(dir string) error {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	results := slices.Clone(w.results)
+	w.mu.Unlock()
+	results, err := keptResults(dir, results)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(results, func(a, b CheckResult) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Speed, b.Speed))
+	})
+
+	var b strings.Builder
+	for _, result := range results[:min(w.size, len(results))] {
+		b.WriteString(ProxyURI(result.Type, result.Proxy) + "\n")
+	}
+	return writeFileAtomic(filepath.Join(dir, "top.txt"), []byte(b.String()))
+}
@@ -1,12 +1,16 @@
 package src
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	neturl "net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,8 +22,8 @@ type ProxyData struct {
 		IP   string `json:"ip"`
 		Port string `json:"port,omitempty"`
 		// Additional fields that might contain port information
-		ProxyPort string `json:"proxy_port,omitempty"`
-		PortNum   string `json:"port_num,omitempty"`
+		ProxyPort  string `json:"proxy_port,omitempty"`
+		PortNum    string `json:"port_num,omitempty"`
 		PortNumber string `json:"port_number,omitempty"`
 	} `json:"data"`
 }
@@ -32,7 +36,24 @@ func truncateURL(url string, maxLength int) string {
 	return url[:maxLength-3] + "..."
 }
 
-// normalizeProxy converts various proxy formats to IP:PORT format
+// Patterns of proxies with credentials
+var (
+	authPrefixRe = regexp.MustCompile(`([^\s:@/]+):([^\s@]+)@(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}):(\d+)`)
+	authSuffixRe = regexp.MustCompile(`^(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}):(\d+):([^\s:@]+):(\S+)$`)
+)
+
+// ipv6ProxyRe matches bracketed IPv6 proxies such as [2001:db8::1]:8080,
+// optionally with user:pass@ credentials
+var ipv6ProxyRe = regexp.MustCompile(`(?:([^\s:@/\[]+):([^\s@\[]+)@)?\[([0-9a-fA-F:.]+)\]:(\d+)`)
+
+// hostnameProxyRe matches a line consisting only of a DNS hostname proxy
+// (host:port or user:pass@host:port). Hostnames are only accepted as whole
+// lines so that arbitrary text such as "Updated by example.com" is not
+// mistaken for a proxy.
+var hostnameProxyRe = regexp.MustCompile(`^(?:([^\s:@/]+):([^\s@]+)@)?((?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,63}):(\d+)$`)
+
+// normalizeProxy converts various proxy formats to HOST:PORT format, or to
+// USER:PASS@HOST:PORT for proxies with credentials
 func normalizeProxy(proxy string) string {
 	// Remove protocol prefix if exists
 	proxy = strings.TrimPrefix(proxy, "http://")
@@ -40,6 +61,36 @@ func normalizeProxy(proxy string) string {
 	proxy = strings.TrimPrefix(proxy, "socks4://")
 	proxy = strings.TrimPrefix(proxy, "socks5://")
 
+	// Try an IPv6 proxy
+	if matches := ipv6ProxyRe.FindStringSubmatch(proxy); matches != nil {
+		ip := net.ParseIP(matches[3])
+		if ip == nil || ip.To4() != nil {
+			return ""
+		}
+		hostPort := net.JoinHostPort(ip.String(), matches[4])
+		if matches[1] != "" {
+			return fmt.Sprintf("%s:%s@%s", matches[1], matches[2], hostPort)
+		}
+		return hostPort
+	}
+
+	// Try a hostname-based proxy
+	if matches := hostnameProxyRe.FindStringSubmatch(proxy); matches != nil {
+		host := strings.ToLower(matches[3])
+		if matches[1] != "" {
+			return fmt.Sprintf("%s:%s@%s:%s", matches[1], matches[2], host, matches[4])
+		}
+		return fmt.Sprintf("%s:%s", host, matches[4])
+	}
+
+	// Try user:pass@ip:port and ip:port:user:pass
+	if matches := authPrefixRe.FindStringSubmatch(proxy); matches != nil {
+		return fmt.Sprintf("%s:%s@%s:%s", matches[1], matches[2], matches[3], matches[4])
+	}
+	if matches := authSuffixRe.FindStringSubmatch(proxy); matches != nil {
+		return fmt.Sprintf("%s:%s@%s:%s", matches[3], matches[4], matches[1], matches[2])
+	}
+
 	// Try to parse as JSON
 	if strings.HasPrefix(proxy, "{") {
 		var data ProxyData
@@ -72,10 +123,10 @@ func normalizeProxy(proxy string) string {
 	// Try to find IP and PORT separately
 	ipRe := regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`)
 	portRe := regexp.MustCompile(`\d{1,5}`)
-	
+
 	ip := ipRe.FindString(proxy)
 	port := portRe.FindString(proxy)
-	
+
 	if ip != "" && port != "" {
 		return fmt.Sprintf("%s:%s", ip, port)
 	}
@@ -83,7 +134,14 @@ func normalizeProxy(proxy string) string {
 	return ""
 }
 
-// isValidProxy checks if a proxy string is valid and returns normalized format
+// ipv4HostRe matches a dotted IPv4 address, octets with leading zeros
+// included
+var ipv4HostRe = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+
+// isValidProxy checks if a proxy string is valid and returns normalized
+// format. Leading zeros are removed from IPv4 octets and ports, so that
+// 001.002.003.004:08080 and 1.2.3.4:8080 are the same proxy, and octets
+// above 255 and ports outside 1-65535 are rejected.
 func isValidProxy(proxy string) (string, bool) {
 	if proxy == "" {
 		return "", false
@@ -96,44 +154,360 @@ func isValidProxy(proxy string) (string, bool) {
 	}
 
 	// Validate the normalized format
-	parts := strings.Split(normalized, ":")
-	if len(parts) != 2 {
+	addr, err := ParseProxyAddr(normalized)
+	if err != nil {
 		return "", false
 	}
 
+	if ipv4HostRe.MatchString(addr.Host) {
+		octets := strings.Split(addr.Host, ".")
+		for i, octet := range octets {
+			n, err := strconv.Atoi(octet)
+			if err != nil || n > 255 {
+				return "", false
+			}
+			octets[i] = strconv.Itoa(n)
+		}
+		addr.Host = strings.Join(octets, ".")
+	}
+
 	// Check if port is numeric and in valid range
-	port := parts[1]
-	if len(port) == 0 || len(port) > 5 {
+	port, err := strconv.ParseUint(addr.Port, 10, 16)
+	if err != nil || port == 0 {
+		return "", false
+	}
+	addr.Port = strconv.FormatUint(port, 10)
+
+	return addr.String(), true
+}
+
+// proxyFromIPPort returns the proxy with the IP or hostname and the port
+// found in separate fields of a structured list
+func proxyFromIPPort(host, port string) (string, bool) {
+	host, port = strings.TrimSpace(host), strings.TrimSpace(port)
+	if host == "" || port == "" {
 		return "", false
 	}
-	for _, c := range port {
-		if c < '0' || c > '9' {
-			return "", false
+	return isValidProxy(net.JoinHostPort(host, port))
+}
+
+// ScrapeSource fetches a single source with the ProxySource of its type and
+// returns the valid proxies it found, normalized to IP:PORT format and
+// without duplicates. url, telegram and github sources are fetched with
+// client and userAgent. If the source fails, the proxies found so far are
+// returned along with the error.
+func ScrapeSource(ctx context.Context, client *http.Client, source Source, userAgent string) ([]string, error) {
+	factory, ok := sourceFactory(source.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown source type %q", source.Type)
+	}
+	source.client, source.userAgent = client, userAgent
+	fetcher, err := factory(source)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := fetcher.Fetch(ctx)
+	var proxies []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if proxy, ok := isValidProxy(candidate.Proxy); ok && !seen[proxy] {
+			seen[proxy] = true
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies, err
+}
+
+// scrapeHTTPSource fetches a url, telegram or github source and returns the
+// valid proxies found in its response. The pages of a paginated source are
+// fetched in order, respecting the source rate limit, until the end of the
+// range or the first page that yields no new proxy. If a page fails, the
+// proxies found on the previous pages are returned along with the error.
+func scrapeHTTPSource(ctx context.Context, client *http.Client, source Source, userAgent string) ([]string, error) {
+	switch source.Type {
+	case SourceTelegram:
+		return scrapeTelegram(ctx, client, source, userAgent)
+	case SourceGitHub:
+		return scrapeGitHub(ctx, client, source, userAgent)
+	}
+
+	var interval time.Duration
+	if source.RateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / source.RateLimit)
+	}
+
+	var proxies []string
+	seen := make(map[string]bool)
+	for i, pageURL := range source.PageURLs() {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return proxies, ctx.Err()
+			}
+		}
+
+		found, err := scrapePage(ctx, client, source, pageURL, userAgent)
+		if err != nil {
+			return proxies, err
+		}
+
+		added := 0
+		for _, proxy := range found {
+			if !seen[proxy] {
+				seen[proxy] = true
+				proxies = append(proxies, proxy)
+				added++
+			}
+		}
+		if source.Pages != nil && added == 0 {
+			slog.Debug("Stopping pagination, page yielded no new proxies", "url", pageURL)
+			break
+		}
+	}
+	return proxies, nil
+}
+
+// scrapePage fetches a single page of a source and parses its proxies
+func scrapePage(ctx context.Context, client *http.Client, source Source, url string, userAgent string) ([]string, error) {
+	body, header, err := fetchSource(ctx, client, source, url, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return parseSourceBody(body, header.Get("Content-Type"), source)
+}
+
+// parseSourceBody extracts the proxies of a source response with the regex
+// of the source, or else its parser
+func parseSourceBody(body []byte, contentType string, source Source) ([]string, error) {
+	if source.Regex != "" {
+		re, err := compileSourceRegex(source.Regex)
+		if err != nil {
+			return nil, err
+		}
+		return extractRegexProxies(body, re), nil
+	}
+	return parseProxies(body, contentType, source.Parser, source.Selector)
+}
+
+// compileSourceRegex compiles the regex of a source, which must have named
+// groups ip and port
+func compileSourceRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.SubexpIndex("ip") < 0 || re.SubexpIndex("port") < 0 {
+		return nil, errors.New("named groups (?P<ip>...) and (?P<port>...) are required")
+	}
+	return re, nil
+}
+
+// extractRegexProxies returns the proxies matched by the regex of a source:
+// the ip and port groups of each match, with the credentials of the user
+// and pass groups if the regex has them and they matched
+func extractRegexProxies(body []byte, re *regexp.Regexp) []string {
+	ip, port := re.SubexpIndex("ip"), re.SubexpIndex("port")
+	user, pass := re.SubexpIndex("user"), re.SubexpIndex("pass")
+
+	var proxies []string
+	for _, match := range re.FindAllSubmatch(body, -1) {
+		proxy, ok := proxyFromIPPort(string(match[ip]), string(match[port]))
+		if !ok {
+			continue
+		}
+		if user >= 0 && pass >= 0 && len(match[user]) > 0 && len(match[pass]) > 0 {
+			if proxy, ok = isValidProxy(string(match[user]) + ":" + string(match[pass]) + "@" + proxy); !ok {
+				continue
+			}
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+// detectParser picks the parser of a response for ParserAuto, from its
+// Content-Type and its shape
+func detectParser(contentType string, body []byte) string {
+	switch {
+	case isHTML(contentType, body):
+		return ParserHTML
+	case isJSON(contentType, body):
+		return ParserJSON
+	case isCSV(contentType, body):
+		return ParserCSV
+	}
+	return ParserText
+}
+
+// parseProxies extracts the valid proxies of a source response with the
+// given parser, normalized to IP:PORT format. The selector only applies to
+// the HTML parser.
+func parseProxies(body []byte, contentType, parser, selector string) ([]string, error) {
+	if parser == "" || parser == ParserAuto {
+		parser = detectParser(contentType, body)
+	}
+	switch parser {
+	case ParserHTML:
+		return extractHTMLProxies(body, selector)
+	case ParserJSON:
+		return extractJSONProxies(body)
+	case ParserCSV:
+		return extractCSVProxies(body)
+	case ParserText:
+	default:
+		return nil, fmt.Errorf("unknown parser %q", parser)
+	}
+
+	// Split response by newlines and filter valid proxies
+	var proxies []string
+	lines := strings.Split(string(body), "\n")
+	for _, line := range lines {
+		proxy := strings.TrimSpace(line)
+		if normalized, ok := isValidProxy(proxy); ok {
+			proxies = append(proxies, normalized)
 		}
 	}
 
-	return normalized, true
+	return proxies, nil
 }
 
-// ScrapeProxies scrapes proxies from a list of URLs
-func ScrapeProxies(urls []string, userAgents []string, timeout time.Duration, proxyType string, concurrent int) []string {
+// maxRetryAfter caps the delay a source can ask for with Retry-After before
+// it is requested again
+const maxRetryAfter = time.Minute
+
+// statusError is a source response with a status other than 2xx
+type statusError struct {
+	status     string
+	code       int
+	retryAfter time.Duration // From the Retry-After header, if any
+}
+
+func (e *statusError) Error() string {
+	return "unexpected status: " + e.status
+}
+
+// retryable reports whether a failed source request may succeed if sent
+// again: after a network error or timeout, or a 429 or 5xx status
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+	var urlErr *neturl.Error
+	return errors.As(err, &urlErr)
+}
+
+// fetchSource sends a GET request for a page of source, applying its timeout
+// and headers, and returns the response body, decompressed and converted to
+// UTF-8, and headers. Requests that may succeed if sent again are retried
+// up to scraper.retries times, waiting scraper.retry_delay before the first
+// retry and doubling the delay before each following one, or longer if the
+// source asks for it with Retry-After.
+func fetchSource(ctx context.Context, client *http.Client, source Source, url, userAgent string) ([]byte, http.Header, error) {
+	delay := source.retryDelay
+	for attempt := 0; ; attempt++ {
+		body, header, err := fetchSourceOnce(ctx, client, source, url, userAgent)
+		if err == nil || attempt >= source.retries || !retryable(err) || ctx.Err() != nil {
+			return body, header, err
+		}
+
+		wait := delay
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.retryAfter > wait {
+			wait = min(statusErr.retryAfter, maxRetryAfter)
+		}
+		slog.Debug("Retrying source", "url", url, "attempt", attempt+1, "delay", wait, "error", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, err
+		}
+		delay *= 2
+	}
+}
+
+// fetchSourceOnce sends a single request for a page of source, once the
+// limit of requests to its host allows it
+func fetchSourceOnce(ctx context.Context, client *http.Client, source Source, url, userAgent string) ([]byte, http.Header, error) {
+	if err := source.hosts.Wait(ctx, url); err != nil {
+		return nil, nil, err
+	}
+	if source.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, source.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating request: %w", err)
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	// Negotiated here to accept deflate too, and to decompress responses
+	// even when a source header overrides it
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	for name, value := range source.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, nil, &statusError{status: resp.Status, code: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	body, err := readSourceBody(resp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return body, resp.Header, nil
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given in
+// seconds or as a date, or 0 if there is none
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// ScrapeProxies scrapes proxies from a list of sources with client, see
+// NewScrapeClient, and the timeout, retries, user agents and concurrency of
+// config. Requests are spaced out per host by hosts, if not nil. When ctx is
+// cancelled, pending sources are skipped and the proxies found so far are
+// returned. The outcome of each source is recorded in health, if not nil.
+// Proxies are deduplicated with scraper.dedup as sources complete, so that
+// only unique ones are held, and source responses are released once parsed.
+func ScrapeProxies(ctx context.Context, client *http.Client, sources []Source, config ScraperConfig, proxyType string, hosts *HostLimiter, health *SourceHealth) []string {
 	var proxies []string
+	seen := NewProxySet(config.Dedup, config.DedupCapacity)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, concurrent)
-	var completedURLs int
-	var totalFound int
+	semaphore := make(chan struct{}, config.Concurrent)
+	status := ScrapeStatus{Protocol: proxyType, Total: len(sources)}
 
-	client := &http.Client{
-		Timeout: timeout,
-	}
+	display.ScrapeProgress(status, false)
 
-	// Print initial message
-	fmt.Printf("Starting %s proxy scraping...\n", proxyType)
-	
 	// Start progress display goroutine
 	done := make(chan struct{})
+	displayed := make(chan struct{})
 	go func() {
+		defer close(displayed)
 		ticker := time.NewTicker(100 * time.Millisecond)
 		defer ticker.Stop()
 
@@ -143,70 +517,60 @@ func ScrapeProxies(urls []string, userAgents []string, timeout time.Duration, pr
 				return
 			case <-ticker.C:
 				mu.Lock()
-				if completedURLs == len(urls) {
-					mu.Unlock()
-					return
-				}
-				fmt.Print("\n\033[1A\033[K") // Move cursor up and clear line
-				fmt.Printf("\r✓ Scraped %d %s proxies [%d/%d]", 
-					totalFound, proxyType, completedURLs, len(urls))
+				current := status
 				mu.Unlock()
+				display.ScrapeProgress(current, false)
 			}
 		}
 	}()
-	
-	for i, url := range urls {
+
+	for i, source := range sources {
 		wg.Add(1)
-		go func(i int, url string) {
+		go func(i int, source Source) {
 			defer wg.Done()
-			semaphore <- struct{}{} // Acquire semaphore
-			defer func() { <-semaphore }() // Release semaphore
-
-			req, err := http.NewRequest("GET", url, nil)
-			if err != nil {
-				log.Printf("Error creating request for %s: %v", url, err)
+			select {
+			case semaphore <- struct{}{}: // Acquire semaphore
+			case <-ctx.Done():
 				return
 			}
+			defer func() { <-semaphore }() // Release semaphore
 
 			// Rotate user agents
-			userAgent := userAgents[i%len(userAgents)]
-			req.Header.Set("User-Agent", userAgent)
-
-			resp, err := client.Do(req)
-			if err != nil {
-				log.Printf("Error fetching %s: %v", url, err)
-				return
+			userAgent := config.UserAgents[i%len(config.UserAgents)]
+			if source.Timeout == 0 {
+				source.Timeout = config.Timeout
 			}
-
-			body, err := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				log.Printf("Error reading response from %s: %v", url, err)
-				return
+			source.retries, source.retryDelay = config.Retries, config.RetryDelay
+			source.hosts = hosts
+			if config.AllowCIDR {
+				source.cidrLimit = config.CIDRMaxCandidates
 			}
-
-			// Split response by newlines and filter valid proxies
-			var localProxies []string
-			lines := strings.Split(string(body), "\n")
-			for _, line := range lines {
-				proxy := strings.TrimSpace(line)
-				if normalized, ok := isValidProxy(proxy); ok {
-					localProxies = append(localProxies, normalized)
-				}
+			localProxies, err := ScrapeSource(ctx, client, source, userAgent)
+			failed := err != nil && ctx.Err() == nil
+			if failed {
+				slog.Warn("Error scraping source", "url", source.URL, "error", err)
 			}
+			health.RecordScrape(source, localProxies, err)
 
 			// Update proxies slice thread-safely
 			mu.Lock()
-			proxies = append(proxies, localProxies...)
-			completedURLs++
-			totalFound = len(proxies)
+			for _, proxy := range localProxies {
+				if seen.Add(proxy) {
+					proxies = append(proxies, proxy)
+				}
+			}
+			status.Completed++
+			status.Found = len(proxies)
+			if failed {
+				status.Failed++
+			}
 			mu.Unlock()
-		}(i, url)
+		}(i, source)
 	}
 
 	wg.Wait()
 	close(done)
-	fmt.Print("\n\033[1A\033[K")
-	fmt.Printf("✓ Scraped %d %s proxies [%d/%d]\n", len(proxies), proxyType, completedURLs, len(urls))
+	<-displayed
+	display.ScrapeProgress(status, true)
 	return proxies
-} 
\ No newline at end of file
+}
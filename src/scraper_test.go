@@ -0,0 +1,373 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseProxies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		parser      string
+		selector    string
+		body        string
+		want        []string
+	}{
+		{
+			name:   "text list",
+			parser: ParserAuto,
+			body:   "1.2.3.4:8080\nnot a proxy\n  5.6.7.8:3128  \n",
+			want:   []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:   "text list with credentials",
+			parser: ParserText,
+			body:   "user:pass@1.2.3.4:8080\n5.6.7.8:3128:user:pass\n",
+			want:   []string{"user:pass@1.2.3.4:8080", "user:pass@5.6.7.8:3128"},
+		},
+		{
+			name:        "JSON array of objects",
+			contentType: "application/json",
+			parser:      ParserAuto,
+			body:        `[{"ip": "1.2.3.4", "port": 8080}, {"ip_address": "5.6.7.8", "port": "3128", "country": "DE"}]`,
+			want:        []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:   "JSON data wrapper",
+			parser: ParserAuto,
+			body:   `{"data": [{"ip": "1.2.3.4", "proxy_port": "8080"}, {"ip": "5.6.7.8", "port_number": 3128}], "total": 2}`,
+			want:   []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:   "JSON objects keyed by IP",
+			parser: ParserAuto,
+			body:   `{"5.6.7.8": {"port": 3128, "anonymity": "elite"}, "1.2.3.4": 8080}`,
+			want:   []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:   "JSON IP:PORT strings and fields",
+			parser: ParserJSON,
+			body:   `{"proxies": ["1.2.3.4:8080"], "items": [{"proxy": "5.6.7.8:3128"}, {"ip": "9.9.9.9:80"}]}`,
+			want:   []string{"5.6.7.8:3128", "9.9.9.9:80", "1.2.3.4:8080"},
+		},
+		{
+			name:   "JSON with credentials",
+			parser: ParserJSON,
+			body:   `[{"host": "1.2.3.4", "port": 8080, "username": "user", "password": "pass"}]`,
+			want:   []string{"user:pass@1.2.3.4:8080"},
+		},
+		{
+			name:   "JSON invalid entries",
+			parser: ParserJSON,
+			body:   `[{"ip": "1.2.3.400", "port": 8080}, {"ip": "1.2.3.4", "port": 70000}, {"ip": "1.2.3.4"}]`,
+			want:   nil,
+		},
+		{
+			name:        "HTML table with IP and port cells",
+			contentType: "text/html; charset=utf-8",
+			parser:      ParserAuto,
+			body: `<html><body><table>
+				<tr><th>IP</th><th>Port</th><th>Country</th></tr>
+				<tr><td>1.2.3.4</td><td>8080</td><td>DE</td></tr>
+				<tr><td> 5.6.7.8 </td><td> 3128 </td><td>US</td></tr>
+				</table></body></html>`,
+			want: []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:     "HTML rows matched by selector",
+			parser:   ParserHTML,
+			selector: "tr.proxy",
+			body: `<table>
+				<tr class="ad"><td>9.9.9.9</td><td>80</td></tr>
+				<tr class="proxy"><td>1.2.3.4:8080</td></tr>
+				</table>`,
+			want: []string{"1.2.3.4:8080"},
+		},
+		{
+			name:   "CSV with header",
+			parser: ParserAuto,
+			body:   "country,ip,port\nDE,1.2.3.4,8080\r\nUS,5.6.7.8,3128\n",
+			want:   []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:        "CSV without header",
+			contentType: "text/csv",
+			parser:      ParserAuto,
+			body:        "1.2.3.4,8080,DE\n5.6.7.8:3128,,US\n",
+			want:        []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:   "CSV separated by semicolons",
+			parser: ParserAuto,
+			body:   "IP Address;Port;Login;Password\n1.2.3.4;8080;user;pass\n5.6.7.8;3128;;\n",
+			want:   []string{"user:pass@1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:   "CSV with short rows",
+			parser: ParserCSV,
+			body:   "ip,port,country\n1.2.3.4,8080,DE\n5.6.7.8\n",
+			want:   []string{"1.2.3.4:8080"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProxies([]byte(tt.body), tt.contentType, tt.parser, tt.selector)
+			if err != nil {
+				t.Fatalf("parseProxies: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseProxies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectParser(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"plain text", "text/plain", "1.2.3.4:8080\n5.6.7.8:3128\n", ParserText},
+		{"HTML by content type", "text/html", "<table></table>", ParserHTML},
+		{"HTML by shape", "", "<!DOCTYPE html><html></html>", ParserHTML},
+		{"JSON by content type", "application/json", `[]`, ParserJSON},
+		{"JSON by shape", "text/plain", `{"data": []}`, ParserJSON},
+		{"JSON lines", "text/plain", "{\"ip\": \"1.2.3.4\"}\n{\"ip\": \"5.6.7.8\"}\n", ParserText},
+		{"CSV by content type", "text/csv", "1.2.3.4:8080\n", ParserCSV},
+		{"CSV by shape", "", "ip,port\n1.2.3.4,8080\n", ParserCSV},
+		{"ragged rows", "", "# proxies, updated hourly\n1.2.3.4:8080\n", ParserText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectParser(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Errorf("detectParser = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidProxy(t *testing.T) {
+	tests := []struct {
+		proxy string
+		want  string
+		ok    bool
+	}{
+		{"1.2.3.4:8080", "1.2.3.4:8080", true},
+		{"http://1.2.3.4:8080", "1.2.3.4:8080", true},
+		{"001.002.003.004:08080", "1.2.3.4:8080", true},
+		{"user:pass@010.0.0.1:0080", "user:pass@10.0.0.1:80", true},
+		{"[2001:db8::1]:8080", "[2001:db8::1]:8080", true},
+		{"[fd00::1]:8080", "[fd00::1]:8080", true},
+		{"proxy.example.com:3128", "proxy.example.com:3128", true},
+		{"256.1.1.1:80", "", false},
+		{"1.2.3.4:65536", "", false},
+		{"1.2.3.4:0", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.proxy, func(t *testing.T) {
+			got, ok := isValidProxy(tt.proxy)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("isValidProxy(%q) = %q, %v, want %q, %v", tt.proxy, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExtractRegexProxies(t *testing.T) {
+	tests := []struct {
+		name  string
+		regex string
+		body  string
+		want  []string
+	}{
+		{
+			name:  "IP and port groups",
+			regex: `host=(?P<ip>[\d.]+)&port=(?P<port>\d+)`,
+			body:  "<a href=\"?host=1.2.3.4&port=8080\">\n<a href=\"?host=5.6.7.8&port=03128\">",
+			want:  []string{"1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:  "credentials groups",
+			regex: `(?P<ip>[\d.]+) (?P<port>\d+)(?: (?P<user>\w+)/(?P<pass>\w+))?`,
+			body:  "1.2.3.4 8080 user/pass\n5.6.7.8 3128\n",
+			want:  []string{"user:pass@1.2.3.4:8080", "5.6.7.8:3128"},
+		},
+		{
+			name:  "invalid matches",
+			regex: `(?P<ip>\S+) (?P<port>\d+)`,
+			body:  "1.2.3.400 8080\n1.2.3.4 99999\n",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := compileSourceRegex(tt.regex)
+			if err != nil {
+				t.Fatalf("compileSourceRegex: %v", err)
+			}
+			if got := extractRegexProxies([]byte(tt.body), re); !slices.Equal(got, tt.want) {
+				t.Errorf("extractRegexProxies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := compileSourceRegex(`(\d+\.\d+\.\d+\.\d+):(\d+)`); err == nil {
+		t.Error("compileSourceRegex accepted a regex without named groups")
+	}
+}
+
+// staticSource is a registered source type listing the proxies of its
+// options
+type staticSource struct {
+	proxies []string
+}
+
+func (s staticSource) Fetch(ctx context.Context) ([]ProxyCandidate, error) {
+	return candidates(s.proxies), nil
+}
+
+func TestScrapeSourceTypes(t *testing.T) {
+	if _, ok := sourceFactory("static"); !ok {
+		RegisterSource("static", func(source Source) (ProxySource, error) {
+			return staticSource{proxies: []string{source.Options["proxy"], "1.2.3.4:8080"}}, nil
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(path, []byte("\ufeff1.2.3.4:8080\nuser:pass@5.6.7.8:3128\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		source Source
+		want   []string
+	}{
+		{
+			name:   "file",
+			source: Source{Type: SourceFile, Path: path},
+			want:   []string{"1.2.3.4:8080", "user:pass@5.6.7.8:3128"},
+		},
+		{
+			name:   "command",
+			source: Source{Type: SourceCommand, Command: []string{"sh", "-c", "echo 1.2.3.4:8080; echo socks5://5.6.7.8:1080"}},
+			want:   []string{"1.2.3.4:8080", "5.6.7.8:1080"},
+		},
+		{
+			name:   "command with regex",
+			source: Source{Type: SourceCommand, Command: []string{"echo", "host=1.2.3.4 port=8080"}, Regex: `host=(?P<ip>\S+) port=(?P<port>\d+)`},
+			want:   []string{"1.2.3.4:8080"},
+		},
+		{
+			name:   "registered type",
+			source: Source{Type: "Static", Options: map[string]string{"proxy": "http://5.6.7.8:3128"}},
+			want:   []string{"5.6.7.8:3128", "1.2.3.4:8080"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			if err := source.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			got, err := ScrapeSource(context.Background(), nil, source, "")
+			if err != nil {
+				t.Fatalf("ScrapeSource: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ScrapeSource = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	failing := Source{Type: SourceCommand, Command: []string{"sh", "-c", "echo 1.2.3.4:8080; echo crawler failed >&2; exit 3"}}
+	if err := failing.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	got, err := ScrapeSource(context.Background(), nil, failing, "")
+	if err == nil || err.Error() != "exit status 3: crawler failed" || !slices.Equal(got, []string{"1.2.3.4:8080"}) {
+		t.Errorf("ScrapeSource of a failing command = %q, %v", got, err)
+	}
+
+	unknown := Source{Type: "unknown"}
+	if err := unknown.validate(); err == nil {
+		t.Error("validate accepted an unregistered type")
+	}
+}
+
+func TestDedupeProxies(t *testing.T) {
+	for _, mode := range []string{DedupExact, DedupFingerprint, DedupBloom} {
+		t.Run(mode, func(t *testing.T) {
+			set := NewProxySet(mode, 100)
+			got := DedupeProxies([]string{"1.2.3.4:8080", "5.6.7.8:3128", "1.2.3.4:8080", "user:pass@1.2.3.4:8080"}, set)
+			want := []string{"1.2.3.4:8080", "5.6.7.8:3128", "user:pass@1.2.3.4:8080"}
+			if !slices.Equal(got, want) {
+				t.Errorf("DedupeProxies = %q, want %q", got, want)
+			}
+			// The set is shared across lists
+			got = DedupeProxies([]string{"5.6.7.8:3128", "9.9.9.9:80"}, set)
+			if !slices.Equal(got, []string{"9.9.9.9:80"}) {
+				t.Errorf("DedupeProxies of a second list = %q, want %q", got, []string{"9.9.9.9:80"})
+			}
+		})
+	}
+}
+
+func TestProxySetBloomSizing(t *testing.T) {
+	// m = -n ln(p) / ln(2)^2 bits and k = m/n ln(2) hashes: 1.8 bytes and 10
+	// hashes per proxy for p = 0.1%
+	set := NewProxySet(DedupBloom, 1_000_000)
+	if bits := len(set.bloom) * 64; bits < 14_377_000 || bits > 14_377_700 {
+		t.Errorf("Bloom filter of %d bits, want about 14377588", bits)
+	}
+	if set.hashK != 10 {
+		t.Errorf("Bloom filter with %d hashes, want 10", set.hashK)
+	}
+	if small := NewProxySet(DedupBloom, 0); len(small.bloom) == 0 || small.hashK < 1 {
+		t.Errorf("Bloom filter of capacity 0 has %d words and %d hashes", len(small.bloom), small.hashK)
+	}
+}
+
+func TestProxySetFalseDuplicates(t *testing.T) {
+	const capacity = 20_000
+	proxy := func(i int) string { return fmt.Sprintf("10.%d.%d.%d:8080", i>>16&255, i>>8&255, i&255) }
+	for _, tt := range []struct {
+		mode string
+		max  int // False duplicates allowed among capacity unique proxies
+	}{
+		{DedupExact, 0},
+		{DedupFingerprint, 0},
+		{DedupBloom, capacity * 3 / 1000}, // Three times the error rate at capacity
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			set := NewProxySet(tt.mode, capacity)
+			falseDups := 0
+			for i := range capacity {
+				if !set.Add(proxy(i)) {
+					falseDups++
+				}
+			}
+			if falseDups > tt.max {
+				t.Errorf("%d false duplicates among %d unique proxies, want at most %d", falseDups, capacity, tt.max)
+			}
+			for i := range capacity {
+				if set.Add(proxy(i)) {
+					t.Fatalf("Add(%q) missed a duplicate", proxy(i))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package src
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// DefaultSearchEngines are the search engines checked when
+// checker.search_engines is not set
+var DefaultSearchEngines = []SearchEngineConfig{
+	{
+		Name: "google",
+		URL:  "https://www.google.com/search?q=proxy+list",
+		BanPatterns: []string{
+			`google\.[a-z.]+/sorry/`,
+			`(?i)our systems have detected unusual traffic`,
+			`id="captcha-form"`,
+		},
+	},
+	{
+		Name: "bing",
+		URL:  "https://www.bing.com/search?q=proxy+list",
+		BanPatterns: []string{
+			`/turing/captcha/`,
+			`(?i)one last step`,
+		},
+	},
+}
+
+// searchEngine is a search engine of checker.search_engines with its ban
+// patterns compiled
+type searchEngine struct {
+	name     string
+	url      string
+	patterns []*regexp.Regexp
+}
+
+// compileSearchEngines compiles the ban patterns of checker.search_engines
+func compileSearchEngines(engines []SearchEngineConfig) ([]searchEngine, error) {
+	compiled := make([]searchEngine, 0, len(engines))
+	for _, engine := range engines {
+		e := searchEngine{name: engine.Name, url: engine.URL}
+		for _, pattern := range engine.BanPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			e.patterns = append(e.patterns, re)
+		}
+		compiled = append(compiled, e)
+	}
+	return compiled, nil
+}
+
+// checkSearch is the search step: each search engine is queried through a
+// working proxy, and it passes if the results come back rather than a
+// captcha or ban page. Like targets, search engines only tag the result.
+func (c *ProxyChecker) checkSearch(ctx context.Context, client *http.Client, result *CheckResult) {
+	if !c.config.Checker.SearchCheck {
+		return
+	}
+	for _, engine := range c.searchEngines {
+		if ctx.Err() != nil {
+			return
+		}
+		if reason := c.searchBan(ctx, client, engine); reason != "" {
+			slog.Debug("Proxy banned by search engine", "proxy", result.Proxy, "engine", engine.name, "reason", reason)
+			continue
+		}
+		result.SearchPassed = append(result.SearchPassed, engine.name)
+	}
+}
+
+// searchBan queries a search engine and returns why the response is a ban,
+// empty if the results came back: an error, a status other than 2xx, or a
+// ban pattern matching the final URL, after redirects, or the body
+func (c *ProxyChecker) searchBan(ctx context.Context, client *http.Client, engine searchEngine) string {
+	req, err := http.NewRequestWithContext(ctx, "GET", engine.url, nil)
+	if err != nil {
+		return err.Error()
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := c.do(client, req)
+	if err != nil {
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return err.Error()
+	}
+
+	finalURL := resp.Request.URL.String()
+	for _, re := range engine.patterns {
+		if re.MatchString(finalURL) || re.Match(body) {
+			return "matched " + re.String()
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Status
+	}
+	return ""
+}
@@ -0,0 +1,351 @@
+package src
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoProxies is returned when the pool has no usable proxy left
+var ErrNoProxies = errors.New("no working proxies available")
+
+// Limits of the time clients of the HTTP listeners of the rotating server
+// and the API take to send request headers, and keep idle connections open
+const (
+	serverHeaderTimeout = 10 * time.Second
+	serverIdleTimeout   = 2 * time.Minute
+)
+
+// RotatingServer is a local HTTP/SOCKS5 proxy that forwards every connection
+// through a randomly chosen working proxy from the pool
+type RotatingServer struct {
+	config *Config
+	pool   *Pool
+}
+
+// NewRotatingServer creates a rotating proxy server backed by pool
+func NewRotatingServer(config *Config, pool *Pool) *RotatingServer {
+	return &RotatingServer{config: config, pool: pool}
+}
+
+// ListenAndServe starts the configured listeners and blocks until ctx is done
+// or a listener fails, which closes the other one
+func (s *RotatingServer) ListenAndServe(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, 2)
+
+	if addr := s.config.Server.HTTPListen; addr != "" {
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           s,
+			ReadHeaderTimeout: serverHeaderTimeout,
+			IdleTimeout:       serverIdleTimeout,
+		}
+		go func() { errc <- srv.ListenAndServe() }()
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+	}
+
+	if addr := s.config.Server.SOCKS5Listen; addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			ln.Close()
+		}()
+		go func() { errc <- s.serveSOCKS5(ctx, ln) }()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errc:
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+}
+
+// ServeHTTP handles CONNECT tunnels and plain HTTP proxy requests
+func (s *RotatingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		s.handleConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy server", http.StatusBadRequest)
+		return
+	}
+	s.handleForward(w, r)
+}
+
+// handleConnect tunnels a CONNECT request through an upstream proxy
+func (s *RotatingServer) handleConnect(w http.ResponseWriter, r *http.Request) {
+	upstream, err := s.dialUpstream(r.Context(), r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	if n := buf.Reader.Buffered(); n > 0 {
+		data, _ := buf.Reader.Peek(n)
+		upstream.Write(data)
+	}
+	pipe(client, upstream)
+}
+
+// handleForward forwards a plain HTTP request through an upstream proxy,
+// retrying with another proxy when the request has no body
+func (s *RotatingServer) handleForward(w http.ResponseWriter, r *http.Request) {
+	attempts := s.config.Server.Retries
+	if r.Body != nil && r.Body != http.NoBody {
+		attempts = 1
+	}
+
+	tried := make(map[string]bool)
+	var lastErr error = ErrNoProxies
+	for i := 0; i < attempts; i++ {
+		upstream, ok := s.pool.Random(tried)
+		if !ok {
+			break
+		}
+		tried[upstream.Proxy] = true
+
+		resp, err := s.roundTrip(r, upstream)
+		if err != nil {
+			lastErr = err
+			s.reportFailure(upstream, err)
+			continue
+		}
+		s.pool.ReportSuccess(upstream.Proxy)
+		defer resp.Body.Close()
+
+		for key, values := range resp.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	http.Error(w, lastErr.Error(), http.StatusBadGateway)
+}
+
+// roundTrip sends a single request through the given upstream proxy
+func (s *RotatingServer) roundTrip(r *http.Request, upstream CheckResult) (*http.Response, error) {
+	transport := &http.Transport{
+		TLSHandshakeTimeout:   s.config.Checker.ConnectTimeout,
+		ResponseHeaderTimeout: s.config.Checker.Timeout,
+		DisableKeepAlives:     true,
+	}
+	switch upstream.Type {
+	case ProxyTypeHTTP:
+		addr, err := ParseProxyAddr(upstream.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(addr.URL("http"))
+	default:
+		// HTTPS and SOCKS proxies only tunnel connections
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return s.dialThrough(ctx, upstream, addr)
+		}
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	req.Header.Del("Proxy-Connection")
+	req.Header.Del("Proxy-Authorization")
+	return transport.RoundTrip(req)
+}
+
+// dialUpstream opens a tunnel to addr through a random pooled proxy, trying
+// other proxies when one fails
+func (s *RotatingServer) dialUpstream(ctx context.Context, addr string) (net.Conn, error) {
+	tried := make(map[string]bool)
+	var lastErr error = ErrNoProxies
+	for i := 0; i < s.config.Server.Retries; i++ {
+		upstream, ok := s.pool.Random(tried)
+		if !ok {
+			break
+		}
+		tried[upstream.Proxy] = true
+
+		conn, err := s.dialThrough(ctx, upstream, addr)
+		if err != nil {
+			lastErr = err
+			s.reportFailure(upstream, err)
+			continue
+		}
+		s.pool.ReportSuccess(upstream.Proxy)
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+// reportFailure records an upstream failure and logs evictions
+func (s *RotatingServer) reportFailure(upstream CheckResult, err error) {
+	if s.pool.ReportFailure(upstream.Proxy, s.config.Server.MaxFailures) {
+		slog.Info("Evicted proxy from pool", "type", upstream.Type, "proxy", upstream.Proxy, "error", err)
+	}
+}
+
+// dialThrough opens a TCP tunnel to addr through a single upstream proxy
+func (s *RotatingServer) dialThrough(ctx context.Context, upstream CheckResult, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.Checker.ConnectTimeout}
+	proxyAddr, err := ParseProxyAddr(upstream.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return dialProxy(ctx, dialer, upstream.Type, proxyAddr, addr, s.config.Checker.Timeout)
+}
+
+// serveSOCKS5 accepts SOCKS5 clients until the listener is closed
+func (s *RotatingServer) serveSOCKS5(ctx context.Context, ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleSOCKS5(ctx, conn)
+	}
+}
+
+// handleSOCKS5 serves a single SOCKS5 client (no authentication, CONNECT only)
+func (s *RotatingServer) handleSOCKS5(ctx context.Context, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(s.config.Checker.Timeout))
+
+	addr, err := readSOCKS5Request(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	upstream, err := s.dialUpstream(ctx, addr)
+	if err != nil {
+		// General SOCKS server failure
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	pipe(conn, upstream)
+}
+
+// readSOCKS5Request performs the SOCKS5 greeting and returns the requested
+// destination address
+func readSOCKS5Request(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != 5 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[1] != 1 {
+		// Command not supported
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return "", fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 4:
+		ip := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		// Address type not supported
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return "", fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// pipe copies data between two connections until either side closes
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyConn := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		dst.Close()
+	}
+	go copyConn(a, b)
+	go copyConn(b, a)
+	wg.Wait()
+}
@@ -0,0 +1,69 @@
+package src
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// contextDialer opens connections like net.Dialer
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// socketLimiter caps the number of sockets the checks keep open at once.
+// All methods are safe to call on a nil *socketLimiter, which does not
+// limit.
+type socketLimiter struct {
+	slots chan struct{}
+}
+
+// newSocketLimiter returns a limiter allowing max sockets open at once, or
+// nil if max is 0
+func newSocketLimiter(max int) *socketLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &socketLimiter{slots: make(chan struct{}, max)}
+}
+
+// dialer returns a dialer opening connections with d once a socket is free
+func (l *socketLimiter) dialer(d contextDialer) contextDialer {
+	if l == nil {
+		return d
+	}
+	return &limitedDialer{dialer: d, limiter: l}
+}
+
+// limitedDialer opens connections once its limiter has a free socket
+type limitedDialer struct {
+	dialer  contextDialer
+	limiter *socketLimiter
+}
+
+// DialContext waits for a free socket, or for ctx to be done, then opens a
+// connection. The socket is freed when the connection is closed.
+func (d *limitedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	select {
+	case d.limiter.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		<-d.limiter.slots
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, release: sync.OnceFunc(func() { <-d.limiter.slots })}, nil
+}
+
+// limitedConn frees its socket on the first call to Close
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	defer c.release()
+	return c.Conn.Close()
+}
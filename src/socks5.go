@@ -0,0 +1,353 @@
+package src
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// SOCKS5 protocol values, from RFC 1928 and RFC 1929
+const (
+	socks5Version      = 5
+	socks5CmdConnect   = 1
+	socks5CmdUDP       = 3
+	socks5AuthNone     = 0
+	socks5AuthPassword = 2
+	socks5AtypIPv4     = 1
+	socks5AtypDomain   = 3
+	socks5AtypIPv6     = 4
+)
+
+// udpProbeName is the name looked up by the UDP probe
+const udpProbeName = "example.com."
+
+// probeUDP reports whether a SOCKS5 proxy relays UDP: a UDP ASSOCIATE
+// request is sent to it, then a DNS query for udpProbeName to
+// checker.udp_dns_server through the relay it opens, which must be
+// answered within checker.timeout.
+func (c *ProxyChecker) probeUDP(ctx context.Context, proxyAddr ProxyAddr) bool {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Checker.Timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	dialer := c.dialer(&net.Dialer{Timeout: c.config.Checker.ConnectTimeout})
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr.HostPort())
+	if err != nil {
+		return false
+	}
+	// The relay is closed by the proxy with the control connection
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	if err := socks5Auth(conn, proxyAddr); err != nil {
+		slog.Debug("SOCKS5 authentication failed", "proxy", proxyAddr.HostPort(), "error", err)
+		return false
+	}
+	relay, err := socks5Command(conn, socks5CmdUDP, "0.0.0.0:0")
+	if err != nil {
+		slog.Debug("UDP ASSOCIATE refused", "proxy", proxyAddr.HostPort(), "error", err)
+		return false
+	}
+	// Proxies answering with an unspecified address relay on their own
+	// address, and those behind NAT often answer with a private one
+	relayHost, relayPort, err := net.SplitHostPort(relay)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(relayHost); ip != nil && (ip.IsUnspecified() || ip.IsPrivate() || ip.IsLoopback()) {
+		relayHost = proxyAddr.Host
+	}
+
+	udp, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(relayHost, relayPort))
+	if err != nil {
+		return false
+	}
+	defer udp.Close()
+	udp.SetDeadline(deadline)
+
+	id := uint16(rand.UintN(1 << 16))
+	query, err := dnsQuery(id, udpProbeName)
+	if err != nil {
+		return false
+	}
+	// RSV, FRAG, then the destination and the payload
+	packet, err := appendSOCKS5Addr([]byte{0, 0, 0}, c.config.Checker.UDPDNSServer)
+	if err != nil {
+		return false
+	}
+	if _, err := udp.Write(append(packet, query...)); err != nil {
+		return false
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := udp.Read(buf)
+		if err != nil {
+			slog.Debug("No answer through UDP relay", "proxy", proxyAddr.HostPort(), "error", err)
+			return false
+		}
+		if isDNSAnswer(buf[:n], id) {
+			return true
+		}
+	}
+}
+
+// isDNSAnswer reports whether a datagram received from a SOCKS5 UDP relay
+// carries the response to the DNS query with the given ID
+func isDNSAnswer(datagram []byte, id uint16) bool {
+	if len(datagram) < 4 || datagram[2] != 0 {
+		return false
+	}
+	headerLen := 4
+	switch datagram[3] {
+	case socks5AtypIPv4:
+		headerLen += net.IPv4len + 2
+	case socks5AtypIPv6:
+		headerLen += net.IPv6len + 2
+	case socks5AtypDomain:
+		if len(datagram) < 5 {
+			return false
+		}
+		headerLen += 1 + int(datagram[4]) + 2
+	default:
+		return false
+	}
+	if len(datagram) < headerLen {
+		return false
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(datagram[headerLen:])
+	return err == nil && header.Response && header.ID == id
+}
+
+// dnsQuery builds a DNS query for the A records of name
+func dnsQuery(id uint16, name string) ([]byte, error) {
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// socks5ReplyError is the reply code of a SOCKS5 proxy refusing a request
+type socks5ReplyError byte
+
+func (e socks5ReplyError) Error() string {
+	reasons := map[socks5ReplyError]string{
+		1: "general failure",
+		2: "connection not allowed by ruleset",
+		3: "network unreachable",
+		4: "host unreachable",
+		5: "connection refused",
+		6: "TTL expired",
+		7: "command not supported",
+		8: "address type not supported",
+	}
+	if reason, ok := reasons[e]; ok {
+		return "SOCKS5 request rejected: " + reason
+	}
+	return fmt.Sprintf("SOCKS5 request rejected with code %#x", byte(e))
+}
+
+// socks5Connect asks the SOCKS5 proxy at the other end of conn to open a
+// tunnel to addr. Hostnames are sent as they are, for the proxy to resolve.
+func socks5Connect(conn net.Conn, proxyAddr ProxyAddr, addr string) error {
+	if err := socks5Auth(conn, proxyAddr); err != nil {
+		return err
+	}
+	_, err := socks5Command(conn, socks5CmdConnect, addr)
+	return err
+}
+
+// socks5DNS dials through a SOCKS5 proxy and records how it resolves
+// hostnames: remotely when it accepts them, locally when it refuses them but
+// accepts their resolved IP
+type socks5DNS struct {
+	resolver *dnsCache
+	remote   atomic.Bool
+	local    atomic.Bool
+}
+
+// dial opens a tunnel to addr through the proxy. A hostname refused by the
+// proxy is resolved locally and tried again by IP.
+func (d *socks5DNS) dial(ctx context.Context, dialer contextDialer, proxyAddr ProxyAddr, addr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := dialProxy(ctx, dialer, ProxyTypeSOCKS5, proxyAddr, addr, timeout)
+	host, port, splitErr := net.SplitHostPort(addr)
+	if splitErr != nil || net.ParseIP(host) != nil {
+		return conn, err
+	}
+	if err == nil {
+		d.remote.Store(true)
+		return conn, nil
+	}
+	var replyErr socks5ReplyError
+	if !errors.As(err, &replyErr) {
+		return nil, err
+	}
+
+	ips, lookupErr := d.resolver.LookupHost(ctx, host)
+	if lookupErr != nil || len(ips) == 0 {
+		return nil, err
+	}
+	conn, retryErr := dialProxy(ctx, dialer, ProxyTypeSOCKS5, proxyAddr, net.JoinHostPort(ips[0], port), timeout)
+	if retryErr != nil {
+		return nil, err
+	}
+	d.local.Store(true)
+	return conn, nil
+}
+
+// mode returns where the proxy resolved the hostnames dialed so far: remote
+// or local, empty if no hostname was dialed
+func (d *socks5DNS) mode() string {
+	switch {
+	case d.remote.Load():
+		return "remote"
+	case d.local.Load():
+		return "local"
+	}
+	return ""
+}
+
+// socks5Auth negotiates the authentication method with the SOCKS5 proxy at
+// the other end of conn, and authenticates with the proxy credentials if it
+// asks for them
+func socks5Auth(conn net.Conn, proxyAddr ProxyAddr) error {
+	methods := []byte{socks5AuthNone}
+	if proxyAddr.HasAuth() {
+		methods = append(methods, socks5AuthPassword)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("invalid SOCKS5 reply version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5AuthNone:
+		return nil
+	case socks5AuthPassword:
+		if !proxyAddr.HasAuth() || len(proxyAddr.Username) > 255 || len(proxyAddr.Password) > 255 {
+			return errors.New("SOCKS5 proxy requires credentials")
+		}
+		// VER, ULEN, UNAME, PLEN, PASSWD
+		req := []byte{1, byte(len(proxyAddr.Username))}
+		req = append(req, proxyAddr.Username...)
+		req = append(req, byte(len(proxyAddr.Password)))
+		req = append(req, proxyAddr.Password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("SOCKS5 authentication rejected")
+		}
+		return nil
+	default:
+		return errors.New("no acceptable SOCKS5 authentication method")
+	}
+}
+
+// socks5Command sends a request with the given command and address to the
+// SOCKS5 proxy at the other end of conn, once authenticated, and returns
+// the address of its reply
+func socks5Command(conn net.Conn, cmd byte, addr string) (string, error) {
+	req, err := appendSOCKS5Addr([]byte{socks5Version, cmd, 0}, addr)
+	if err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return "", err
+	}
+
+	// VER, REP, RSV, ATYP
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return "", err
+	}
+	if reply[0] != socks5Version {
+		return "", fmt.Errorf("invalid SOCKS5 reply version %d", reply[0])
+	}
+	if reply[1] != 0 {
+		return "", socks5ReplyError(reply[1])
+	}
+
+	var host []byte
+	switch reply[3] {
+	case socks5AtypIPv4:
+		host = make([]byte, net.IPv4len)
+	case socks5AtypIPv6:
+		host = make([]byte, net.IPv6len)
+	case socks5AtypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return "", err
+		}
+		host = make([]byte, n[0])
+	default:
+		return "", fmt.Errorf("invalid SOCKS5 address type %d", reply[3])
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(conn, host); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return "", err
+	}
+	hostStr := string(host)
+	if reply[3] != socks5AtypDomain {
+		hostStr = net.IP(host).String()
+	}
+	return net.JoinHostPort(hostStr, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// appendSOCKS5Addr appends the SOCKS5 encoding of addr, an IP or hostname
+// with a port, to b
+func appendSOCKS5Addr(b []byte, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("hostname too long: %q", host)
+		}
+		b = append(b, socks5AtypDomain, byte(len(host)))
+		b = append(b, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		b = append(append(b, socks5AtypIPv4), ip4...)
+	} else {
+		b = append(append(b, socks5AtypIPv6), ip...)
+	}
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
@@ -0,0 +1,503 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parsers that extract proxies from a source response
+const (
+	ParserAuto = "auto" // Detected from the Content-Type and the shape of the response
+	ParserText = "text" // One proxy per line, plain text or simple JSON
+	ParserHTML = "html" // HTML rows matched by Selector, or any table row
+	ParserJSON = "json" // JSON document with proxy objects, objects keyed by IP or IP:PORT strings
+	ParserCSV  = "csv"  // CSV rows, with IP and port columns named by a header or found by shape
+)
+
+// Source types
+const (
+	SourceURL      = "url"      // Proxy list fetched from URL
+	SourceTelegram = "telegram" // Recent messages of a public Telegram channel
+	SourceGitHub   = "github"   // Proxy lists discovered with the GitHub code search
+	SourceFile     = "file"     // Local proxy list file
+	SourceStdin    = "stdin"    // Proxy list read from the standard input
+	SourceCommand  = "command"  // Output of a command, e.g. a custom crawler
+	SourceCIDR     = "cidr"     // Every address and port of ranges of your own infrastructure, requires scraper.allow_cidr
+)
+
+// Source is a proxy list URL together with the options used to fetch and
+// parse it. Sources of other types are fetched by the ProxySource their
+// type is registered with, see RegisterSource.
+type Source struct {
+	Type       string            `yaml:"type"`        // SourceURL (default), SourceTelegram, SourceGitHub, SourceFile, SourceStdin, SourceCommand, SourceCIDR or a registered type
+	URL        string            `yaml:"url"`         // May contain a {page} placeholder, see Pages
+	Protocol   string            `yaml:"protocol"`    // Protocol of the listed proxies: http, socks5 or auto to detect it
+	Parser     string            `yaml:"parser"`      // One of ParserAuto, ParserText, ParserHTML, ParserJSON or ParserCSV
+	Selector   string            `yaml:"selector"`    // CSS selector of the rows holding proxies (html parser only)
+	Regex      string            `yaml:"regex"`       // Regular expression with named groups ip and port, optionally user and pass, used instead of the parser
+	Headers    map[string]string `yaml:"headers"`     // Extra request headers, e.g. Referer or an API key
+	Timeout    time.Duration     `yaml:"timeout"`     // Request timeout, defaults to scraper.timeout
+	RateLimit  float64           `yaml:"rate_limit"`  // Maximum requests per second to this source, 0 for no limit
+	Pages      *PageRange        `yaml:"pages"`       // Page numbers substituted for {page} in the URL, stops at the first page without new proxies
+	Channel    string            `yaml:"channel"`     // Telegram channel username (telegram only)
+	History    int               `yaml:"history"`     // Telegram web preview pages of about 20 messages to read, default 1
+	BotToken   string            `yaml:"bot_token"`   // Read channel posts through the Bot API instead of the web preview
+	Query      string            `yaml:"query"`       // GitHub code search query (github only)
+	MaxResults int               `yaml:"max_results"` // Maximum number of discovered files to scrape, default 30 (github only)
+	Token      string            `yaml:"token"`       // GitHub API token, defaults to scraper.github_token (github only)
+	Path       string            `yaml:"path"`        // Path of the proxy list (file only)
+	Command    []string          `yaml:"command"`     // Program and arguments whose output lists proxies (command only)
+	CIDRs      []string          `yaml:"cidrs"`       // Address ranges, e.g. 10.0.0.0/24, or single addresses (cidr only)
+	Ports      []string          `yaml:"ports"`       // Ports or port ranges, e.g. 3128 or 8000-8010, checked on every address (cidr only)
+	Options    map[string]string `yaml:"options"`     // Settings of registered source types
+
+	retries    int           // Extra attempts of failed requests, from scraper.retries
+	retryDelay time.Duration // Delay before the first retry, from scraper.retry_delay
+	hosts      *HostLimiter  // Limit of requests per host shared by all sources, nil for no limit
+	client     *http.Client  // Client of url, telegram and github sources
+	userAgent  string        // User agent of url, telegram and github sources
+	cidrLimit  int           // Maximum candidates of cidr sources, from scraper.cidr_max_candidates, 0 unless scraper.allow_cidr
+}
+
+// PageRange is the range of page numbers fetched from a paginated source
+type PageRange struct {
+	Start int `yaml:"start"`
+	End   int `yaml:"end"`
+	Step  int `yaml:"step"` // Defaults to 1
+}
+
+// sourcesFile is the layout of sources.yaml
+type sourcesFile struct {
+	Sources []Source `yaml:"sources"`
+}
+
+// PageURLs returns the URLs to fetch for the source, one per page for a
+// paginated source
+func (s Source) PageURLs() []string {
+	if s.Pages == nil || !strings.Contains(s.URL, "{page}") {
+		return []string{s.URL}
+	}
+	var urls []string
+	for page := s.Pages.Start; page <= s.Pages.End; page += s.Pages.Step {
+		urls = append(urls, strings.ReplaceAll(s.URL, "{page}", strconv.Itoa(page)))
+	}
+	return urls
+}
+
+// ParseSource parses a line of a sources file. A line holds the source URL,
+// optionally followed by a page range for a URL containing {page}, written
+// start-end or start-end:step, and by the parser to use:
+//
+//	https://example.com/proxies.txt
+//	https://example.com/list text
+//	https://example.com/api?page={page} pages=1-20
+//	https://example.com/free-proxies html
+//	https://example.com/free-proxies?p={page} pages=1-5 html=table#proxies tbody tr
+//	https://t.me/s/channel history=3
+//
+// A selector extends to the end of the line. Telegram channel URLs select
+// the telegram source type.
+func ParseSource(line string) (Source, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Source{}, errors.New("empty source")
+	}
+	source := Source{Type: SourceURL, URL: fields[0], Parser: ParserAuto}
+	if channel, ok := telegramChannel(source.URL); ok {
+		source.Type = SourceTelegram
+		source.Channel = channel
+	}
+
+options:
+	for i, field := range fields[1:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch key = strings.ToLower(key); key {
+		case "pages":
+			pages, err := parsePageRange(value)
+			if err != nil {
+				return Source{}, fmt.Errorf("pages: %w", err)
+			}
+			source.Pages = pages
+		case "history":
+			history, err := strconv.Atoi(value)
+			if err != nil {
+				return Source{}, fmt.Errorf("history: invalid number %q", value)
+			}
+			source.History = history
+		case ParserAuto, ParserText, ParserHTML, ParserJSON, ParserCSV:
+			source.Parser = key
+			if value != "" {
+				source.Selector = strings.Join(append([]string{value}, fields[i+2:]...), " ")
+				break options
+			}
+		default:
+			return Source{}, fmt.Errorf("unknown option %q", field)
+		}
+	}
+
+	if err := source.validate(); err != nil {
+		return Source{}, err
+	}
+	return source, nil
+}
+
+// parsePageRange parses a page range written start-end or start-end:step
+func parsePageRange(value string) (*PageRange, error) {
+	bounds, step, hasStep := strings.Cut(value, ":")
+	start, end, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid range %q, expected start-end or start-end:step", value)
+	}
+
+	var pages PageRange
+	var err error
+	if pages.Start, err = strconv.Atoi(start); err != nil {
+		return nil, fmt.Errorf("invalid start %q", start)
+	}
+	if pages.End, err = strconv.Atoi(end); err != nil {
+		return nil, fmt.Errorf("invalid end %q", end)
+	}
+	if hasStep {
+		if pages.Step, err = strconv.Atoi(step); err != nil {
+			return nil, fmt.Errorf("invalid step %q", step)
+		}
+	}
+	return &pages, nil
+}
+
+// ParseSources parses every line of a sources file, see ParseSource
+func ParseSources(lines []string) ([]Source, error) {
+	sources := make([]Source, 0, len(lines))
+	for _, line := range lines {
+		source, err := ParseSource(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", line, err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// LoadSources reads the structured sources file at path. Entries are
+// validated and their defaults filled in.
+func LoadSources(path string) ([]Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file sourcesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	for i := range file.Sources {
+		source := &file.Sources[i]
+		source.Protocol = strings.ToLower(source.Protocol)
+		if source.Protocol != "http" && source.Protocol != "socks5" && source.Protocol != "auto" {
+			return nil, fmt.Errorf("sources[%d]: protocol: unknown protocol %q, expected http, socks5 or auto", i, source.Protocol)
+		}
+		if err := source.validate(); err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+	}
+	return file.Sources, nil
+}
+
+// validate checks the source options other than the protocol and fills in
+// defaults
+func (s *Source) validate() error {
+	s.Type = strings.ToLower(s.Type)
+	switch s.Type {
+	case "":
+		s.Type = SourceURL
+	case SourceURL:
+	case SourceTelegram:
+		return s.validateTelegram()
+	case SourceGitHub:
+		return s.validateGitHub()
+	case SourceFile, SourceStdin, SourceCommand:
+		return s.validateLocal()
+	case SourceCIDR:
+		return s.validateCIDR()
+	default:
+		return s.validateRegistered()
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url: invalid URL %q, expected http:// or https://", s.URL)
+	}
+
+	if err := s.validateCommon(); err != nil {
+		return err
+	}
+
+	if s.Pages != nil {
+		if !strings.Contains(s.URL, "{page}") {
+			return errors.New("pages: url has no {page} placeholder")
+		}
+		if s.Pages.Step == 0 {
+			s.Pages.Step = 1
+		}
+		if s.Pages.Step < 0 || s.Pages.End < s.Pages.Start {
+			return fmt.Errorf("pages: invalid range %d to %d by %d", s.Pages.Start, s.Pages.End, s.Pages.Step)
+		}
+	}
+	return nil
+}
+
+// validateCommon checks the options shared by all source types: parser,
+// selector, regex, timeout and rate limit
+func (s *Source) validateCommon() error {
+	s.Parser = strings.ToLower(s.Parser)
+	switch s.Parser {
+	case "":
+		s.Parser = ParserAuto
+	case ParserAuto, ParserText, ParserHTML, ParserJSON, ParserCSV:
+	default:
+		return fmt.Errorf("parser: unknown parser %q, expected auto, text, html, json or csv", s.Parser)
+	}
+	if s.Selector != "" {
+		if _, err := parseSelector(s.Selector); err != nil {
+			return fmt.Errorf("selector: %w", err)
+		}
+	}
+	if s.Regex != "" {
+		if _, err := compileSourceRegex(s.Regex); err != nil {
+			return fmt.Errorf("regex: %w", err)
+		}
+		if s.Selector != "" {
+			return errors.New("regex: cannot be combined with selector")
+		}
+	}
+
+	if s.Timeout < 0 {
+		return errors.New("timeout: must not be negative")
+	}
+	if s.RateLimit < 0 {
+		return errors.New("rate_limit: must not be negative")
+	}
+	return nil
+}
+
+// validateTelegram checks the options of a telegram source and fills in
+// defaults
+func (s *Source) validateTelegram() error {
+	if s.Channel == "" {
+		if channel, ok := telegramChannel(s.URL); ok {
+			s.Channel = channel
+		}
+	}
+	s.Channel = strings.TrimPrefix(s.Channel, "@")
+	if !telegramChannelRe.MatchString(s.Channel) {
+		return fmt.Errorf("channel: invalid channel username %q", s.Channel)
+	}
+	s.URL = telegramPreviewURL + s.Channel
+
+	if s.History == 0 {
+		s.History = 1
+	}
+	if s.History < 0 {
+		return errors.New("history: must not be negative")
+	}
+	if s.Pages != nil {
+		return errors.New("pages: not supported by telegram sources, use history")
+	}
+	return s.validateCommon()
+}
+
+// validateGitHub checks the options of a github source and fills in
+// defaults
+func (s *Source) validateGitHub() error {
+	if s.Query == "" {
+		filename := s.Protocol
+		if filename == "auto" {
+			filename = "proxies"
+		}
+		s.Query = fmt.Sprintf("proxy in:path filename:%s.txt", filename)
+	}
+	if s.MaxResults == 0 {
+		s.MaxResults = 30
+	}
+	if s.MaxResults < 0 || s.MaxResults > 100 {
+		return fmt.Errorf("max_results: %d out of range 1-100", s.MaxResults)
+	}
+	if s.Pages != nil {
+		return errors.New("pages: not supported by github sources")
+	}
+	s.URL = githubSearchURL + "?q=" + url.QueryEscape(s.Query)
+	return s.validateCommon()
+}
+
+// validateLocal checks the options of a file, stdin or command source. Its
+// URL is set to a file://, stdin: or command: URL naming it in logs and
+// source health.
+func (s *Source) validateLocal() error {
+	switch s.Type {
+	case SourceFile:
+		if s.Path == "" {
+			return errors.New("path: required by file sources")
+		}
+		path, err := filepath.Abs(s.Path)
+		if err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+		s.URL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	case SourceStdin:
+		s.URL = "stdin:"
+	case SourceCommand:
+		if len(s.Command) == 0 || s.Command[0] == "" {
+			return errors.New("command: required by command sources")
+		}
+		s.URL = "command:" + strings.Join(s.Command, " ")
+	}
+	if s.Pages != nil {
+		return fmt.Errorf("pages: not supported by %s sources", s.Type)
+	}
+	return s.validateCommon()
+}
+
+// validateCIDR checks the ranges and ports of a cidr source. Its URL is set
+// to a cidr: URL naming them in logs and source health.
+func (s *Source) validateCIDR() error {
+	if len(s.CIDRs) == 0 {
+		return errors.New("cidrs: required by cidr sources")
+	}
+	if len(s.Ports) == 0 {
+		return errors.New("ports: required by cidr sources")
+	}
+	if _, _, err := parseCIDRSource(*s); err != nil {
+		return err
+	}
+	if s.Pages != nil || s.Regex != "" || s.Selector != "" {
+		return errors.New("pages, regex and selector are not supported by cidr sources")
+	}
+	s.URL = "cidr:" + strings.Join(s.CIDRs, ",") + ";ports=" + strings.Join(s.Ports, ",")
+	return s.validateCommon()
+}
+
+// validateRegistered checks a source of a registered type by creating its
+// ProxySource. Its URL defaults to the type.
+func (s *Source) validateRegistered() error {
+	factory, ok := sourceFactory(s.Type)
+	if !ok {
+		return fmt.Errorf("type: unknown type %q, expected url, telegram, github, file, stdin, command, cidr or a registered type", s.Type)
+	}
+	if s.URL == "" {
+		s.URL = s.Type + ":"
+	}
+	if err := s.validateCommon(); err != nil {
+		return err
+	}
+	if _, err := factory(*s); err != nil {
+		return fmt.Errorf("%s source: %w", s.Type, err)
+	}
+	return nil
+}
+
+// SourceDuplicate is a source skipped because it lists the same proxies as
+// an earlier one
+type SourceDuplicate struct {
+	Source Source
+	Of     Source // Source kept in its place
+}
+
+// DedupeSources returns sources without the entries fetching the same list
+// as an earlier one with the same protocol and parsing options, and the
+// entries left out. URLs are compared normalized: scheme, host case,
+// default ports, trailing slashes, query order and fragments are ignored,
+// and the raw.githubusercontent.com, github.com raw and blob, and jsDelivr
+// mirrors of a GitHub file are the same list.
+func DedupeSources(sources []Source) ([]Source, []SourceDuplicate) {
+	unique := make([]Source, 0, len(sources))
+	var duplicates []SourceDuplicate
+	seen := make(map[string]int, len(sources))
+	for _, source := range sources {
+		pages := ""
+		if source.Pages != nil {
+			pages = fmt.Sprint(*source.Pages)
+		}
+		key := strings.Join([]string{
+			strings.ToLower(source.Protocol), source.Type, NormalizeSourceURL(source.URL),
+			source.Parser, source.Selector, source.Regex, pages,
+		}, "\x00")
+		if i, ok := seen[key]; ok {
+			duplicates = append(duplicates, SourceDuplicate{Source: source, Of: unique[i]})
+			continue
+		}
+		seen[key] = len(unique)
+		unique = append(unique, source)
+	}
+	return unique, duplicates
+}
+
+// NormalizeSourceURL returns the form of a source URL that sources are
+// compared by, see DedupeSources. URLs other than http:// and https:// are
+// returned unchanged.
+func NormalizeSourceURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (!strings.EqualFold(u.Scheme, "http") && !strings.EqualFold(u.Scheme, "https")) {
+		return rawURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	path := strings.TrimRight(u.Path, "/")
+	query := u.Query()
+
+	// Mirrors of a file of a GitHub repository
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	switch {
+	case host == "raw.githubusercontent.com" && len(parts) >= 4:
+		return githubFileKey(parts[0], parts[1], parts[2:])
+	case host == "github.com" && len(parts) >= 5 && (parts[2] == "raw" || parts[2] == "blob" && query.Get("raw") == "true"):
+		return githubFileKey(parts[0], parts[1], parts[3:])
+	case host == "cdn.jsdelivr.net" && len(parts) >= 4 && parts[0] == "gh":
+		repo, ref, ok := strings.Cut(parts[2], "@")
+		if !ok {
+			ref = "HEAD"
+		}
+		return githubFileKey(parts[1], repo, append([]string{ref}, parts[3:]...))
+	case host == "t.me" || host == "telegram.me":
+		path = strings.ToLower(path)
+		host = "t.me"
+	}
+
+	normalized := "//" + host + path
+	if len(query) > 0 {
+		normalized += "?" + query.Encode()
+	}
+	return normalized
+}
+
+// githubFileKey returns the key of a file of a GitHub repository given by
+// its ref and path, which may start with refs/heads/
+func githubFileKey(owner, repo string, refPath []string) string {
+	if len(refPath) > 2 && refPath[0] == "refs" && refPath[1] == "heads" {
+		refPath = refPath[2:]
+	}
+	return "github:" + strings.ToLower(owner+"/"+repo) + "/" + strings.Join(refPath, "/")
+}
+
+// SourcesFor returns the sources listing proxies of the given protocol
+func SourcesFor(sources []Source, protocol string) []Source {
+	var filtered []Source
+	for _, source := range sources {
+		if strings.EqualFold(source.Protocol, protocol) {
+			filtered = append(filtered, source)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,77 @@
+package src
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StableRecord is the JSON representation of a proxy of stable.json
+type StableRecord struct {
+	Proxy        string    `json:"proxy"`
+	Type         string    `json:"type"`
+	Streak       int       `json:"streak"`         // Consecutive runs passed, the last one included
+	Uptime       float64   `json:"uptime"`         // Percentage of checks passed across all runs
+	AvgLatencyMs int64     `json:"avg_latency_ms"` // Average latency of the checks passed
+	FirstSeen    time.Time `json:"first_seen"`
+}
+
+// writeStable writes the proxies that passed at least store.stable_runs
+// runs in a row, the run started at since included, to
+// <output dir>/stable.txt as <type>://<proxy> lines and to
+// <output dir>/stable.json, longest streak first
+func (c *ProxyChecker) writeStable(ctx context.Context, since time.Time) error {
+	runs := c.config.Store.StableRuns
+	if c.Store == nil || runs <= 0 {
+		return nil
+	}
+
+	var records []StableRecord
+	for _, proxyType := range DetectOrder {
+		entries, err := c.Store.Entries(ctx, proxyType.String())
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			// Store timestamps have a resolution of one second
+			if entry.Streak < runs || entry.LastChecked.Before(since.Truncate(time.Second)) {
+				continue
+			}
+			records = append(records, StableRecord{
+				Proxy:        entry.Proxy,
+				Type:         entry.Type,
+				Streak:       entry.Streak,
+				Uptime:       entry.Uptime(),
+				AvgLatencyMs: entry.AvgLatency().Milliseconds(),
+				FirstSeen:    entry.FirstSeen,
+			})
+		}
+	}
+	slices.SortFunc(records, func(a, b StableRecord) int {
+		if a.Streak != b.Streak {
+			return cmp.Compare(b.Streak, a.Streak)
+		}
+		return cmp.Compare(a.AvgLatencyMs, b.AvgLatencyMs)
+	})
+
+	var lines strings.Builder
+	for _, record := range records {
+		lines.WriteString(strings.ToLower(record.Type) + "://" + record.Proxy + "\n")
+	}
+	if err := writeFileAtomic(filepath.Join(c.config.Output.Dir, "stable.txt"), []byte(lines.String())); err != nil {
+		return err
+	}
+
+	if records == nil {
+		records = []StableRecord{}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.config.Output.Dir, "stable.json"), data)
+}
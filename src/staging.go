@@ -0,0 +1,180 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// stagingDir is the directory of the output directory the files of a run
+// are written to with output.write_mode: atomic, until they are published
+const stagingDir = ".run"
+
+// openStaging prepares the staging directory of a run writing to dir and
+// returns its path. A new run starts from empty HTTP and SOCKS5 files. A
+// resumed run continues the files of the interrupted one: those left in the
+// staging directory if it was killed before publishing them, or the
+// published ones.
+func openStaging(dir string, resumed bool) (string, error) {
+	staging := filepath.Join(dir, stagingDir)
+	if !resumed {
+		if err := os.RemoveAll(staging); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(staging, 0755); err != nil {
+			return "", err
+		}
+		for _, proxyType := range []ProxyType{ProxyTypeHTTP, ProxyTypeSOCKS5} {
+			if err := os.WriteFile(OutputFile(staging, proxyType), nil, 0644); err != nil {
+				return "", err
+			}
+		}
+		return staging, nil
+	}
+
+	paths, err := stagedPaths(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		target := filepath.Join(staging, rel)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := copyFile(path, target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return staging, nil
+}
+
+// publishStaging moves the files of the staging directory into dir, each
+// one replacing its previous version with a rename, so that readers see
+// either the previous or the new file in full. The HTTPS, SOCKS4 and
+// per-country files of the previous run that the new one did not write are
+// removed.
+func publishStaging(staging, dir string) error {
+	previous, err := filepath.Glob(filepath.Join(dir, "by_country", "*.txt"))
+	if err != nil {
+		return err
+	}
+	for _, proxyType := range DetectOrder {
+		previous = append(previous, OutputFile(dir, proxyType))
+	}
+
+	paths, err := stagedPaths(staging)
+	if err != nil {
+		return err
+	}
+	var published []string
+	for _, path := range paths {
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(path, target); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		published = append(published, target)
+	}
+
+	for _, path := range previous {
+		if slices.Contains(published, path) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.RemoveAll(staging)
+}
+
+// snapshotStaging copies the proxy files of the staging directory over
+// those of dir, each one replaced with a rename, while the run keeps
+// appending to the staged files. locks are the locks of the staged files.
+func snapshotStaging(staging, dir string, locks map[ProxyType]*sync.Mutex) error {
+	for _, proxyType := range DetectOrder {
+		locks[proxyType].Lock()
+		data, err := os.ReadFile(OutputFile(staging, proxyType))
+		locks[proxyType].Unlock()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(OutputFile(dir, proxyType), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagedPaths returns the files of dir a run writes: the text output files,
+// per-target and per-country files included, and the CSV file. Some of
+// them may not exist.
+func stagedPaths(dir string) ([]string, error) {
+	paths, err := outputPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	return append(paths, filepath.Join(dir, "proxies.csv")), nil
+}
+
+// copyFile copies the file at src to dst, creating the directory of dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeFileAtomic writes data to a temporary file next to path, then
+// renames it to path, so that readers never see a partly written file
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
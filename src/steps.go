@@ -0,0 +1,172 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Built-in check steps, see checker.steps
+const (
+	StepConnectivity = "connectivity" // The test URL answers through the proxy, measuring its latency
+	StepGeo          = "geo"          // Exit IP and location from the IP lookup service (strict_check only)
+	StepAnonymity    = "anonymity"    // Anonymity level from the headers seen by a judge (strict_check only)
+	StepTLS          = "tls"          // TLS interception, with checker.tls_check
+	StepContent      = "content"      // Content tampering, with checker.content_check
+	StepCapabilities = "capabilities" // Keep-alive and HTTP/2 support, with checker.capability_check
+	StepCloudflare   = "cloudflare"   // Content of a site fronted by Cloudflare, with checker.cloudflare_check
+	StepSearch       = "search"       // Captcha walls of checker.search_engines, with checker.search_check
+	StepUDP          = "udp"          // UDP relay of SOCKS5 proxies, with checker.udp_check
+	StepBandwidth    = "bandwidth"    // Throughput, with checker.bandwidth_url
+	StepTargets      = "targets"      // Sites of checker.targets
+	StepRisk         = "risk"         // Honeypot heuristics, with risk.enabled
+)
+
+// DefaultCheckSteps are the check steps run when checker.steps is not set
+var DefaultCheckSteps = []string{
+	StepConnectivity, StepGeo, StepAnonymity, StepTLS, StepContent,
+	StepCapabilities, StepCloudflare, StepSearch, StepUDP, StepBandwidth, StepTargets, StepRisk,
+}
+
+// CheckStep is a stage of the checks run on every proxy. Steps run in the
+// order of checker.steps, after connectivity, as long as the proxy works.
+type CheckStep interface {
+	// Check tests the proxy with client, which sends requests through it,
+	// and records its findings in result. Clearing result.Working fails
+	// the proxy and skips the next steps.
+	Check(ctx context.Context, client *http.Client, result *CheckResult)
+}
+
+// CheckStepFunc adapts a function to the CheckStep interface
+type CheckStepFunc func(ctx context.Context, client *http.Client, result *CheckResult)
+
+// Check calls f
+func (f CheckStepFunc) Check(ctx context.Context, client *http.Client, result *CheckResult) {
+	f(ctx, client, result)
+}
+
+var (
+	checkStepsMu sync.RWMutex
+	checkSteps   = make(map[string]CheckStep)
+)
+
+// RegisterCheckStep makes a custom check step available to checker.steps
+// under name, which is case-insensitive. It panics if the name is that of
+// a built-in or already registered step, or if step is nil.
+func RegisterCheckStep(name string, step CheckStep) {
+	name = strings.ToLower(name)
+	if step == nil {
+		panic("src: RegisterCheckStep step is nil for " + name)
+	}
+	checkStepsMu.Lock()
+	defer checkStepsMu.Unlock()
+	if _, ok := checkSteps[name]; ok || slices.Contains(DefaultCheckSteps, name) {
+		panic("src: RegisterCheckStep called twice for " + name)
+	}
+	checkSteps[name] = step
+}
+
+// registeredCheckStep returns the custom check step registered under name
+func registeredCheckStep(name string) (CheckStep, bool) {
+	checkStepsMu.RLock()
+	defer checkStepsMu.RUnlock()
+	step, ok := checkSteps[strings.ToLower(name)]
+	return step, ok
+}
+
+// validateCheckSteps checks the names of checker.steps, which must start
+// with connectivity and list each step once
+func validateCheckSteps(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if !strings.EqualFold(names[0], StepConnectivity) {
+		return errors.New("must start with connectivity")
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if _, ok := registeredCheckStep(name); !ok && !slices.Contains(DefaultCheckSteps, name) {
+			return fmt.Errorf("unknown step %q, expected %s or a registered step", name, strings.Join(DefaultCheckSteps, ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("step %q listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// newCheckSteps returns the check steps of checker.steps
+func (c *ProxyChecker) newCheckSteps() []CheckStep {
+	names := c.config.Checker.Steps
+	if len(names) == 0 {
+		names = DefaultCheckSteps
+	}
+
+	builtin := map[string]CheckStepFunc{
+		StepConnectivity: c.checkConnectivity,
+		StepGeo:          c.checkGeo,
+		StepAnonymity:    c.checkAnonymity,
+		StepTLS:          c.probeTLS,
+		StepContent:      c.probeContent,
+		StepCapabilities: c.probeCapabilities,
+		StepCloudflare:   c.probeCloudflare,
+		StepSearch:       c.checkSearch,
+		StepUDP:          c.checkUDP,
+		StepBandwidth:    c.checkBandwidth,
+		StepTargets:      c.checkTargets,
+		StepRisk:         c.checkRisk,
+	}
+	steps := make([]CheckStep, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if step, ok := builtin[name]; ok {
+			steps = append(steps, step)
+		} else if step, ok := registeredCheckStep(name); ok {
+			steps = append(steps, step)
+		} else {
+			slog.Warn("Skipping unknown check step", "step", name)
+		}
+	}
+	return steps
+}
+
+// runSteps runs the check steps on a proxy, with client sending requests
+// through it. The first step, connectivity, sets whether the proxy works,
+// and the next ones run as long as it does.
+func (c *ProxyChecker) runSteps(ctx context.Context, client *http.Client, result *CheckResult) {
+	if capture := c.newHeaderCapture(); capture != nil {
+		ctx = context.WithValue(ctx, headerCaptureKey{}, capture)
+		defer func() { result.Headers = capture.captured() }()
+	}
+	for i, step := range c.steps {
+		if i > 0 && !result.Working {
+			return
+		}
+		step.Check(ctx, client, result)
+	}
+}
+
+// checkUDP is the udp step, probing whether a SOCKS5 proxy relays UDP
+func (c *ProxyChecker) checkUDP(ctx context.Context, client *http.Client, result *CheckResult) {
+	// UDP datagrams cannot go through the upstream proxy
+	if !c.config.Checker.UDPCheck || c.upstream != nil || result.Type != ProxyTypeSOCKS5 {
+		return
+	}
+	if addr, err := ParseProxyAddr(result.Proxy); err == nil {
+		result.UDP = c.probeUDP(ctx, addr)
+	}
+}
+
+// checkBandwidth is the bandwidth step, see measureBandwidth
+func (c *ProxyChecker) checkBandwidth(ctx context.Context, client *http.Client, result *CheckResult) {
+	if c.config.Checker.BandwidthURL != "" {
+		c.measureBandwidth(ctx, client, result)
+	}
+}
@@ -0,0 +1,179 @@
+// Package store persists the check history of proxies in SQLite so repeated
+// runs can tell reliable proxies from ones that only work occasionally.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS proxies (
+	proxy            TEXT    NOT NULL,
+	type             TEXT    NOT NULL,
+	first_seen       INTEGER NOT NULL,
+	last_checked     INTEGER NOT NULL,
+	success_count    INTEGER NOT NULL DEFAULT 0,
+	fail_count       INTEGER NOT NULL DEFAULT 0,
+	latency_total_ms INTEGER NOT NULL DEFAULT 0,
+	streak           INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (proxy, type)
+);
+`
+
+// Entry is the check history of a single proxy
+type Entry struct {
+	Proxy       string
+	Type        string
+	FirstSeen   time.Time
+	LastChecked time.Time
+	Successes   int
+	Failures    int
+	LatencyMs   int64 // Sum of the latencies of successful checks
+	Streak      int   // Consecutive successful checks up to the last one
+}
+
+// AvgLatency returns the average latency of successful checks
+func (e Entry) AvgLatency() time.Duration {
+	if e.Successes == 0 {
+		return 0
+	}
+	return time.Duration(e.LatencyMs/int64(e.Successes)) * time.Millisecond
+}
+
+// Uptime returns the percentage of checks the proxy passed
+func (e Entry) Uptime() float64 {
+	total := e.Successes + e.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(e.Successes) / float64(total) * 100
+}
+
+// Store records proxy check history in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialize access instead of failing
+	// with SQLITE_BUSY under concurrent checks
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA synchronous=NORMAL",
+		schema,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	// Databases created before streaks were recorded lack their column
+	if err := addColumn(db, "streak", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// addColumn adds a column to the proxies table unless it already exists
+func addColumn(db *sql.DB, name, definition string) error {
+	var exists bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('proxies') WHERE name = ?`, name).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec("ALTER TABLE proxies ADD COLUMN " + name + " " + definition)
+	return err
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores the outcome of a check and returns the updated history
+func (s *Store) Record(ctx context.Context, proxy, proxyType string, working bool, latency time.Duration, at time.Time) (Entry, error) {
+	success, fail, latencyMs := 0, 1, int64(0)
+	if working {
+		success, fail, latencyMs = 1, 0, latency.Milliseconds()
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO proxies (proxy, type, first_seen, last_checked, success_count, fail_count, latency_total_ms, streak)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (proxy, type) DO UPDATE SET
+			last_checked = excluded.last_checked,
+			success_count = success_count + excluded.success_count,
+			fail_count = fail_count + excluded.fail_count,
+			latency_total_ms = latency_total_ms + excluded.latency_total_ms,
+			streak = CASE WHEN excluded.streak > 0 THEN streak + 1 ELSE 0 END
+		RETURNING proxy, type, first_seen, last_checked, success_count, fail_count, latency_total_ms, streak`,
+		proxy, proxyType, at.Unix(), at.Unix(), success, fail, latencyMs, success)
+	return scanEntry(row)
+}
+
+// Get returns the history of a single proxy
+func (s *Store) Get(ctx context.Context, proxy, proxyType string) (Entry, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT proxy, type, first_seen, last_checked, success_count, fail_count, latency_total_ms, streak
+		FROM proxies WHERE proxy = ? AND type = ?`, proxy, proxyType)
+	entry, err := scanEntry(row)
+	if err == sql.ErrNoRows {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// Entries returns the history of all proxies of a type, keyed by proxy
+func (s *Store) Entries(ctx context.Context, proxyType string) (map[string]Entry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT proxy, type, first_seen, last_checked, success_count, fail_count, latency_total_ms, streak
+		FROM proxies WHERE type = ?`, proxyType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[string]Entry)
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries[entry.Proxy] = entry
+	}
+	return entries, rows.Err()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEntry reads an Entry from a row of the proxies table
+func scanEntry(row scanner) (Entry, error) {
+	var entry Entry
+	var firstSeen, lastChecked int64
+	err := row.Scan(&entry.Proxy, &entry.Type, &firstSeen, &lastChecked,
+		&entry.Successes, &entry.Failures, &entry.LatencyMs, &entry.Streak)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.FirstSeen = time.Unix(firstSeen, 0)
+	entry.LastChecked = time.Unix(lastChecked, 0)
+	return entry, nil
+}
@@ -0,0 +1,223 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// checkTargets requests every configured target through the proxy and
+// records the names of those that answered as expected. Targets only tag
+// the result, a proxy failing all of them is still considered working.
+func (c *ProxyChecker) checkTargets(ctx context.Context, client *http.Client, result *CheckResult) {
+	for _, target := range c.config.Checker.Targets {
+		if ctx.Err() != nil {
+			return
+		}
+		if c.checkTarget(ctx, client, target) {
+			result.Targets = append(result.Targets, target.Name)
+		}
+	}
+}
+
+// checkTarget returns true if target answered with an expected status code
+// and, when configured, a body containing the expected substring
+func (c *ProxyChecker) checkTarget(ctx context.Context, client *http.Client, target TargetConfig) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", target.URL, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+
+	resp, err := c.do(client, req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(target.ExpectStatus, resp.StatusCode) {
+		return false
+	}
+	if target.ExpectBody == "" {
+		return true
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(body, []byte(target.ExpectBody))
+}
+
+// targetOutput writes proxies that passed a target to
+// <output dir>/targets/<name>/<type>.txt
+type targetOutput struct {
+	dir   string
+	locks map[string]*sync.Mutex
+}
+
+// newTargetOutput creates the output files of every target, truncating
+// existing ones if truncate is set
+func newTargetOutput(dir string, targets []TargetConfig, truncate bool) (*targetOutput, error) {
+	t := &targetOutput{dir: dir, locks: make(map[string]*sync.Mutex)}
+	for _, target := range targets {
+		if err := os.MkdirAll(filepath.Join(dir, target.Name), 0755); err != nil {
+			return nil, err
+		}
+		for _, proxyType := range DetectOrder {
+			path := t.path(target.Name, proxyType)
+			flag := os.O_CREATE | os.O_WRONLY
+			if truncate {
+				flag |= os.O_TRUNC
+			}
+			file, err := os.OpenFile(path, flag, 0644)
+			if err != nil {
+				return nil, err
+			}
+			file.Close()
+			t.locks[path] = &sync.Mutex{}
+		}
+	}
+	return t, nil
+}
+
+// path returns the output file of a target for the given proxy type
+func (t *targetOutput) path(name string, proxyType ProxyType) string {
+	return filepath.Join(t.dir, name, strings.ToLower(proxyType.String())+".txt")
+}
+
+// Save appends line to the output file of every target result passed
+func (t *targetOutput) Save(result CheckResult, line string) error {
+	for _, name := range result.Targets {
+		path := t.path(name, result.Type)
+		if err := AppendLine(path, line, t.locks[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// targetMatrix records the working proxies of a run to write which of them
+// passed which targets once checking ends, to <output
+// dir>/target_matrix.json and target_matrix.csv. All methods are safe to
+// call on a nil *targetMatrix, which writes nothing.
+type targetMatrix struct {
+	targets []TargetConfig
+	mu      sync.Mutex
+	results []CheckResult
+}
+
+// matrixData is the content of target_matrix.json
+type matrixData struct {
+	Targets []matrixTarget `json:"targets"`
+	Proxies []matrixProxy  `json:"proxies"`
+}
+
+// matrixTarget is the success rate of a target across the working proxies
+type matrixTarget struct {
+	Name        string  `json:"name"`
+	URL         string  `json:"url"`
+	Passed      int     `json:"passed"`
+	SuccessRate float64 `json:"success_rate"` // Percentage of the working proxies that passed the target
+}
+
+// matrixProxy is the row of a working proxy
+type matrixProxy struct {
+	Proxy       string          `json:"proxy"` // <type>://<proxy>
+	Targets     map[string]bool `json:"targets"`
+	SuccessRate float64         `json:"success_rate"` // Percentage of the targets the proxy passed
+}
+
+// newTargetMatrix returns the matrix configured by output.target_matrix,
+// nil if it is disabled or no target is configured
+func newTargetMatrix(config *Config) *targetMatrix {
+	if !config.Output.TargetMatrix || len(config.Checker.Targets) == 0 {
+		return nil
+	}
+	return &targetMatrix{targets: config.Checker.Targets}
+}
+
+// add records a result saved to the output files
+func (m *targetMatrix) add(result CheckResult) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = append(m.results, result)
+}
+
+// write writes the matrix of the working proxies still in the output files
+// of dir, in the order they were checked
+func (m *targetMatrix) write(dir string) error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	results := slices.Clone(m.results)
+	m.mu.Unlock()
+	results, err := keptResults(dir, results)
+	if err != nil {
+		return err
+	}
+
+	data := matrixData{Targets: make([]matrixTarget, len(m.targets)), Proxies: make([]matrixProxy, 0, len(results))}
+	for i, target := range m.targets {
+		data.Targets[i] = matrixTarget{Name: target.Name, URL: target.URL}
+	}
+	header := []string{"proxy"}
+	for _, target := range m.targets {
+		header = append(header, target.Name)
+	}
+	rows := [][]string{append(header, "success_rate")}
+	for _, result := range results {
+		row := matrixProxy{Proxy: ProxyURI(result.Type, result.Proxy), Targets: make(map[string]bool, len(m.targets))}
+		record := []string{row.Proxy}
+		passed := 0
+		for i, target := range m.targets {
+			ok := slices.Contains(result.Targets, target.Name)
+			if ok {
+				passed++
+				data.Targets[i].Passed++
+			}
+			row.Targets[target.Name] = ok
+			record = append(record, strconv.FormatBool(ok))
+		}
+		row.SuccessRate = successRate(passed, len(m.targets))
+		data.Proxies = append(data.Proxies, row)
+		rows = append(rows, append(record, strconv.FormatFloat(row.SuccessRate, 'f', 1, 64)))
+	}
+	for i := range data.Targets {
+		data.Targets[i].SuccessRate = successRate(data.Targets[i].Passed, len(results))
+	}
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "target_matrix.json"), encoded); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "target_matrix.csv"), b.Bytes())
+}
+
+// successRate returns n out of total as a percentage rounded to one
+// decimal
+func successRate(n, total int) float64 {
+	return math.Round(percent(n, total)*10) / 10
+}
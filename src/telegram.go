@@ -0,0 +1,216 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Telegram endpoints, variables so they can be pointed at a mirror
+var (
+	telegramPreviewURL = "https://t.me/s/"
+	telegramAPIURL     = "https://api.telegram.org"
+)
+
+// telegramChannelRe matches valid public channel usernames
+var telegramChannelRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{4,31}$`)
+
+// telegramMessageSelector and telegramTextSelector select the messages of
+// the channel web preview and their text
+var (
+	telegramMessageSelector = selectorChain{{classes: []string{"tgme_widget_message"}, attrs: [][2]string{{"data-post", ""}}}}
+	telegramTextSelector    = selectorChain{{classes: []string{"tgme_widget_message_text"}}}
+)
+
+// telegramChannel returns the channel of a t.me/<channel> or
+// t.me/s/<channel> URL
+func telegramChannel(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != "t.me" && u.Host != "telegram.me" {
+		return "", false
+	}
+	channel := strings.TrimPrefix(strings.Trim(u.Path, "/"), "s/")
+	if !telegramChannelRe.MatchString(channel) {
+		return "", false
+	}
+	return channel, true
+}
+
+// scrapeTelegram returns the proxies posted in the recent messages of a
+// public Telegram channel, read from the Bot API when a token is set and
+// from the channel web preview otherwise
+func scrapeTelegram(ctx context.Context, client *http.Client, source Source, userAgent string) ([]string, error) {
+	var texts []string
+	var err error
+	if source.BotToken != "" {
+		texts, err = telegramBotPosts(ctx, client, source)
+	} else {
+		texts, err = telegramPreviewPosts(ctx, client, source, userAgent)
+	}
+
+	extract := extractTextProxies
+	if source.Regex != "" {
+		re, err := compileSourceRegex(source.Regex)
+		if err != nil {
+			return nil, err
+		}
+		extract = func(text string) []string { return extractRegexProxies([]byte(text), re) }
+	}
+
+	var proxies []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, proxy := range extract(text) {
+			if !seen[proxy] {
+				seen[proxy] = true
+				proxies = append(proxies, proxy)
+			}
+		}
+	}
+	return proxies, err
+}
+
+// telegramPreviewPosts reads the message texts of source.History pages of
+// the channel web preview, newest first. Each page holds about 20 messages
+// and links to the previous ones with ?before=<message id>.
+func telegramPreviewPosts(ctx context.Context, client *http.Client, source Source, userAgent string) ([]string, error) {
+	var interval time.Duration
+	if source.RateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / source.RateLimit)
+	}
+
+	var texts []string
+	pageURL := telegramPreviewURL + source.Channel
+	for page := 0; page < source.History; page++ {
+		if page > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return texts, ctx.Err()
+			}
+		}
+
+		body, _, err := fetchSource(ctx, client, source, pageURL, userAgent)
+		if err != nil {
+			return texts, err
+		}
+		doc, err := html.Parse(bytes.NewReader(body))
+		if err != nil {
+			return texts, fmt.Errorf("parsing HTML: %w", err)
+		}
+
+		oldest := 0
+		for _, message := range telegramMessageSelector.matchAll(doc) {
+			_, id, _ := strings.Cut(attr(message, "data-post"), "/")
+			if n, err := strconv.Atoi(id); err == nil && (oldest == 0 || n < oldest) {
+				oldest = n
+			}
+			for _, text := range telegramTextSelector.matchAll(message) {
+				texts = append(texts, nodeText(text))
+			}
+		}
+		if oldest <= 1 {
+			break
+		}
+		pageURL = fmt.Sprintf("%s%s?before=%d", telegramPreviewURL, source.Channel, oldest)
+	}
+	return texts, nil
+}
+
+// telegramUpdates is the getUpdates response of the Bot API
+type telegramUpdates struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      []struct {
+		ChannelPost       *telegramPost `json:"channel_post"`
+		EditedChannelPost *telegramPost `json:"edited_channel_post"`
+	} `json:"result"`
+}
+
+// telegramPost is a channel message of the Bot API
+type telegramPost struct {
+	Chat struct {
+		Username string `json:"username"`
+	} `json:"chat"`
+	Text    string `json:"text"`
+	Caption string `json:"caption"`
+}
+
+// telegramBotPosts reads the channel posts pending in the bot update queue.
+// The bot must be a member of the channel, and updates are not
+// acknowledged so other consumers still receive them.
+func telegramBotPosts(ctx context.Context, client *http.Client, source Source) ([]string, error) {
+	query := url.Values{"allowed_updates": {`["channel_post","edited_channel_post"]`}}
+	apiURL := fmt.Sprintf("%s/bot%s/getUpdates?%s", telegramAPIURL, source.BotToken, query.Encode())
+
+	// The token is part of the URL, keep it out of error messages
+	body, _, err := fetchSource(ctx, client, source, apiURL, "")
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("querying Bot API: %w", err)
+	}
+
+	var updates telegramUpdates
+	if err := json.Unmarshal(body, &updates); err != nil {
+		return nil, fmt.Errorf("parsing Bot API response: %w", err)
+	}
+	if !updates.OK {
+		return nil, fmt.Errorf("Bot API error: %s", updates.Description)
+	}
+
+	var texts []string
+	for _, update := range updates.Result {
+		for _, post := range []*telegramPost{update.ChannelPost, update.EditedChannelPost} {
+			if post != nil && strings.EqualFold(post.Chat.Username, source.Channel) {
+				texts = append(texts, post.Text, post.Caption)
+			}
+		}
+	}
+	return texts, nil
+}
+
+// extractTextProxies returns the proxies found in free-form text such as a
+// chat message. Proxies are either written as IP:PORT, possibly with a
+// scheme or credentials, or as an IP followed by a port a few words later,
+// e.g. "IP: 1.2.3.4 Port: 8080".
+func extractTextProxies(text string) []string {
+	var proxies []string
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == '|' || r == '`'
+		})
+		for i, field := range fields {
+			if strings.Contains(field, ":") {
+				if normalized, ok := isValidProxy(field); ok {
+					proxies = append(proxies, normalized)
+					continue
+				}
+			}
+			ip := net.ParseIP(field)
+			if ip == nil || ip.To4() == nil {
+				continue
+			}
+			for _, next := range fields[i+1 : min(i+3, len(fields))] {
+				if port, err := strconv.Atoi(next); err == nil && port > 0 && port <= 65535 {
+					proxies = append(proxies, net.JoinHostPort(ip.String(), next))
+					break
+				}
+			}
+		}
+	}
+	return proxies
+}
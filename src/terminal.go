@@ -0,0 +1,102 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Terminal draws a block of progress lines that is updated in place. On a
+// terminal supporting ANSI escape sequences the block is redrawn with cursor
+// movements; on other terminals, such as older Windows consoles, the lines
+// are joined into a single line rewritten with a carriage return. When the
+// output is not a terminal, e.g. redirected to a file, updates are skipped
+// and only the final state is written.
+type Terminal struct {
+	mu    sync.Mutex
+	out   io.Writer
+	tty   bool
+	ansi  bool
+	drawn int // Lines of the block drawn so far, 0 before the first update
+	width int // Width of the last single line drawn without ANSI support
+}
+
+// NewTerminal returns a Terminal writing to f, detecting whether f is a
+// terminal and enabling ANSI escape sequences where possible
+func NewTerminal(f *os.File) *Terminal {
+	fd := f.Fd()
+	t := &Terminal{out: f}
+	switch {
+	case isatty.IsCygwinTerminal(fd):
+		// Cygwin and MSYS terminals such as mintty interpret ANSI sequences
+		t.tty, t.ansi = true, true
+	case isatty.IsTerminal(fd):
+		t.tty, t.ansi = true, enableVirtualTerminal(f)
+	}
+	return t
+}
+
+// ANSI reports whether lines are redrawn with ANSI escape sequences, which
+// also means that block characters such as progress bars can be used
+func (t *Terminal) ANSI() bool {
+	return t.ansi
+}
+
+// Update redraws the progress block with lines
+func (t *Terminal) Update(lines ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tty {
+		t.draw(lines)
+	}
+}
+
+// Done draws the final state of the progress block and ends it, so the
+// next update starts a new block below it
+func (t *Terminal) Done(lines ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tty {
+		t.draw(lines)
+	} else {
+		fmt.Fprint(t.out, strings.Join(lines, "\n"))
+	}
+	fmt.Fprintln(t.out)
+	t.drawn, t.width = 0, 0
+}
+
+// draw writes lines over the previously drawn block
+func (t *Terminal) draw(lines []string) {
+	var b strings.Builder
+	if t.ansi {
+		if t.drawn > 1 {
+			fmt.Fprintf(&b, "\033[%dA", t.drawn-1)
+		}
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString("\r\033[K" + line)
+		}
+		// Clear lines left over from a taller previous block
+		for i := len(lines); i < t.drawn; i++ {
+			b.WriteString("\n\r\033[K")
+		}
+		t.drawn = max(t.drawn, len(lines))
+	} else {
+		line := strings.Join(lines, " | ")
+		width := utf8.RuneCountInString(line)
+		b.WriteString("\r" + line)
+		if width < t.width {
+			// Overwrite the end of the longer previous line
+			b.WriteString(strings.Repeat(" ", t.width-width))
+		}
+		t.width = width
+	}
+	io.WriteString(t.out, b.String())
+}
@@ -0,0 +1,17 @@
+//go:build !unix && !windows
+
+package src
+
+import "os"
+
+// enableVirtualTerminal reports whether the terminal f supports ANSI escape
+// sequences, assumed on platforms other than Unix and Windows
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
+
+// terminalSize returns the number of columns and rows of the terminal f,
+// which is unknown on platforms other than Unix and Windows
+func terminalSize(f *os.File) (width, height int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package src
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableVirtualTerminal reports whether the terminal f supports ANSI escape
+// sequences, which all Unix terminals do
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
+
+// terminalSize returns the number of columns and rows of the terminal f
+func terminalSize(f *os.File) (width, height int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), true
+}
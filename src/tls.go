@@ -0,0 +1,89 @@
+package src
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// probeTLS requests checker.tls_check_url through a working proxy and
+// records whether the proxy can carry TLS and whether it intercepts it. The
+// certificate chain it serves is accepted without verification, then
+// checked against the system roots and, when set, checker.tls_pins: a chain
+// failing either was tampered with. Intercepting proxies are flagged, and
+// marked as not working with checker.tls_check: exclude.
+func (c *ProxyChecker) probeTLS(ctx context.Context, client *http.Client, result *CheckResult) {
+	if c.config.Checker.TLSCheck == "" {
+		return
+	}
+	base, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer transport.CloseIdleConnections()
+	probe := &http.Client{Transport: transport, Timeout: client.Timeout}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Checker.TLSCheckURL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", c.config.Checker.UserAgent)
+	resp, err := c.do(probe, req)
+	if err != nil {
+		slog.Debug("TLS probe failed", "proxy", result.Proxy, "error", err)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeBody))
+	resp.Body.Close()
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return
+	}
+
+	result.TLS = true
+	if err := verifyChain(req.URL, resp.TLS.PeerCertificates, c.config.Checker.TLSPins); err != nil {
+		slog.Debug("Proxy intercepts TLS", "proxy", result.Proxy, "error", err)
+		result.MITM = true
+		c.flagRisk(result, RiskMITM)
+		if c.config.Checker.TLSCheck == "exclude" {
+			result.Working = false
+		}
+	}
+}
+
+// verifyChain verifies a certificate chain served for u against the system
+// roots, then, if pins is not empty, checks that one of its certificates
+// has a public key among pins
+func verifyChain(u *url.URL, chain []*x509.Certificate, pins []string) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{DNSName: u.Hostname(), Intermediates: intermediates})
+	if err != nil || len(pins) == 0 {
+		return err
+	}
+	for _, cert := range chain {
+		if slices.Contains(pins, spkiPin(cert)) {
+			return nil
+		}
+	}
+	return errors.New("no certificate matches checker.tls_pins")
+}
+
+// spkiPin returns the pin of the public key of a certificate: the base64
+// SHA-256 digest of its SubjectPublicKeyInfo, as used by HPKP and curl's
+// --pinnedpubkey
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,120 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// torExitListMaxAge is the age after which the cached Tor exit list is
+// downloaded again. The Tor Project refreshes it about every hour.
+const torExitListMaxAge = time.Hour
+
+// TorExits is the set of IPs of Tor exit nodes. All methods are safe to
+// call on a nil *TorExits, which contains no IP.
+type TorExits struct {
+	ips map[netip.Addr]bool
+}
+
+// LoadTorExits returns the Tor exit list cached in <output dir>/tor_exits.txt,
+// downloading it from checker.tor_exit_list_url first if the cache is
+// missing or older than an hour. If the download fails, a stale cache is
+// used rather than nothing.
+func LoadTorExits(ctx context.Context, config *Config) (*TorExits, error) {
+	path := filepath.Join(config.Output.Dir, "tor_exits.txt")
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > torExitListMaxAge {
+		if downloadErr := downloadTorExits(ctx, config, path); downloadErr != nil {
+			if err != nil {
+				return nil, downloadErr
+			}
+			slog.Warn("Error downloading Tor exit list, using the cached one", "error", downloadErr,
+				"age", time.Since(info.ModTime()).Round(time.Minute))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTorExits(string(data)), nil
+}
+
+// downloadTorExits saves the Tor exit list to path
+func downloadTorExits(ctx context.Context, config *Config, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, config.Scraper.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", config.Checker.TorExitListURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", config.Scraper.UserAgent)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(parseTorExits(string(data)).ips) == 0 {
+		return errors.New("no IP in Tor exit list")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// parseTorExits reads a Tor exit list: one IP per line, as served by
+// https://check.torproject.org/torbulkexitlist. The "ExitAddress <ip> ..."
+// lines of the exit-addresses format are understood as well.
+func parseTorExits(list string) *TorExits {
+	t := &TorExits{ips: make(map[netip.Addr]bool)}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "ExitAddress" {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if addr, err := netip.ParseAddr(fields[0]); err == nil {
+			t.ips[addr.Unmap()] = true
+		}
+	}
+	return t
+}
+
+// Len returns the number of Tor exit IPs
+func (t *TorExits) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.ips)
+}
+
+// Contains reports whether ip is a Tor exit node
+func (t *TorExits) Contains(ip string) bool {
+	if t == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && t.ips[addr.Unmap()]
+}
@@ -1,19 +1,129 @@
 package src
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ProxyType int
 
 const (
 	ProxyTypeHTTP ProxyType = iota
 	ProxyTypeSOCKS5
+	ProxyTypeHTTPS  // HTTP proxy that only tunnels connections with CONNECT
+	ProxyTypeSOCKS4 // SOCKS4, or SOCKS4a for hostnames
+	ProxyTypeAuto   // Unknown protocol, detected by the checker
 )
 
+// DetectOrder is the order in which protocols are tried to detect the type
+// of a ProxyTypeAuto proxy
+var DetectOrder = []ProxyType{ProxyTypeHTTP, ProxyTypeHTTPS, ProxyTypeSOCKS4, ProxyTypeSOCKS5}
+
 func (t ProxyType) String() string {
 	switch t {
 	case ProxyTypeHTTP:
 		return "HTTP"
 	case ProxyTypeSOCKS5:
 		return "SOCKS5"
+	case ProxyTypeHTTPS:
+		return "HTTPS"
+	case ProxyTypeSOCKS4:
+		return "SOCKS4"
+	case ProxyTypeAuto:
+		return "Auto"
 	default:
 		return "Unknown"
 	}
-} 
\ No newline at end of file
+}
+
+// ParseProxyType parses a protocol name, as in the scheme of a proxy URL
+func ParseProxyType(s string) (ProxyType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "http":
+		return ProxyTypeHTTP, nil
+	case "https":
+		return ProxyTypeHTTPS, nil
+	case "socks4", "socks4a":
+		return ProxyTypeSOCKS4, nil
+	case "socks5", "socks5h":
+		return ProxyTypeSOCKS5, nil
+	case "auto":
+		return ProxyTypeAuto, nil
+	default:
+		return ProxyTypeAuto, fmt.Errorf("unknown protocol %q, expected http, https, socks4, socks5 or auto", s)
+	}
+}
+
+// AnonymityLevel classifies how much a proxy reveals about its client
+type AnonymityLevel int
+
+const (
+	AnonymityUnknown AnonymityLevel = iota
+	AnonymityTransparent
+	AnonymityAnonymous
+	AnonymityElite
+)
+
+func (l AnonymityLevel) String() string {
+	switch l {
+	case AnonymityTransparent:
+		return "transparent"
+	case AnonymityAnonymous:
+		return "anonymous"
+	case AnonymityElite:
+		return "elite"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseAnonymityLevel parses an anonymity level name as used in config files
+func ParseAnonymityLevel(s string) (AnonymityLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "unknown":
+		return AnonymityUnknown, nil
+	case "transparent":
+		return AnonymityTransparent, nil
+	case "anonymous":
+		return AnonymityAnonymous, nil
+	case "elite":
+		return AnonymityElite, nil
+	default:
+		return AnonymityUnknown, fmt.Errorf("unknown anonymity level %q", s)
+	}
+}
+
+// NetworkClass tells whether an exit IP belongs to a hosting provider or to
+// a consumer ISP
+type NetworkClass int
+
+const (
+	NetworkUnknown NetworkClass = iota
+	NetworkResidential
+	NetworkDatacenter
+)
+
+func (n NetworkClass) String() string {
+	switch n {
+	case NetworkResidential:
+		return "residential"
+	case NetworkDatacenter:
+		return "datacenter"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseNetworkClass parses a network class name as used in config files
+func ParseNetworkClass(s string) (NetworkClass, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "unknown":
+		return NetworkUnknown, nil
+	case "residential":
+		return NetworkResidential, nil
+	case "datacenter":
+		return NetworkDatacenter, nil
+	default:
+		return NetworkUnknown, fmt.Errorf("unknown network class %q", s)
+	}
+}
@@ -0,0 +1,241 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3Uploader pushes the output files to an S3-compatible bucket, such as
+// Amazon S3 or MinIO. All methods are safe to call on a nil *S3Uploader,
+// which uploads nothing.
+type S3Uploader struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	outputDir string
+	client    *http.Client
+}
+
+// NewS3Uploader returns the uploader configured by output.upload, nil if no
+// endpoint is set
+func NewS3Uploader(config *Config) (*S3Uploader, error) {
+	upload := config.Output.Upload
+	if upload.Endpoint == "" {
+		return nil, nil
+	}
+	endpoint, err := url.Parse(upload.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Uploader{
+		endpoint:  endpoint,
+		region:    upload.Region,
+		bucket:    upload.Bucket,
+		prefix:    upload.Prefix,
+		accessKey: upload.AccessKey,
+		secretKey: upload.SecretKey,
+		outputDir: config.Output.Dir,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Upload stores every output file twice: under <prefix><time>/, with the
+// UTC time the run ended, and under <prefix>latest/, overwriting the files
+// of the previous run. Keys keep the path of the files in the output
+// directory, e.g. latest/by_country/DE_http.txt. It returns the number of
+// files uploaded.
+func (u *S3Uploader) Upload(ctx context.Context, now time.Time) (int, error) {
+	if u == nil {
+		return 0, nil
+	}
+	paths, err := resultFiles(u.outputDir)
+	if err != nil {
+		return 0, err
+	}
+
+	runPrefix := u.prefix + now.UTC().Format(runTimeFormat) + "/"
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		rel, err := filepath.Rel(u.outputDir, path)
+		if err != nil {
+			return 0, err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, key := range []string{runPrefix + rel, u.prefix + "latest/" + rel} {
+			if err := u.put(ctx, key, data, contentType(path)); err != nil {
+				return 0, fmt.Errorf("uploading %s: %w", key, err)
+			}
+		}
+	}
+	return len(paths), nil
+}
+
+// contentType returns the media type of an output file
+func contentType(path string) string {
+	switch filepath.Ext(path) {
+	case ".csv":
+		return "text/csv; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".yaml":
+		return "application/yaml"
+	case ".pac":
+		return "application/x-ns-proxy-autoconfig"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// put stores data under key with a PutObject request. Buckets are
+// addressed in the path, which MinIO and other S3-compatible servers
+// expect.
+func (u *S3Uploader) put(ctx context.Context, key string, data []byte, contentType string) error {
+	objectURL := *u.endpoint
+	objectURL.Path = strings.TrimSuffix(objectURL.Path, "/") + "/" + u.bucket + "/" + key
+	objectURL.RawPath = awsEscapePath(objectURL.Path)
+	req, err := http.NewRequestWithContext(ctx, "PUT", objectURL.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if u.accessKey != "" {
+		signS3Request(req, data, u.accessKey, u.secretKey, u.region, time.Now())
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// signS3Request adds an AWS Signature Version 4 Authorization header to
+// req, covering its host, its headers and payload
+func signS3Request(req *http.Request, payload []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest, signedHeaders := canonicalS3Request(req, payloadHash)
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := s3StringToSign(amzDate, scope, canonicalRequest)
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// s3StringToSign returns the string signed for a canonical request sent at
+// amzDate, with the credential scope of the signing key
+func s3StringToSign(amzDate, scope, canonicalRequest string) string {
+	return "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+}
+
+// canonicalS3Request returns the canonical request of Signature Version 4
+// for req with a payload of the given hash, and the names of the headers
+// it signs
+func canonicalS3Request(req *http.Request, payloadHash string) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	return canonicalRequest, signedHeaders
+}
+
+// canonicalQuery returns the query parameters sorted by name and value,
+// escaped as Signature Version 4 expects
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// awsEscapePath escapes each segment of an S3 object path
+func awsEscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsEscape percent-encodes every byte but the unreserved characters of
+// RFC 3986, as Signature Version 4 requires
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sha256Hex returns the hex-encoded SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
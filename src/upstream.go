@@ -0,0 +1,161 @@
+package src
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// upstreamProxyEnv lists the environment variables checker.upstream_proxy
+// env reads the proxy from, in order of precedence
+var upstreamProxyEnv = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"}
+
+// upstreamDefaultPorts are the ports of upstream proxies whose URL has none
+var upstreamDefaultPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}
+
+// NewScrapeClient returns the client sources are fetched with: through
+// scraper.upstream_proxy if set, else through the working HTTP and SOCKS5
+// proxies of the previous results in turn with scraper.use_proxies, else
+// directly. It also returns the number of proxies requests are routed
+// through, 0 when they are sent directly.
+func NewScrapeClient(config *Config) (*http.Client, int, error) {
+	var proxies []*url.URL
+	switch {
+	case config.Scraper.UpstreamProxy != "":
+		u, err := url.Parse(config.Scraper.UpstreamProxy)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scraper.upstream_proxy: %w", err)
+		}
+		proxies = []*url.URL{u}
+	case config.Scraper.UseProxies:
+		proxies = workingProxyURLs(config.Output.Dir)
+	}
+	if len(proxies) == 0 {
+		// Timeouts are applied per source, which may override the default
+		return &http.Client{}, 0, nil
+	}
+
+	// Each request, retries included, goes through the next proxy
+	var next atomic.Uint64
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(*http.Request) (*url.URL, error) {
+		return proxies[(next.Add(1)-1)%uint64(len(proxies))], nil
+	}
+	return &http.Client{Transport: transport}, len(proxies), nil
+}
+
+// workingProxyURLs returns the working HTTP and SOCKS5 proxies of the
+// previous results in dir as proxy URLs, in the order of the output files
+func workingProxyURLs(dir string) []*url.URL {
+	var urls []*url.URL
+	for _, proxyType := range []ProxyType{ProxyTypeHTTP, ProxyTypeSOCKS5} {
+		proxies, _ := ReadOutputFile(OutputFile(dir, proxyType))
+		for _, proxy := range proxies {
+			if addr, err := ParseProxyAddr(proxy); err == nil {
+				urls = append(urls, addr.URL(strings.ToLower(proxyType.String())))
+			}
+		}
+	}
+	return urls
+}
+
+// CheckerUpstream returns the proxy of checker.upstream_proxy that check
+// connections go through, or nil if they connect directly. With env, it is
+// read from the first of HTTPS_PROXY, HTTP_PROXY and ALL_PROXY that is set,
+// in upper or lower case, as http:// if it has no scheme.
+func CheckerUpstream(config *Config) (*url.URL, error) {
+	rawURL := config.Checker.UpstreamProxy
+	if rawURL == "env" {
+		rawURL = ""
+		for _, name := range upstreamProxyEnv {
+			if rawURL = os.Getenv(name); rawURL != "" {
+				break
+			}
+		}
+		if rawURL != "" && !strings.Contains(rawURL, "://") {
+			rawURL = "http://" + rawURL
+		}
+	}
+	if rawURL == "" {
+		return nil, nil
+	}
+	if err := validateProxyURL(rawURL); err != nil {
+		return nil, fmt.Errorf("checker.upstream_proxy: %w", err)
+	}
+	return url.Parse(rawURL)
+}
+
+// upstreamDialer opens TCP connections through an upstream proxy: with
+// CONNECT for http:// and https:// proxies, the latter over TLS, and with a
+// SOCKS5 CONNECT for socks5:// and socks5h:// proxies, which resolve
+// hostnames themselves
+type upstreamDialer struct {
+	dialer    contextDialer
+	proxyType ProxyType
+	addr      ProxyAddr
+	timeout   time.Duration
+}
+
+// newUpstreamDialer returns a dialer opening connections through the proxy
+// at upstream with dialer, or dialer itself if upstream is nil. The proxy
+// handshake must complete within timeout.
+func newUpstreamDialer(dialer contextDialer, upstream *url.URL, timeout time.Duration) contextDialer {
+	if upstream == nil {
+		return dialer
+	}
+	d := &upstreamDialer{dialer: dialer, proxyType: ProxyTypeHTTP, timeout: timeout}
+	d.addr.Host, d.addr.Port = upstream.Hostname(), upstream.Port()
+	if d.addr.Port == "" {
+		d.addr.Port = upstreamDefaultPorts[upstream.Scheme]
+	}
+	if upstream.User != nil {
+		d.addr.Username = upstream.User.Username()
+		d.addr.Password, _ = upstream.User.Password()
+	}
+	switch upstream.Scheme {
+	case "https":
+		d.dialer = &tlsDialer{dialer: dialer, serverName: d.addr.Host, timeout: timeout}
+	case "socks5", "socks5h":
+		d.proxyType = ProxyTypeSOCKS5
+	}
+	return d
+}
+
+// DialContext opens a tunnel to addr through the upstream proxy
+func (d *upstreamDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, fmt.Errorf("upstream proxy cannot relay %s", network)
+	}
+	return dialProxy(ctx, d.dialer, d.proxyType, d.addr, addr, d.timeout)
+}
+
+// tlsDialer opens TLS connections, to reach https:// upstream proxies
+type tlsDialer struct {
+	dialer     contextDialer
+	serverName string
+	timeout    time.Duration
+}
+
+// DialContext opens a connection to addr and completes the TLS handshake
+// within timeout
+func (d *tlsDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: d.serverName})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -3,7 +3,9 @@ package src
 import (
 	"bufio"
 	"fmt"
+	"math/rand/v2"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -65,19 +67,26 @@ func AppendLine(path string, line string, mu *sync.Mutex) error {
 	return err
 }
 
-// RemoveDuplicates removes duplicate proxies and returns unique ones
+// RemoveDuplicates removes duplicate proxies and returns unique ones, in
+// place, see DedupeProxies
 func RemoveDuplicates(proxies []string) []string {
-	seen := make(map[string]struct{})
-	var result []string
+	return DedupeProxies(proxies, NewProxySet(DedupExact, len(proxies)))
+}
 
-	for _, proxy := range proxies {
-		if _, exists := seen[proxy]; !exists {
-			seen[proxy] = struct{}{}
-			result = append(result, proxy)
-		}
-	}
+// PrioritizeProxies stably reorders proxies so that the ones with the highest
+// score come first. Proxies missing from scores count as zero.
+func PrioritizeProxies(proxies []string, scores map[string]float64) {
+	sort.SliceStable(proxies, func(i, j int) bool {
+		return scores[proxies[i]] > scores[proxies[j]]
+	})
+}
 
-	return result
+// ShuffleProxies puts proxies in random order, spreading the proxies of a
+// subnet across the run
+func ShuffleProxies(proxies []string) {
+	rand.Shuffle(len(proxies), func(i, j int) {
+		proxies[i], proxies[j] = proxies[j], proxies[i]
+	})
 }
 
 // ClearLine clears the current line in the console
@@ -91,7 +100,7 @@ func ProgressBar(percentage float64, width int) string {
 	if filled > width {
 		filled = width
 	}
-	
+
 	bar := "["
 	for i := 0; i < width; i++ {
 		if i < filled {
@@ -101,11 +110,11 @@ func ProgressBar(percentage float64, width int) string {
 		}
 	}
 	bar += "]"
-	
+
 	return bar
 }
 
 // WriteFile writes content to a file
 func WriteFile(path string, content string) error {
 	return os.WriteFile(path, []byte(content+"\n"), 0644)
-} 
\ No newline at end of file
+}
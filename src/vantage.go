@@ -0,0 +1,146 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// vantagePoint is a local address proxies are checked from
+type vantagePoint struct {
+	name string
+	ip   net.IP
+}
+
+// vantageKey is the context key of the vantage point connections of a check
+// are opened from
+type vantageKey struct{}
+
+// resolveVantagePoints returns the vantage points of
+// checker.vantage_points, with the first address of their interface, IPv4
+// if it has one
+func resolveVantagePoints(points []VantagePointConfig) ([]vantagePoint, error) {
+	resolved := make([]vantagePoint, 0, len(points))
+	names := make(map[string]bool, len(points))
+	for _, point := range points {
+		v := vantagePoint{name: point.Name}
+		switch {
+		case point.Address != "" && point.Interface != "":
+			return nil, fmt.Errorf("%q: address and interface are exclusive", point.Name)
+		case point.Address != "":
+			if v.ip = net.ParseIP(point.Address); v.ip == nil {
+				return nil, fmt.Errorf("invalid address %q", point.Address)
+			}
+			if v.name == "" {
+				v.name = point.Address
+			}
+		case point.Interface != "":
+			ip, err := interfaceIP(point.Interface)
+			if err != nil {
+				return nil, err
+			}
+			v.ip = ip
+			if v.name == "" {
+				v.name = point.Interface
+			}
+		default:
+			return nil, fmt.Errorf("%q: address or interface required", point.Name)
+		}
+		if names[v.name] {
+			return nil, fmt.Errorf("duplicate name %q", v.name)
+		}
+		names[v.name] = true
+		resolved = append(resolved, v)
+	}
+	return resolved, nil
+}
+
+// interfaceIP returns the first address of a network interface, IPv4 if it
+// has one
+func interfaceIP(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	var first net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if first == nil {
+			first = ipNet.IP
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("interface %s has no address", name)
+	}
+	return first, nil
+}
+
+// vantageDialer opens connections with dialer from the local address of
+// the vantage point of their context, if any
+type vantageDialer struct {
+	dialer *net.Dialer
+}
+
+func (d vantageDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	v, ok := ctx.Value(vantageKey{}).(vantagePoint)
+	if !ok {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	dialer := *d.dialer
+	if strings.HasPrefix(network, "udp") {
+		dialer.LocalAddr = &net.UDPAddr{IP: v.ip}
+	} else {
+		dialer.LocalAddr = &net.TCPAddr{IP: v.ip}
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// checkVantages checks a proxy from every vantage point at once and merges
+// the results into the fastest working one, listing the vantage points the
+// proxy works from. With checker.vantage_mode: all, the proxy is only
+// working if it works from all of them. It also reports whether a check
+// was interrupted by ctx.
+func (c *ProxyChecker) checkVantages(ctx context.Context, proxyStr string, proxyType ProxyType) (CheckResult, bool) {
+	results := make([]CheckResult, len(c.vantages))
+	interrupted := make([]bool, len(c.vantages))
+	var wg sync.WaitGroup
+	for i, v := range c.vantages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], interrupted[i] = c.checkRetrying(context.WithValue(ctx, vantageKey{}, v), proxyStr, proxyType)
+		}()
+	}
+	wg.Wait()
+
+	merged := results[0]
+	var vantages []string
+	stopped := false
+	for i, result := range results {
+		stopped = stopped || interrupted[i]
+		if !result.Working {
+			continue
+		}
+		vantages = append(vantages, c.vantages[i].name)
+		if !merged.Working || result.Speed < merged.Speed {
+			merged = result
+		}
+	}
+	merged.Vantages = vantages
+	if c.config.Checker.VantageMode == "all" && len(vantages) < len(c.vantages) {
+		merged.Working = false
+	}
+	return merged, stopped
+}
@@ -0,0 +1,128 @@
+package src
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileWatcher records the changes of files, such as config.yaml and the
+// source lists, for a daemon to reload them before its next cycle. It
+// watches the directories of the files, so that files replaced on save by
+// editors are still followed.
+type FileWatcher struct {
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	paths   map[string]bool // Watched files, and directories whose files are all watched
+	dirs    map[string]bool // Directories added to watcher
+	changed map[string]bool
+}
+
+// NewFileWatcher returns a watcher of no file until Watch is called
+func NewFileWatcher() (*FileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &FileWatcher{
+		watcher: watcher,
+		paths:   make(map[string]bool),
+		dirs:    make(map[string]bool),
+		changed: make(map[string]bool),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Watch replaces the watched paths. A directory stands for all the files
+// in it; a missing file is picked up once created.
+func (w *FileWatcher) Watch(paths ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watched := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		watched[path] = true
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs[path] = true
+		} else {
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+
+	for dir := range dirs {
+		if !w.dirs[dir] {
+			if err := w.watcher.Add(dir); err != nil {
+				return err
+			}
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+		}
+	}
+	w.paths, w.dirs = watched, dirs
+	return nil
+}
+
+// Changed returns the watched files changed since the last call, sorted
+func (w *FileWatcher) Changed() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := make([]string, 0, len(w.changed))
+	for path := range w.changed {
+		changed = append(changed, path)
+	}
+	clear(w.changed)
+	slices.Sort(changed)
+	return changed
+}
+
+// Close stops watching
+func (w *FileWatcher) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.watcher.Close()
+}
+
+// run records the events of watched files until the watcher is closed
+func (w *FileWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// Changes of permissions or access times alone are ignored
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			w.mu.Lock()
+			if w.paths[event.Name] || w.paths[filepath.Dir(event.Name)] {
+				w.changed[event.Name] = true
+				slog.Debug("Watched file changed", "path", event.Name, "op", event.Op.String())
+			}
+			w.mu.Unlock()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("Error watching files", "error", err)
+		}
+	}
+}